@@ -9,5 +9,10 @@ import (
 func main() {
 	s := server.NewServer()
 
+	// these log their errors and keep going, so one failing doesn't stop the
+	// others
+	go s.ProcessOutbox()
+	go s.ProcessDigests()
+
 	log.Fatalln(s.ProcessMediaQueue())
 }
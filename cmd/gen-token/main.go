@@ -3,11 +3,11 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/jackc/pgx"
 	"github.com/lambdacollective/cobbles-api/server"
 )
 
@@ -17,24 +17,40 @@ func main() {
 		panic("SERVER_SECRET missing")
 	}
 
-	s := server.Server{ServerSecret: secret}
-	var userID int64
-	var err error
-	if os.Getenv("USER_ID") != "" {
-		userID, err = strconv.ParseInt(os.Getenv("USER_ID"), 10, 64)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		userID = rand.Int63n(100000000)
+	// tokens need a session to be accepted
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		panic("DATABASE_URL missing")
 	}
 
-	token, err := s.GenerateAuthJWT(userID)
+	connConfig, err := pgx.ParseConnectionString(databaseURL)
+	if err != nil {
+		panic(err)
+	}
+
+	connPool, err := pgx.NewConnPool(pgx.ConnPoolConfig{ConnConfig: connConfig})
+	if err != nil {
+		panic(err)
+	}
+
+	s := server.Server{ServerSecret: secret, ConnPool: connPool}
+	// sessions reference users, so it has to be one that exists
+	if os.Getenv("USER_ID") == "" {
+		panic("USER_ID missing")
+	}
+
+	userID, err := strconv.ParseInt(os.Getenv("USER_ID"), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	tokens, err := s.IssueAuthTokens(userID, nil)
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Printf("UserID: %d\n", userID)
-	fmt.Printf("Token: %s\n", token.Token)
-	fmt.Printf("ExpiresAt: %s", token.ExpiresAt.Format(time.RFC3339))
+	fmt.Printf("Token: %s\n", tokens.Access.Token)
+	fmt.Printf("ExpiresAt: %s\n", tokens.Access.ExpiresAt.Format(time.RFC3339))
+	fmt.Printf("RefreshToken: %s", tokens.Refresh.Token)
 }
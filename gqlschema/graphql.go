@@ -6,6 +6,7 @@ const typeDefs = `
 	schema {
 		query: Query
 		mutation: Mutation
+		subscription: Subscription
 	}
 
 	type Query {
@@ -22,13 +23,36 @@ const typeDefs = `
 		getCommentsByPostID(id: Int!): PostCommentsResult!
 
 		feed(input: FeedInput): FeedResult
+		nearbyFeed(input: NearbyFeedInput!): FeedResult
+		neighborhoodForLocation(lat: Float!, lng: Float!): Neighborhood
+		neighborhoods(input: NeighborhoodsInput): [Neighborhood!]!
+		// sessions are the current user's logged in devices
+		sessions(): [Session!]!
+		// passkeys are the current user's registered passkeys
+		passkeys(): [Passkey!]!
+		// users the current user blocked or muted, most recent first
+		blockedUsers(): [User!]!
+		mutedUsers(): [User!]!
 		conversationByID(id: String!): Conversation!
 		conversations(input: ConversationsInput): ConversationsResult!
 		notifications(input: NotificationsInput): NotificationsResult!
+		notificationSettings(): NotificationSettings!
 
 		hasCurrentUserLikedPost(id: Int!): Boolean!
 		isLiked(id: Int!): Boolean!
 		postComments(input: UserPostCommentsInput!): PostCommentsResult!
+
+		// reportedPosts is the moderation queue, moderators and admins only
+		reportedPosts(input: ReportedPostsInput): ReportedPostsResult!
+		// contentFlags is what the content filter flagged or shadow hid,
+		// moderators and admins only
+		contentFlags(input: ContentFlagsInput): ContentFlagsResult!
+		// contentRules are the content filter's rules added by admins, admins
+		// only
+		contentRules(): [ContentRule!]!
+		// auditEvents is every admin and moderator action, newest first,
+		// admins only
+		auditEvents(input: AuditEventsInput): AuditEventsResult!
 	}
 
 	type Mutation {
@@ -47,23 +71,95 @@ const typeDefs = `
 		createFollower(id: Int!): Follower
 		unfollow(id: Int!): Boolean!
 
+		// Blocking hides a user's posts, comments and conversations, and stops
+		// either of you messaging the other. Muting only hides their posts and
+		// comments. Each replaces the other.
+		blockUser(id: Int!): Boolean!
+		unblockUser(id: Int!): Boolean!
+		muteUser(id: Int!): Boolean!
+		unmuteUser(id: Int!): Boolean!
+
 		updateUser(input: UpdateUserInput!): User
 		updateFCMToken(input: UpdateTokenInput!): User
 
 		getOrCreateConversation(input: GetOrCreateConversationInput!): GetOrCreateConversationResult!
 		sendMessage(input: SendMessageInput!): SendMessageResult
 
+		// deprecated: registers an FCM token, use registerPushDevice
 		userAssignDeviceToken(input: UserAssignDeviceTokenInput!): Boolean
+		// registerPushDevice sends the user's pushes to a device, a token
+		// moves to whoever registered it last
+		registerPushDevice(input: RegisterPushDeviceInput!): Boolean!
+		unregisterPushDevice(token: String!): Boolean!
 
 		requestLoginCode(input: RequestLoginCodeInput!): Boolean
 		loginUser(input: LoginUserInput!): LoginUserResult
+		refreshAuthToken(input: RefreshAuthTokenInput!): LoginUserResult
+		logout(input: LogoutInput): Boolean!
+		logoutAllDevices(): Boolean!
+
+		// Email login, logged in users get a link confirming the email for
+		// them instead, which only confirmEmail accepts
+		requestEmailLogin(input: RequestEmailLoginInput!): Boolean
+		loginWithEmail(input: LoginWithEmailInput!): LoginUserResult
+		confirmEmail(input: ConfirmEmailInput!): User
+
+		// Passkeys, binary fields are base64url
+		beginPasskeyRegistration(): PasskeyChallenge!
+		finishPasskeyRegistration(input: FinishPasskeyRegistrationInput!): Passkey
+		beginPasskeyLogin(): PasskeyChallenge!
+		loginWithPasskey(input: LoginWithPasskeyInput!): LoginUserResult
+		removePasskey(id: ID!): Boolean!
 
 		markNotificationRead(input: MarkNotificationReadInput!): Boolean
+		// markAllNotificationsRead returns how many notifications were unread
+		markAllNotificationsRead: Int!
+		updateNotificationSettings(input: UpdateNotificationSettingsInput!): NotificationSettings!
 
 		requestMediaUpload(input: RequestMediaUploadInput!): RequestMediaUploadResult
 
 		likePost(id: Int!): Boolean!
 		unlikePost(id: Int!): Boolean!
+
+		// Moderation, moderators and admins only, acting on a post closes all
+		// of its open reports
+		updateReportedPost(input: UpdateReportedPostInput!): ReportedPost
+		moderatePost(input: ModeratePostInput!): Post
+
+		// Content filter rules, admins only. Patterns are case insensitive
+		// regular expressions.
+		createContentRule(input: CreateContentRuleInput!): ContentRule
+		setContentRuleEnabled(input: SetContentRuleEnabledInput!): ContentRule
+		deleteContentRule(id: ID!): Boolean!
+
+		// admins only, roles take effect when the user's token is refreshed
+		setUserRoles(input: SetUserRolesInput!): [Role!]!
+
+		// Account status, admins only. Suspended and banned users can't use
+		// the API and their posts and comments are hidden.
+		suspendUser(input: SuspendUserInput!): AccountStatus
+		banUser(input: ChangeUserStatusInput!): AccountStatus
+		// reinstateUser lifts a suspension or ban
+		reinstateUser(input: ChangeUserStatusInput!): AccountStatus
+
+		// Neighborhood administration, admins only
+		createNeighborhood(input: CreateNeighborhoodInput!): Neighborhood
+		updateNeighborhood(input: UpdateNeighborhoodInput!): Neighborhood
+		// mergeNeighborhoods returns the neighborhood merged into
+		mergeNeighborhoods(input: MergeNeighborhoodsInput!): Neighborhood
+	}
+
+	// Subscriptions are served over the graphql-ws WebSocket protocol at
+	// /graphql/ws, and only to a logged in user
+	type Subscription {
+		// messageAdded is each message sent in one of the user's conversations
+		messageAdded(conversationID: ID!): Message!
+		// conversationUpdated is any of the user's conversations when it's
+		// started or gets a message
+		conversationUpdated(): Conversation!
+		// notificationAdded is each notification added to the user's inbox,
+		// again when a like or comment coalesces into it
+		notificationAdded(): Notification!
 	}
 
 	input RemovePostInput {
@@ -72,12 +168,37 @@ const typeDefs = `
 
 	input ReportedPostInput {
 		postID: ID!
-		personReportingID: ID!
-		ReportingReasonText: String!
+		// deprecated: ignored, reports are from the current user
+		personReportingID: ID
+		reason: ReportReason = OTHER
+		ReportingReasonText: String
+	}
+
+	enum ReportReason {
+		SPAM
+		HARASSMENT
+		HATE_SPEECH
+		VIOLENCE
+		SEXUAL
+		MISINFORMATION
+		OTHER
+	}
+
+	enum ModerationAction {
+		// dismiss shows the post again if reports or a moderator hid it, but
+		// not if the content filter did
+		DISMISS
+		// hidePost keeps the post out of feeds, except its author's
+		HIDE_POST
+		REMOVE_POST
+		// suspendAuthor removes the post and stops its author posting
+		SUSPEND_AUTHOR
 	}
 
 	input NotificationsInput {
-		keepSchemaHappy: Boolean
+		unreadOnly: Boolean
+		pageToken: String
+		limit: Int
 	}
 
 	type Follower {
@@ -88,13 +209,85 @@ const typeDefs = `
 
 	type NotificationsResult {
 		notifications: [Notification]!
+		// unreadCount is all the user's unread notifications, not just this
+		// page's
+		unreadCount: Int!
+		nextPageToken: String
+	}
+
+	enum NotificationKind {
+		LIKE
+		COMMENT
+		// reply is a reply to the user's comment
+		REPLY
+		FOLLOW
+		MESSAGE
+		// digest is the daily round up of the user's neighborhood, for users
+		// with neighborhoodPosts on
+		DIGEST
+	}
+
+	// NotificationSettings choose what goes to the user's inbox and devices.
+	// Nothing is pushed during quiet hours, it's still in the inbox.
+	type NotificationSettings {
+		messages: Boolean!
+		// comments on the user's posts and replies to their comments
+		comments: Boolean!
+		likes: Boolean!
+		follows: Boolean!
+		neighborhoodPosts: Boolean!
+		quietHours: QuietHours
+		// timeZone is the IANA time zone quiet hours are in, like
+		// America/New_York
+		timeZone: String!
+	}
+
+	// QuietHours are HH:MM times, an end before the start is the next day
+	type QuietHours {
+		start: String!
+		end: String!
+	}
+
+	// fields left out keep their value
+	input UpdateNotificationSettingsInput {
+		messages: Boolean
+		comments: Boolean
+		likes: Boolean
+		follows: Boolean
+		neighborhoodPosts: Boolean
+		quietHours: QuietHoursInput
+		clearQuietHours: Boolean
+		timeZone: String
+	}
+
+	input QuietHoursInput {
+		start: String!
+		end: String!
 	}
 
 	type Notification {
 		id: ID!
+		kind: NotificationKind!
 		unread: Boolean!
 		content: String!
 		timestamp: Timestamp!
+		// actor is who caused the notification, the latest of actorCount
+		// users when likes or comments on a post were coalesced
+		actor: User
+		actorCount: Int!
+		// the notification's target, which are set depends on its kind.
+		// They're null once the target is deleted.
+		post: Post
+		comment: PostComment
+		conversation: Conversation
+		// deepLink opens the target in the app, push notifications carry the
+		// same link. It's one of
+		//   cobbles://posts/{postID}
+		//   cobbles://posts/{postID}/comments/{commentID}
+		//   cobbles://conversations/{conversationID}
+		//   cobbles://users/{userID}
+		//   cobbles://notifications
+		deepLink: String!
 	}
 
 	input MarkNotificationReadInput {
@@ -126,6 +319,9 @@ const typeDefs = `
 		mediaMetadata: MediaMetadataInput
 
 		tags: [String!]
+
+		// location is where the post is about, shown in nearbyFeed
+		location: LocationInput
 	}
 
 	input CreatePostCommentInput {
@@ -148,6 +344,19 @@ const typeDefs = `
 		deviceToken: String!
 	}
 
+	enum PushPlatform {
+		APNS
+		FCM
+	}
+
+	input RegisterPushDeviceInput {
+		platform: PushPlatform!
+		token: String!
+		// deviceID is the ID the device logs in with, its older tokens are
+		// replaced
+		deviceID: String
+	}
+
 	input UpdatePostInput {
 		id: ID!
 		title: String
@@ -160,11 +369,87 @@ const typeDefs = `
 		phoneNumber: String!
 		loginCode: String!
 		fcmToken: String
+		// deviceID identifies the device across logins, logging in again
+		// revokes the device's previous refresh token
+		deviceID: String
 	}
 
 	type LoginUserResult {
+		// token is a short lived access token for the authorization header
 		token: String!
 		expiresAt: Timestamp!
+
+		// refreshToken gets a new token from refreshAuthToken, it can only be
+		// used once
+		refreshToken: String!
+		refreshTokenExpiresAt: Timestamp!
+	}
+
+	input RefreshAuthTokenInput {
+		refreshToken: String!
+	}
+
+	input LogoutInput {
+		// defaults to the current session
+		sessionID: ID
+	}
+
+	input RequestEmailLoginInput {
+		email: String!
+	}
+
+	input LoginWithEmailInput {
+		// token is from the emailed link
+		token: String!
+		fcmToken: String
+		deviceID: String
+	}
+
+	input ConfirmEmailInput {
+		// token is from the emailed link, which has confirm=email
+		token: String!
+	}
+
+	type PasskeyChallenge {
+		challengeID: ID!
+		// options is the JSON publicKey argument for
+		// navigator.credentials.create() or .get()
+		options: String!
+	}
+
+	input FinishPasskeyRegistrationInput {
+		challengeID: ID!
+		clientDataJSON: String!
+		attestationObject: String!
+		name: String
+	}
+
+	input LoginWithPasskeyInput {
+		challengeID: ID!
+		credentialID: String!
+		clientDataJSON: String!
+		authenticatorData: String!
+		signature: String!
+		fcmToken: String
+		deviceID: String
+	}
+
+	type Passkey {
+		id: ID!
+		name: String
+		credentialID: String!
+		createdAt: Timestamp!
+		lastUsedAt: Timestamp
+	}
+
+	type Session {
+		id: ID!
+		deviceID: String
+		// current is the session making this request
+		current: Boolean!
+
+		createdAt: Timestamp!
+		lastSeenAt: Timestamp!
 	}
 
 	
@@ -180,12 +465,18 @@ const typeDefs = `
 	input UpdateUserInput {
 		name: String
 		photoURL: String
+		// zipCode also sets the user's home neighborhood when it falls in
+		// one, unless neighborhoodSlug is given
 		zipCode: String
 		bio: String
+
+		// neighborhoodSlug sets the user's home neighborhood
+		neighborhoodSlug: String
 	}
 
 	input UpdateTokenInput {
-		userID: ID!
+		// deprecated: ignored, the current user's token is updated
+		userID: ID
 		fcmToken: String!
 	}
 
@@ -208,6 +499,10 @@ const typeDefs = `
 
 		shareLinkURL: String
 
+		location: Location
+		// distanceMeters is only set on posts from nearbyFeed
+		distanceMeters: Float
+
 		preview: PostMedia
 		media: PostMedia
 		
@@ -227,12 +522,206 @@ const typeDefs = `
 		height: Int
 	}
 
+	input UpdateReportedPostInput {
+		id: ID!
+		action: ModerationAction!
+	}
+
+	input ModeratePostInput {
+		postID: ID!
+		action: ModerationAction!
+		// suspendDays is how long SUSPEND_AUTHOR suspends for, 7 by default
+		suspendDays: Int
+	}
+
+	enum ContentKind {
+		POST
+		COMMENT
+		MESSAGE
+	}
+
+	// FilterVerdict is what the content filter does with matching text
+	enum FilterVerdict {
+		// flag keeps the content and lists it in contentFlags
+		FLAG
+		// shadowHide keeps the content but only shows it to its author
+		SHADOW_HIDE
+		REJECT
+	}
+
+	type ContentRule {
+		id: ID!
+		pattern: String!
+		verdict: FilterVerdict!
+		// kinds is empty when the rule applies to all content
+		kinds: [ContentKind!]!
+		reason: String
+		enabled: Boolean!
+		createdAt: Timestamp!
+	}
+
+	input CreateContentRuleInput {
+		pattern: String!
+		verdict: FilterVerdict!
+		kinds: [ContentKind!]
+		reason: String
+	}
+
+	input SetContentRuleEnabledInput {
+		id: ID!
+		enabled: Boolean!
+	}
+
+	type ContentFlag {
+		id: ID!
+		kind: ContentKind!
+		// contentID is the post's, comment's or message's ID
+		contentID: ID!
+		author: User
+		text: String!
+		verdict: FilterVerdict!
+		reason: String
+		createdAt: Timestamp!
+	}
+
+	input ContentFlagsInput {
+		pageToken: String
+		limit: Int
+	}
+
+	type ContentFlagsResult {
+		contentFlags: [ContentFlag!]!
+		nextPageToken: String
+	}
+
+	enum AuditAction {
+		SET_USER_ROLES
+		CREATE_NEIGHBORHOOD
+		UPDATE_NEIGHBORHOOD
+		MERGE_NEIGHBORHOODS
+		// moderators' actions on posts, see ModerationAction
+		DISMISS
+		HIDE_POST
+		REMOVE_POST
+		SUSPEND_AUTHOR
+		SUSPEND_USER
+		BAN_USER
+		REINSTATE_USER
+		CREATE_CONTENT_RULE
+		UPDATE_CONTENT_RULE
+		DELETE_CONTENT_RULE
+		// the server hid a post after reports, there's no actor
+		AUTO_HIDE_POST
+	}
+
+	enum AuditTargetType {
+		USER
+		POST
+		NEIGHBORHOOD
+		CONTENT_RULE
+	}
+
+	type AuditEvent {
+		id: ID!
+		// actor is null for actions the server took itself
+		actor: User
+		action: AuditAction!
+		targetType: AuditTargetType!
+		targetID: ID!
+		// before and after are the target as JSON, null when it didn't
+		// exist
+		before: String
+		after: String
+		createdAt: Timestamp!
+	}
+
+	input AuditEventsInput {
+		actorID: ID
+		action: AuditAction
+		targetType: AuditTargetType
+		targetID: ID
+		since: Timestamp
+		pageToken: String
+		limit: Int
+	}
+
+	type AuditEventsResult {
+		auditEvents: [AuditEvent!]!
+		nextPageToken: String
+	}
+
+	input ReportedPostsInput {
+		pageToken: String
+		limit: Int
+	}
+
+	type ReportedPostsResult {
+		reportedPosts: [ReportedPostGroup!]!
+		nextPageToken: String
+	}
+
+	// ReportedPostGroup is a post's open reports
+	type ReportedPostGroup {
+		post: Post
+		reportCount: Int!
+		// reportWeight counts each reporter once, weighted by how trusted
+		// their reports are
+		reportWeight: Float!
+		// autoHiddenAt is when reports hid the post until a moderator acts,
+		// dismissing shows it again
+		autoHiddenAt: Timestamp
+		reasons: [ReportReason!]!
+		reports: [ReportedPost!]!
+		firstReportedAt: Timestamp!
+		lastReportedAt: Timestamp!
+	}
+
+	enum Role {
+		ADMIN
+		MODERATOR
+	}
+
+	input SetUserRolesInput {
+		userID: ID!
+		roles: [Role!]!
+	}
+
+	enum UserStatus {
+		ACTIVE
+		SUSPENDED
+		BANNED
+	}
+
+	type AccountStatus {
+		status: UserStatus!
+		// suspendedUntil is only set for suspended users
+		suspendedUntil: Timestamp
+		// reason is why an admin or moderator last changed the status
+		reason: String
+		changedBy: User
+		changedAt: Timestamp
+	}
+
+	input SuspendUserInput {
+		userID: ID!
+		days: Int!
+		reason: String!
+	}
+
+	input ChangeUserStatusInput {
+		userID: ID!
+		reason: String!
+	}
+
 	type ReportedPost {
 		id: ID!
 		postID: Int!
 		personReportingID: Int!
+		reason: ReportReason!
 		reportingReasonText: String!
-		ActionTaken: Int!
+		// actionTaken is null until a moderator handles the report
+		actionTaken: ModerationAction
+		actionTakenAt: Timestamp
 
 		createdAt: Timestamp!
 		updatedAt: Timestamp!
@@ -242,12 +731,72 @@ const typeDefs = `
 		id: ID!
 		slug: String!
 		name: String!
+
+		zipCodes: [String!]!
+		center: Location
+		// boundary is a closed polygon, null if the neighborhood has none
+		boundary: [Location!]
+
+		// retired neighborhoods can't be picked as a home or posted in
+		retired: Boolean!
+		// mergedInto is where a merged neighborhood's posts and users went
+		mergedInto: Neighborhood
+	}
+
+	input NeighborhoodsInput {
+		// admins only
+		includeRetired: Boolean
+	}
+
+	input CreateNeighborhoodInput {
+		slug: String!
+		name: String!
+		zipCodes: [String!]
+		center: LocationInput
+		// boundary is a polygon of at least 3 points
+		boundary: [LocationInput!]
+	}
+
+	input UpdateNeighborhoodInput {
+		// slug of the neighborhood to update
+		slug: String!
+
+		// null fields are left unchanged
+		newSlug: String
+		name: String
+		zipCodes: [String!]
+		center: LocationInput
+		boundary: [LocationInput!]
+		retired: Boolean
+	}
+
+	input MergeNeighborhoodsInput {
+		fromSlug: String!
+		intoSlug: String!
+	}
+
+	type Location {
+		lat: Float!
+		lng: Float!
+	}
+
+	input LocationInput {
+		lat: Float!
+		lng: Float!
 	}
 
 	type User {
 		id: ID!
 
+		// phoneNumber is only shown to its owner
 		phoneNumber: String
+		// email is only shown to its owner
+		email: String
+		// roles are only shown to the user and admins, admins can do
+		// everything moderators can
+		roles: [Role!]
+		// accountStatus is only shown to the user and admins
+		accountStatus: AccountStatus
 		name: String
 		photoURL: String
 		zipCode: String
@@ -256,6 +805,9 @@ const typeDefs = `
 		following: Int
 
 		postCount: Int!
+
+		// neighborhood is the user's home neighborhood, null if not picked yet
+		neighborhood: Neighborhood
 		
 		createdAt: Timestamp!
 		updatedAt: Timestamp!
@@ -266,6 +818,19 @@ const typeDefs = `
 	input FeedInput {
 		tags: [String!]
 
+		// defaults to the current user's home neighborhood
+		neighborhoodSlug: String
+
+		pageToken: String
+		limit: Int
+	}
+
+	input NearbyFeedInput {
+		lat: Float!
+		lng: Float!
+		// defaults to 1000, at most 50000
+		radiusMeters: Int
+
 		pageToken: String
 		limit: Int
 	}
@@ -354,7 +919,9 @@ const typeDefs = `
 
 	input SendMessageInput {
 		conversationID: ID!
-		destinationUserID: ID!
+		// destinationUserID must be another participant of the conversation,
+		// defaults to the other participant
+		destinationUserID: ID
 		body: String!
 	}
 
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"text/scanner"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// Subscriptions speak Apollo's graphql-ws protocol, see
+// https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
+const graphqlWSProtocol = "graphql-ws"
+
+const (
+	wsConnectionInit      = "connection_init"
+	wsConnectionAck       = "connection_ack"
+	wsConnectionError     = "connection_error"
+	wsConnectionKeepAlive = "ka"
+	wsConnectionTerminate = "connection_terminate"
+	wsStart               = "start"
+	wsData                = "data"
+	wsError               = "error"
+	wsComplete            = "complete"
+	wsStop                = "stop"
+)
+
+const (
+	wsKeepAliveInterval = 20 * time.Second
+	wsWriteTimeout      = 10 * time.Second
+	wsReadLimit         = 64 * 1024
+
+	// wsReauthInterval is how often an open connection's session and account
+	// status are checked again
+	wsReauthInterval = time.Minute
+)
+
+var wsUpgrader = websocket.Upgrader{
+	Subprotocols: []string{graphqlWSProtocol},
+	// clients authenticate with access tokens, never cookies, so another
+	// site can't subscribe as someone
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsInitPayload is connection_init's, browsers can't set headers on a
+// WebSocket so the access token may come here instead
+type wsInitPayload struct {
+	Authorization string `json:"authorization"`
+}
+
+type wsStartPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// subscriptionHandler serves schema's subscriptions over graphql-ws, queries
+// and mutations go to /graphql. The user's access token comes from the
+// upgrade request, or connection_init if it had none. It's checked again
+// before every operation and every wsReauthInterval, and the socket is closed
+// once it expires or the session is revoked or the account restricted.
+func subscriptionHandler(s *server.Server, schema *graphql.Schema) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader already responded
+			log.Print(err)
+			return
+		}
+
+		c := &wsConn{
+			conn:       conn,
+			server:     s,
+			schema:     schema,
+			authHeader: r.Header.Get("authorization"),
+			operations: map[string]context.CancelFunc{},
+		}
+		c.serve(r.Context())
+	})
+}
+
+type wsConn struct {
+	conn   *websocket.Conn
+	server *server.Server
+	schema *graphql.Schema
+
+	// authHeader is the access token as an Authorization header value,
+	// empty for someone logged out
+	authHeader string
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	operations map[string]context.CancelFunc
+}
+
+func (c *wsConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.conn.Close()
+
+	c.conn.SetReadLimit(wsReadLimit)
+
+	if c.conn.Subprotocol() != graphqlWSProtocol {
+		c.send("", wsConnectionError, map[string]string{"message": "graphql-ws subprotocol required"})
+		return
+	}
+
+	var initialized bool
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && ctx.Err() == nil {
+				log.Print(err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case wsConnectionInit:
+			if initialized {
+				continue
+			}
+
+			if err := c.init(msg.Payload); err != nil {
+				c.send("", wsConnectionError, map[string]string{"message": err.Error()})
+				return
+			}
+
+			_, expiresAt, authErr := c.authenticate(ctx)
+			if authErr != nil {
+				c.send("", wsConnectionError, authErr)
+				return
+			}
+
+			initialized = true
+			c.send("", wsConnectionAck, nil)
+			c.send("", wsConnectionKeepAlive, nil)
+			go c.keepAlive(ctx)
+			if c.authHeader != "" {
+				go c.watchAuth(ctx, expiresAt)
+			}
+
+		case wsStart:
+			if !initialized {
+				c.send(msg.ID, wsError, map[string]string{"message": "connection not initialized"})
+				continue
+			}
+
+			opCtx, _, authErr := c.authenticate(ctx)
+			if authErr != nil {
+				c.send("", wsConnectionError, authErr)
+				return
+			}
+
+			c.start(ctx, opCtx, msg)
+
+		case wsStop:
+			c.stop(msg.ID)
+
+		case wsConnectionTerminate:
+			return
+
+		default:
+			c.send(msg.ID, wsError, map[string]string{"message": "unknown message type " + msg.Type})
+		}
+	}
+}
+
+// init takes the access token from connection_init's payload, if the upgrade
+// request didn't have one
+func (c *wsConn) init(payload json.RawMessage) error {
+	if c.authHeader != "" || len(payload) == 0 {
+		return nil
+	}
+
+	var init wsInitPayload
+	if err := json.Unmarshal(payload, &init); err != nil {
+		return err
+	}
+
+	c.authHeader = init.Authorization
+	return nil
+}
+
+// authenticate checks the connection's access token again, adding who it's
+// for to ctx. The error is connection_error's payload.
+func (c *wsConn) authenticate(ctx context.Context) (context.Context, time.Time, map[string]interface{}) {
+	if c.authHeader == "" {
+		return ctx, time.Time{}, nil
+	}
+
+	authCtx, claims, authErr := authenticate(ctx, c.server, c.authHeader)
+	if authErr != nil {
+		if authErr.restricted != nil {
+			return nil, time.Time{}, accountRestrictedError(authErr.restricted)
+		}
+
+		return nil, time.Time{}, map[string]interface{}{"message": authErr.message}
+	}
+
+	return authCtx, time.Unix(claims.ExpiresAt, 0), nil
+}
+
+// watchAuth closes the connection when its access token expires, or sooner
+// if a periodic check finds its session revoked or account restricted
+func (c *wsConn) watchAuth(ctx context.Context, expiresAt time.Time) {
+	expired := time.NewTimer(time.Until(expiresAt))
+	defer expired.Stop()
+
+	ticker := time.NewTicker(wsReauthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expired.C:
+			c.close(map[string]interface{}{"message": "access token expired"})
+			return
+		case <-ticker.C:
+			if _, _, authErr := c.authenticate(ctx); authErr != nil {
+				c.close(authErr)
+				return
+			}
+		}
+	}
+}
+
+// close ends the connection with a connection_error, which stops serve and
+// every operation
+func (c *wsConn) close(payload map[string]interface{}) {
+	c.send("", wsConnectionError, payload)
+	c.conn.Close()
+}
+
+// start runs a subscription in opCtx, which has the user as of now, until
+// it's stopped or the connection's ctx is done
+func (c *wsConn) start(ctx, opCtx context.Context, msg wsMessage) {
+	var payload wsStartPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.send(msg.ID, wsError, map[string]string{"message": err.Error()})
+		return
+	}
+
+	// queries and mutations would otherwise run as whoever connected, for as
+	// long as the socket stays open
+	if operationType(payload.Query, payload.OperationName) != "subscription" {
+		c.send(msg.ID, wsError, map[string]string{"message": "only subscriptions are served over WebSocket, POST queries and mutations to /graphql"})
+		return
+	}
+
+	opCtx, cancel := context.WithCancel(opCtx)
+
+	c.mu.Lock()
+	if _, exists := c.operations[msg.ID]; exists {
+		c.mu.Unlock()
+		cancel()
+		c.send(msg.ID, wsError, map[string]string{"message": "operation " + msg.ID + " already started"})
+		return
+	}
+	c.operations[msg.ID] = cancel
+	c.mu.Unlock()
+
+	responses, err := c.schema.Subscribe(opCtx, payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		c.stop(msg.ID)
+		c.send(msg.ID, wsError, map[string]string{"message": err.Error()})
+		return
+	}
+
+	go func() {
+		for response := range responses {
+			c.send(msg.ID, wsData, response)
+		}
+
+		c.stop(msg.ID)
+		if ctx.Err() == nil {
+			c.send(msg.ID, wsComplete, nil)
+		}
+	}()
+}
+
+// stop ends an operation, it completes once its responses stop
+func (c *wsConn) stop(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cancel, ok := c.operations[id]; ok {
+		cancel()
+		delete(c.operations, id)
+	}
+}
+
+func (c *wsConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.send("", wsConnectionKeepAlive, nil)
+		}
+	}
+}
+
+func (c *wsConn) send(id, messageType string, payload interface{}) {
+	msg := wsMessage{ID: id, Type: messageType}
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		msg.Payload = encoded
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Print(err)
+	}
+}
+
+// operationType is "query", "mutation" or "subscription" for the operation
+// in document that operationName picks, or the only one if it's empty. It's
+// empty if there's no such operation.
+func operationType(document, operationName string) string {
+	type operation struct{ kind, name string }
+	var operations []operation
+
+	// tokenized like graphql-go's lexer does, so strings and comments end
+	// where the parser that runs the operation thinks they do
+	sc := &scanner.Scanner{
+		Mode: scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings,
+	}
+	sc.Init(strings.NewReader(document))
+	sc.Error = func(*scanner.Scanner, string) {}
+
+	// header is set from an operation or fragment keyword until its
+	// selection set, variables, directives and type conditions in between
+	// aren't definitions. naming is set right after an operation keyword.
+	var depth, parens int
+	var header, naming bool
+	for tok := sc.Scan(); tok != scanner.EOF; tok = sc.Scan() {
+		if tok == ',' {
+			continue
+		}
+		if tok == '#' {
+			for r := sc.Peek(); r != '\r' && r != '\n' && r != scanner.EOF; r = sc.Peek() {
+				sc.Next()
+			}
+			continue
+		}
+
+		if naming {
+			naming = false
+			if tok == scanner.Ident {
+				operations[len(operations)-1].name = sc.TokenText()
+				continue
+			}
+		}
+
+		switch {
+		case tok == '(':
+			parens++
+		case tok == ')':
+			parens--
+		case parens > 0:
+		case tok == '{':
+			if depth == 0 {
+				if !header {
+					// the query shorthand, a selection set on its own
+					operations = append(operations, operation{kind: "query"})
+				}
+				header = false
+			}
+			depth++
+		case tok == '}':
+			depth--
+		case depth == 0 && !header && tok == scanner.Ident:
+			switch keyword := sc.TokenText(); keyword {
+			case "query", "mutation", "subscription":
+				operations = append(operations, operation{kind: keyword})
+				naming = true
+			}
+			header = true
+		}
+	}
+
+	if sc.ErrorCount > 0 {
+		return ""
+	}
+
+	var found string
+	var count int
+	for _, op := range operations {
+		if operationName == "" || op.name == operationName {
+			found = op.kind
+			count++
+		}
+	}
+
+	if count != 1 {
+		return ""
+	}
+
+	return found
+}
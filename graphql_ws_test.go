@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationType(t *testing.T) {
+	cases := []struct {
+		document      string
+		operationName string
+		expected      string
+	}{
+		{`subscription { notificationAdded { id } }`, "", "subscription"},
+		{`subscription{notificationAdded{id}}`, "", "subscription"},
+		{`{ currentUser { id } }`, "", "query"},
+		{`query { currentUser { id } }`, "", "query"},
+		{`mutation Send($input: SendMessageInput!) { sendMessage(input: $input) { message { id } } }`, "", "mutation"},
+		{`
+			# subscription
+			fragment user on User { id }
+			subscription Added($id: ID!) @live(if: {query: "mutation { x }"}) {
+				messageAdded(conversationID: $id) { from { ...user } }
+			}`, "", "subscription"},
+
+		// which operation is picked
+		{`subscription S { notificationAdded { id } } mutation M { logout }`, "S", "subscription"},
+		{`subscription S { notificationAdded { id } } mutation M { logout }`, "M", "mutation"},
+		{`subscription S { notificationAdded { id } } mutation M { logout }`, "", ""},
+		{`subscription S { notificationAdded { id } }`, "T", ""},
+
+		// keywords that aren't definitions
+		{`fragment f on query { id } mutation { logout }`, "", "mutation"},
+		{`subscription S($mutation: ID) { messageAdded(conversationID: $mutation) { body } }`, "", "subscription"},
+		{`subscription { messageAdded(conversationID: "1") { body } } "mutation { logout }"`, "", "subscription"},
+		{`""" mutation { logout } """ subscription { notificationAdded { id } }`, "", "subscription"},
+
+		// graphql-go reads """ in a query as "" and then a quote
+		{`fragment F on Mutation { updatePost(input: {id: "1", tags: ["""s2", """) } subscription S { notificationAdded { id } } fragment G on M { f(x: "]}) { id } } mutation S { ...F }`, "", "mutation"},
+		{`fragment F on Mutation { updatePost(input: {id: "1", tags: ["""s2", """) } subscription S { notificationAdded { id } } fragment G on M { f(x: "]}) { id } } mutation S { ...F }`, "S", "mutation"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, operationType(c.document, c.operationName), c.document)
+	}
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/graph-gophers/graphql-go/relay"
 	"github.com/lambdacollective/cobbles-api/gqlschema"
@@ -30,6 +33,7 @@ func main() {
 		w.Write(page)
 	}))
 	http.Handle("/graphql", authMiddleware(server, graphqlHandler))
+	http.Handle("/graphql/ws", authMiddleware(server, subscriptionHandler(server, schema)))
 
 	envPort := os.Getenv("PORT")
 	if envPort != "" {
@@ -42,48 +46,136 @@ func main() {
 
 func authMiddleware(s *server.Server, handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), "client_ip", clientIP(r)))
+
 		authHeader := r.Header.Get("authorization")
 
 		if authHeader != "" {
-			//Ash Commented Start
-			parts := strings.Split(authHeader, " ")
-			// log.Print(len(parts))
-			if !(len(parts) >= 2) {
-				http.Error(w, "authorization header invalid", http.StatusBadRequest)
-				return
-			}
-			token, err := s.ValidateAuthJWT(parts[1])
-			// End
-
-			// parts := strings.Split(authHeader, ".")
-			// log.Print(parts[1])
-			// log.Print(parts[0])
-			// if (len(parts[1]) != 3) {
-			// 	http.Error(w, "authorization header invalid 1", http.StatusBadRequest)
-			// 	return
-			// }
-			// log.Print(parts[1])
-			// //token, err := s.ValidateAuthJWT(authHeader)
-			// token, err := s.ValidateAuthJWT(parts[1])
-			if err != nil {
-				log.Print(err)
-				http.Error(w, "authorization header invalid", http.StatusBadRequest)
-				return
-			}
+			ctx, _, authErr := authenticate(r.Context(), s, authHeader)
+			if authErr != nil {
+				if authErr.restricted != nil {
+					writeAccountRestricted(w, authErr.restricted)
+					return
+				}
 
-			claims, ok := token.Claims.(*server.AuthJWTClaims)
-			if !ok {
-				log.Print(err)
+				http.Error(w, authErr.message, authErr.code)
 				return
 			}
-			authKey := "user_id"
-			r = r.WithContext(context.WithValue(r.Context(), authKey, claims.UserID))
+
+			r = r.WithContext(ctx)
 		}
 
 		handler.ServeHTTP(w, r)
 	})
 }
 
+// authError is why an access token was turned away, and the HTTP status to
+// turn it away with
+type authError struct {
+	code    int
+	message string
+
+	// restricted is the account status of a suspended or banned user, who's
+	// turned away with accountRestrictedError instead of message
+	restricted *server.AccountStatus
+}
+
+// authenticate checks the access token in an Authorization header value and
+// adds the user, session and roles it's for to ctx
+func authenticate(ctx context.Context, s *server.Server, authHeader string) (context.Context, *server.AuthJWTClaims, *authError) {
+	parts := strings.Split(authHeader, " ")
+	if !(len(parts) >= 2) {
+		return nil, nil, &authError{code: http.StatusBadRequest, message: "authorization header invalid"}
+	}
+
+	token, err := s.ValidateAuthJWT(parts[1])
+	if err != nil {
+		log.Print(err)
+		return nil, nil, &authError{code: http.StatusBadRequest, message: "authorization header invalid"}
+	}
+
+	claims, ok := token.Claims.(*server.AuthJWTClaims)
+	if !ok {
+		return nil, nil, &authError{code: http.StatusInternalServerError, message: "internal server error"}
+	}
+
+	// tokens from before sessions have no jti and can't be revoked
+	if claims.Id == "" {
+		return nil, nil, &authError{code: http.StatusUnauthorized, message: server.ErrSessionRevoked.Error()}
+	}
+
+	active, err := s.SessionActive(claims.UserID, claims.Id)
+	if err != nil {
+		log.Print(err)
+		return nil, nil, &authError{code: http.StatusInternalServerError, message: "internal server error"}
+	}
+	if !active {
+		return nil, nil, &authError{code: http.StatusUnauthorized, message: server.ErrSessionRevoked.Error()}
+	}
+
+	status, err := s.UserAccountStatus(claims.UserID)
+	if err != nil {
+		log.Print(err)
+		return nil, nil, &authError{code: http.StatusInternalServerError, message: "internal server error"}
+	}
+	if status.Restricted() {
+		return nil, nil, &authError{code: http.StatusForbidden, restricted: status}
+	}
+
+	authKey := "user_id"
+	ctx = context.WithValue(ctx, authKey, claims.UserID)
+	ctx = context.WithValue(ctx, "session_id", claims.Id)
+	ctx = context.WithValue(ctx, "roles", claims.Roles)
+	return ctx, claims, nil
+}
+
+// writeAccountRestricted turns away a suspended or banned user with a
+// GraphQL error response, so clients show it like any other error
+func writeAccountRestricted(w http.ResponseWriter, status *server.AccountStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{accountRestrictedError(status)},
+	})
+}
+
+// accountRestrictedError is the GraphQL error for a suspended or banned user
+func accountRestrictedError(status *server.AccountStatus) map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code":   "ACCOUNT_" + strings.ToUpper(string(status.Status)),
+		"reason": status.Reason,
+	}
+	if status.SuspendedUntil != nil {
+		extensions["suspendedUntil"] = status.SuspendedUntil.Format(time.RFC3339)
+	}
+
+	err := &server.AccountRestrictedError{
+		Status:         status.Status,
+		SuspendedUntil: status.SuspendedUntil,
+	}
+
+	return map[string]interface{}{
+		"message":    err.Error(),
+		"extensions": extensions,
+	}
+}
+
+// clientIP is the address the request came from. Heroku's router appends the
+// real client to X-Forwarded-For, so trust the last entry.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("x-forwarded-for"); forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		return strings.TrimSpace(parts[len(parts)-1])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
 var page = []byte(`
 <!DOCTYPE html>
 <html>
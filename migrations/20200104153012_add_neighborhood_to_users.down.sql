@@ -0,0 +1,4 @@
+drop index if exists posts_neighborhood_id_index;
+drop index if exists users_neighborhood_id_index;
+
+alter table users drop column neighborhood_id;
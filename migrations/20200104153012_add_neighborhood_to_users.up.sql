@@ -0,0 +1,4 @@
+alter table users add neighborhood_id bigint references neighborhoods (id);
+
+create index users_neighborhood_id_index on users (neighborhood_id);
+create index posts_neighborhood_id_index on posts (neighborhood_id);
@@ -0,0 +1,7 @@
+drop index if exists neighborhoods_zip_codes_index;
+drop index if exists neighborhoods_boundary_index;
+
+alter table neighborhoods drop column center_lng;
+alter table neighborhoods drop column center_lat;
+alter table neighborhoods drop column zip_codes;
+alter table neighborhoods drop column boundary;
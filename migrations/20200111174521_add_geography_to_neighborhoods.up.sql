@@ -0,0 +1,16 @@
+-- boundary points are (lng, lat) so that point(lng, lat) can be tested with @>
+alter table neighborhoods add boundary polygon;
+alter table neighborhoods add zip_codes text[] default '{}' not null;
+alter table neighborhoods add center_lat double precision;
+alter table neighborhoods add center_lng double precision;
+
+create index neighborhoods_boundary_index on neighborhoods using gist (boundary);
+create index neighborhoods_zip_codes_index on neighborhoods using gin (zip_codes);
+
+update neighborhoods
+set zip_codes = '{02127}',
+	center_lat = 42.3334,
+	center_lng = -71.0471,
+	boundary = polygon '((-71.0660,42.3445),(-71.0220,42.3445),(-71.0220,42.3215),(-71.0660,42.3215))',
+	updated_at = now()
+where slug = 'southie';
@@ -0,0 +1,4 @@
+drop index if exists posts_location_index;
+
+alter table posts drop column lng;
+alter table posts drop column lat;
@@ -0,0 +1,8 @@
+create extension if not exists cube;
+create extension if not exists earthdistance;
+
+alter table posts add lat double precision;
+alter table posts add lng double precision;
+
+create index posts_location_index on posts using gist (ll_to_earth(lat, lng))
+	where lat is not null and lng is not null;
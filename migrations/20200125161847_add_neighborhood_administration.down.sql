@@ -0,0 +1,4 @@
+drop index if exists neighborhoods_slug_uindex;
+
+alter table neighborhoods drop column merged_into_id;
+alter table neighborhoods drop column retired_at;
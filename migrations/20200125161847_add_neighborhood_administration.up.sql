@@ -0,0 +1,4 @@
+alter table neighborhoods add retired_at timestamp with time zone;
+alter table neighborhoods add merged_into_id bigint references neighborhoods (id);
+
+create unique index neighborhoods_slug_uindex on neighborhoods (lower(slug));
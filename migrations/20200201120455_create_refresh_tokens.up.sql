@@ -0,0 +1,19 @@
+create table refresh_tokens (
+	id bigserial not null
+		constraint refresh_tokens_pkey
+			primary key,
+	user_id bigint not null references users (id) on delete cascade,
+	-- device_id is picked by the client, logging in again on the same device
+	-- revokes that device's previous refresh token
+	device_id text,
+	-- sha256 of the token, the token itself is only ever held by the client
+	token_hash bytea not null,
+	expires_at timestamp with time zone not null,
+	revoked_at timestamp with time zone,
+	replaced_by_id bigint references refresh_tokens (id),
+	created_at timestamp with time zone not null,
+	last_used_at timestamp with time zone
+);
+
+create unique index refresh_tokens_token_hash_uindex on refresh_tokens (token_hash);
+create index refresh_tokens_user_id_device_id_index on refresh_tokens (user_id, device_id);
@@ -0,0 +1,7 @@
+delete from refresh_tokens;
+
+alter table refresh_tokens drop session_id;
+alter table refresh_tokens add device_id text;
+create index refresh_tokens_user_id_device_id_index on refresh_tokens (user_id, device_id);
+
+drop table if exists sessions;
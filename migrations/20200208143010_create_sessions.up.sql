@@ -0,0 +1,19 @@
+-- one row per logged in device, its id is the jti of that device's access
+-- tokens
+create table sessions (
+	id uuid primary key,
+	user_id bigint not null references users (id) on delete cascade,
+	device_id text,
+	created_at timestamp with time zone not null,
+	last_seen_at timestamp with time zone not null,
+	revoked_at timestamp with time zone
+);
+
+create index sessions_user_id_index on sessions (user_id) where revoked_at is null;
+
+-- refresh tokens from before sessions can't be tied to one, so those devices
+-- log in again
+delete from refresh_tokens;
+
+alter table refresh_tokens add session_id uuid not null references sessions (id) on delete cascade;
+alter table refresh_tokens drop device_id;
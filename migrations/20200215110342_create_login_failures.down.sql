@@ -0,0 +1,3 @@
+drop table if exists login_failures;
+
+alter table login_codes drop failed_attempts;
@@ -0,0 +1,12 @@
+alter table login_codes add failed_attempts integer default 0 not null;
+
+-- failed loginUser attempts per phone number and per client IP
+create table login_failures (
+	kind text not null,
+	key text not null,
+	failures integer not null,
+	last_failed_at timestamp with time zone not null,
+	locked_until timestamp with time zone,
+	constraint login_failures_pkey
+		primary key (kind, key)
+);
@@ -0,0 +1,5 @@
+drop table if exists webauthn_challenges;
+drop table if exists passkeys;
+drop table if exists email_login_tokens;
+
+alter table users drop email;
@@ -0,0 +1,47 @@
+-- emails are stored lowercased
+alter table users add email text;
+create unique index users_email_uindex on users (email);
+
+create table email_login_tokens (
+	id bigserial not null
+		constraint email_login_tokens_pkey
+			primary key,
+	email text not null,
+	-- set when a logged in user asked for the link, to add the email to them
+	user_id bigint references users (id) on delete cascade,
+	-- sha256 of the token, the token itself is only in the emailed link
+	token_hash bytea not null,
+	expires_at timestamp with time zone not null,
+	used_at timestamp with time zone,
+	created_at timestamp with time zone not null
+);
+
+create unique index email_login_tokens_token_hash_uindex on email_login_tokens (token_hash);
+create index email_login_tokens_email_index on email_login_tokens (email);
+
+create table passkeys (
+	id bigserial not null
+		constraint passkeys_pkey
+			primary key,
+	user_id bigint not null references users (id) on delete cascade,
+	name text,
+	credential_id bytea not null,
+	-- uncompressed P-256 point, passkeys are ES256 only
+	public_key bytea not null,
+	sign_count bigint default 0 not null,
+	created_at timestamp with time zone not null,
+	last_used_at timestamp with time zone
+);
+
+create unique index passkeys_credential_id_uindex on passkeys (credential_id);
+create index passkeys_user_id_index on passkeys (user_id);
+
+create table webauthn_challenges (
+	id uuid primary key,
+	-- registration challenges belong to the user adding a passkey
+	user_id bigint references users (id) on delete cascade,
+	kind text not null,
+	challenge bytea not null,
+	expires_at timestamp with time zone not null,
+	created_at timestamp with time zone not null
+);
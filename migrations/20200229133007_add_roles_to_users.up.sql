@@ -0,0 +1 @@
+alter table users add roles text[] default '{}' not null;
@@ -0,0 +1,10 @@
+alter table users drop suspended_until;
+
+alter table posts drop hidden;
+
+drop index reported_posts_open_post_id_idx;
+
+alter table reported_posts drop action_taken_at;
+alter table reported_posts drop action_taken_by_id;
+alter table reported_posts alter action_taken type bigint using null;
+alter table reported_posts drop reason;
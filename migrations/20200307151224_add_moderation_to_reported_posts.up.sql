@@ -0,0 +1,26 @@
+-- reported_posts used to only be created by GORM
+create table if not exists reported_posts (
+  id serial primary key,
+  created_at timestamp with time zone,
+  updated_at timestamp with time zone,
+  deleted_at timestamp with time zone,
+  post_id integer,
+  person_reporting_id integer,
+  reporting_reason_text text,
+  action_taken bigint
+);
+
+create index if not exists idx_reported_posts_deleted_at on reported_posts (deleted_at);
+
+alter table reported_posts add reason text default 'other' not null;
+
+-- numbered actions were never given a meaning
+alter table reported_posts alter action_taken type text using null;
+alter table reported_posts add action_taken_by_id bigint references users (id);
+alter table reported_posts add action_taken_at timestamp with time zone;
+
+create index reported_posts_open_post_id_idx on reported_posts (post_id) where action_taken is null;
+
+alter table posts add hidden bool default false not null;
+
+alter table users add suspended_until timestamp with time zone;
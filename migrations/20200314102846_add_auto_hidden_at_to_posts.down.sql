@@ -0,0 +1,3 @@
+drop index reported_posts_person_reporting_id_idx;
+
+alter table posts drop auto_hidden_at;
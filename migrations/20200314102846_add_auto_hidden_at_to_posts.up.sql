@@ -0,0 +1,4 @@
+-- set while a post is hidden by reports and waiting for a moderator
+alter table posts add auto_hidden_at timestamp with time zone;
+
+create index reported_posts_person_reporting_id_idx on reported_posts (person_reporting_id);
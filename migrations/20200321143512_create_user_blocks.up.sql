@@ -0,0 +1,10 @@
+-- kind is block or mute, blocking someone you muted replaces the mute
+create table user_blocks (
+  user_id bigint not null references users (id) on delete cascade,
+  blocked_user_id bigint not null references users (id) on delete cascade,
+  kind text not null,
+  created_at timestamp with time zone not null default now(),
+  primary key (user_id, blocked_user_id)
+);
+
+create index user_blocks_blocked_user_id_idx on user_blocks (blocked_user_id);
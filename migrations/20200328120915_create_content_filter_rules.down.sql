@@ -0,0 +1,5 @@
+alter table messages drop hidden;
+
+drop table content_flags;
+
+drop table content_filter_rules;
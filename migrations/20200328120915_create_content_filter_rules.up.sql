@@ -0,0 +1,28 @@
+create table content_filter_rules (
+  id bigserial primary key,
+  pattern text not null,
+  verdict text not null,
+  -- empty applies to every kind of content
+  kinds text[] default '{}' not null,
+  reason text,
+  enabled boolean default true not null,
+  created_by_id bigint references users (id),
+  created_at timestamp with time zone default now() not null,
+  updated_at timestamp with time zone default now() not null
+);
+
+-- content a filter flagged or shadow hid, for moderators
+create table content_flags (
+  id bigserial primary key,
+  kind text not null,
+  content_id bigint not null,
+  user_id bigint not null references users (id) on delete cascade,
+  text text not null,
+  verdict text not null,
+  reason text,
+  created_at timestamp with time zone default now() not null
+);
+
+create index content_flags_kind_content_id_idx on content_flags (kind, content_id);
+
+alter table messages add hidden boolean default false not null;
@@ -0,0 +1,4 @@
+alter table users drop status_changed_at;
+alter table users drop status_changed_by_id;
+alter table users drop status_reason;
+alter table users drop status;
@@ -0,0 +1,7 @@
+alter table users add status text not null default 'active';
+alter table users add status_reason text;
+alter table users add status_changed_by_id bigint references users (id) on delete set null;
+alter table users add status_changed_at timestamp with time zone;
+
+-- suspensions handed out by moderating reported posts
+update users set status = 'suspended' where suspended_until > now();
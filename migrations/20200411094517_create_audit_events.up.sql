@@ -0,0 +1,27 @@
+-- audit_events is append only. actor_id is null for actions the server took
+-- itself, like hiding a post after reports, and isn't a foreign key so events
+-- outlive their actor.
+create table audit_events (
+  id bigserial primary key,
+  actor_id bigint,
+  action text not null,
+  target_type text not null,
+  target_id bigint not null,
+  before jsonb,
+  after jsonb,
+  created_at timestamp with time zone not null default now()
+);
+
+create index audit_events_target_idx on audit_events (target_type, target_id, id);
+create index audit_events_actor_id_idx on audit_events (actor_id, id);
+create index audit_events_action_idx on audit_events (action, id);
+
+create function audit_events_append_only() returns trigger as $$
+begin
+  raise exception 'audit_events is append only';
+end;
+$$ language plpgsql;
+
+create trigger audit_events_append_only
+  before update or delete on audit_events
+  for each row execute procedure audit_events_append_only();
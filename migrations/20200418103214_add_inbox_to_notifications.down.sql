@@ -0,0 +1,12 @@
+drop index notifications_unread_idx;
+drop index notifications_user_id_idx;
+
+alter table notifications
+  alter column updated_at drop not null,
+  alter column updated_at drop default,
+  alter column created_at drop not null,
+  alter column created_at drop default,
+  alter column read drop not null,
+  alter column data drop not null,
+  drop constraint notifications_user_id_fkey,
+  alter column user_id drop not null;
@@ -0,0 +1,18 @@
+delete from notifications where user_id is null;
+update notifications set read = false where read is null;
+update notifications set created_at = now() where created_at is null;
+update notifications set updated_at = created_at where updated_at is null;
+update notifications set data = '{}' where data is null;
+
+alter table notifications
+  alter column user_id set not null,
+  add constraint notifications_user_id_fkey foreign key (user_id) references users (id) on delete cascade,
+  alter column data set not null,
+  alter column read set not null,
+  alter column created_at set default now(),
+  alter column created_at set not null,
+  alter column updated_at set default now(),
+  alter column updated_at set not null;
+
+create index notifications_user_id_idx on notifications (user_id, id);
+create index notifications_unread_idx on notifications (user_id) where read is false;
@@ -0,0 +1,20 @@
+alter table notifications add data jsonb;
+
+update notifications
+set data = jsonb_strip_nulls(jsonb_build_object(
+  'type', kind,
+  'content', content,
+  'actorID', actor_id,
+  'postID', post_id,
+  'commentID', comment_id,
+  'conversationID', conversation_id
+));
+
+alter table notifications
+  alter column data set not null,
+  drop column conversation_id,
+  drop column comment_id,
+  drop column post_id,
+  drop column actor_id,
+  drop column content,
+  drop column kind;
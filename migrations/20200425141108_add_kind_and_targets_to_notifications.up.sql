@@ -0,0 +1,22 @@
+alter table notifications
+  add kind text,
+  add content text,
+  add actor_id bigint references users (id) on delete set null,
+  add post_id bigint,
+  add comment_id bigint,
+  add conversation_id bigint;
+
+update notifications
+set kind = data->>'type',
+  content = coalesce(data->>'content', ''),
+  actor_id = (select id from users where id = (data->>'actorID')::bigint),
+  post_id = (data->>'postID')::bigint,
+  comment_id = (data->>'commentID')::bigint,
+  conversation_id = (data->>'conversationID')::bigint;
+
+delete from notifications where kind is null;
+
+alter table notifications
+  alter column kind set not null,
+  alter column content set not null,
+  drop column data;
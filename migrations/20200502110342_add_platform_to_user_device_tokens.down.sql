@@ -0,0 +1,17 @@
+drop index user_device_tokens_user_id_idx;
+drop index user_device_tokens_device_token_idx;
+
+alter table user_device_tokens
+  add endpoint_arn text,
+  alter column updated_at drop default,
+  alter column updated_at drop not null,
+  alter column created_at drop default,
+  alter column created_at drop not null,
+  alter column enabled drop default,
+  alter column enabled drop not null,
+  alter column device_token drop not null,
+  drop constraint user_device_tokens_user_id_fkey,
+  alter column user_id drop not null,
+  drop column disabled_reason,
+  drop column device_id,
+  drop column platform;
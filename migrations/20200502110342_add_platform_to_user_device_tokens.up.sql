@@ -0,0 +1,43 @@
+-- user_device_tokens holds every device a user gets pushes on. Devices were
+-- SNS endpoints before, with a phone's FCM token kept in users.fcm_token too.
+delete from user_device_tokens where user_id is null or device_token is null;
+
+alter table user_device_tokens
+  add platform text,
+  add device_id text,
+  add disabled_reason text;
+
+update user_device_tokens
+set platform = case when endpoint_arn like '%/APNS%' then 'apns' else 'fcm' end;
+
+insert into user_device_tokens (user_id, device_token, platform, enabled, created_at, updated_at)
+select id, fcm_token, 'fcm', true, now(), now()
+from users
+where fcm_token is not null
+  and fcm_token <> '';
+
+-- a token belongs to one device, keep the newest row for it
+delete from user_device_tokens t
+using user_device_tokens newer
+where newer.device_token = t.device_token
+  and newer.id > t.id;
+
+update user_device_tokens set enabled = true where enabled is null;
+update user_device_tokens set created_at = now() where created_at is null;
+update user_device_tokens set updated_at = created_at where updated_at is null;
+
+alter table user_device_tokens
+  alter column user_id set not null,
+  add constraint user_device_tokens_user_id_fkey foreign key (user_id) references users (id) on delete cascade,
+  alter column device_token set not null,
+  alter column platform set not null,
+  alter column enabled set not null,
+  alter column enabled set default true,
+  alter column created_at set not null,
+  alter column created_at set default now(),
+  alter column updated_at set not null,
+  alter column updated_at set default now(),
+  drop column endpoint_arn;
+
+create unique index user_device_tokens_device_token_idx on user_device_tokens (device_token);
+create index user_device_tokens_user_id_idx on user_device_tokens (user_id) where enabled;
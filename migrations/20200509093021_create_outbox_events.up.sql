@@ -0,0 +1,18 @@
+-- outbox_events are side effects, like pushes, written in the transaction of
+-- the change causing them and carried out by cobbles-worker. Events are
+-- pending until they're done, or dead once they've failed too many times.
+create table outbox_events (
+  id bigserial primary key,
+  kind text not null,
+  payload jsonb not null,
+  status text not null default 'pending',
+  attempts integer not null default 0,
+  next_attempt_at timestamp with time zone not null default now(),
+  last_error text,
+  processed_at timestamp with time zone,
+  created_at timestamp with time zone not null default now(),
+  updated_at timestamp with time zone not null default now()
+);
+
+create index outbox_events_due_idx on outbox_events (next_attempt_at) where status = 'pending';
+create index outbox_events_dead_idx on outbox_events (id) where status = 'dead';
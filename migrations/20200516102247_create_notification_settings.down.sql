@@ -0,0 +1 @@
+drop table notification_settings;
@@ -0,0 +1,19 @@
+-- notification_settings are a user's notification preferences, users without
+-- a row have the defaults. Quiet hours are minutes after midnight in
+-- time_zone and may wrap past midnight.
+create table notification_settings (
+  user_id bigint primary key references users (id) on delete cascade,
+  messages boolean not null default true,
+  comments boolean not null default true,
+  likes boolean not null default true,
+  follows boolean not null default true,
+  neighborhood_posts boolean not null default false,
+  quiet_hours_start smallint,
+  quiet_hours_end smallint,
+  time_zone text not null default 'UTC',
+  created_at timestamp with time zone not null default now(),
+  updated_at timestamp with time zone not null default now(),
+  check ((quiet_hours_start is null) = (quiet_hours_end is null)),
+  check (quiet_hours_start between 0 and 1439),
+  check (quiet_hours_end between 0 and 1439)
+);
@@ -0,0 +1,9 @@
+alter table notification_settings drop column last_digest_at;
+
+drop index outbox_events_dedupe_key_idx;
+alter table outbox_events
+  drop column version,
+  drop column dedupe_key;
+
+drop index notifications_coalesce_idx;
+alter table notifications drop column actor_count;
@@ -0,0 +1,14 @@
+-- actor_count is how many users' likes or comments on a post were folded
+-- into one notification
+alter table notifications add actor_count integer not null default 1;
+create index notifications_coalesce_idx on notifications (user_id, kind, post_id, id) where read is false;
+
+-- a pending event with the same dedupe_key is updated rather than queued
+-- twice, version counts the updates so a worker doesn't finish an event that
+-- changed while it was sending
+alter table outbox_events
+  add dedupe_key text,
+  add version integer not null default 0;
+create unique index outbox_events_dedupe_key_idx on outbox_events (dedupe_key) where status = 'pending';
+
+alter table notification_settings add last_digest_at timestamp with time zone;
@@ -0,0 +1 @@
+alter table notifications drop column actor_ids;
@@ -0,0 +1,4 @@
+-- actor_ids is every user folded into a coalesced notification, so someone
+-- liking again after others did isn't counted twice
+alter table notifications add actor_ids bigint[] not null default '{}';
+update notifications set actor_ids = array[actor_id] where actor_id is not null;
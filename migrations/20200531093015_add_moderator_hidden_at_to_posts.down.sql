@@ -0,0 +1 @@
+alter table posts drop moderator_hidden_at;
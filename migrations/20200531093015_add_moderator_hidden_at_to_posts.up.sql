@@ -0,0 +1,3 @@
+-- set while a post is hidden by a moderator, so dismissing its reports later
+-- shows it again
+alter table posts add moderator_hidden_at timestamp with time zone;
@@ -0,0 +1,123 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/jackc/pgx"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// SuspendUser - admins only, locks a user out for a number of days
+func (r *Resolver) SuspendUser(ctx context.Context, args struct {
+	Input struct {
+		UserID graphql.ID
+		Days   int32
+		Reason string
+	}
+}) (*AccountStatusResolver, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.ParseInt(string(args.Input.UserID), 10, 64)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if args.Input.Days > server.MaxSuspensionDays {
+		return nil, fmt.Errorf("days must be at most %d, ban the user instead", server.MaxSuspensionDays)
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.server.UserAccountStatusForUpdate(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(args.Input.Days) * 24 * time.Hour
+	status, err := r.server.SuspendUser(tx, adminID, userID, duration, args.Input.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.audit(tx, adminID, server.AuditActionSuspendUser, server.AuditTargetUser, userID, before, status); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &AccountStatusResolver{server: r.server, status: status}, nil
+}
+
+type changeUserStatusArgs struct {
+	Input struct {
+		UserID graphql.ID
+		Reason string
+	}
+}
+
+// BanUser - admins only, locks a user out until they're reinstated
+func (r *Resolver) BanUser(ctx context.Context, args changeUserStatusArgs) (*AccountStatusResolver, error) {
+	return r.changeUserStatus(ctx, args, server.AuditActionBanUser, r.server.BanUser)
+}
+
+// ReinstateUser - admins only, lifts a suspension or ban
+func (r *Resolver) ReinstateUser(ctx context.Context, args changeUserStatusArgs) (*AccountStatusResolver, error) {
+	return r.changeUserStatus(ctx, args, server.AuditActionReinstateUser, r.server.ReinstateUser)
+}
+
+func (r *Resolver) changeUserStatus(
+	ctx context.Context,
+	args changeUserStatusArgs,
+	action server.AuditAction,
+	change func(tx *pgx.Tx, adminID, userID int64, reason string) (*server.AccountStatus, error),
+) (*AccountStatusResolver, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.ParseInt(string(args.Input.UserID), 10, 64)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.server.UserAccountStatusForUpdate(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := change(tx, adminID, userID, args.Input.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.audit(tx, adminID, action, server.AuditTargetUser, userID, before, status); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &AccountStatusResolver{server: r.server, status: status}, nil
+}
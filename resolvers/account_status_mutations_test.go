@@ -0,0 +1,219 @@
+package resolvers
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountStatus(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		adminID  int64 = 1
+		userID   int64 = 2
+		viewerID int64 = 3
+	)
+	for _, id := range []int64{adminID, userID, viewerID} {
+		harness.MustCreateUser(id)
+	}
+	_, err := connPool.Exec(`update users set roles = '{admin}' where id = $1`, adminID)
+	require.NoError(t, err)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: `
+			mutation {
+				createPost(input: {title: "hello", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: fmt.Sprintf(`
+			mutation {
+				createPostComment(input: {postID: %s, comment: "first"})
+			}`, post.CreatePost.ID),
+	}, nil)
+
+	commentCount := func() int {
+		var res struct {
+			PostComments struct {
+				Comments []struct {
+					Comment string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: viewerID,
+			Query: fmt.Sprintf(`
+			{
+				postComments(input: {postID: %s}) {
+					comments {
+						comment
+					}
+				}
+			}`, post.CreatePost.ID),
+		}, &res)
+		return len(res.PostComments.Comments)
+	}
+
+	accountStatus := func(viewerID int64) map[string]interface{} {
+		var res map[string]interface{}
+		harness.MustExec(ExecInput{
+			UserID: viewerID,
+			Query: fmt.Sprintf(`
+			{
+				otherUser(id: %d) {
+					accountStatus {
+						status
+						reason
+					}
+				}
+			}`, userID),
+		}, &res)
+		return res
+	}
+
+	t.Run("only admins change statuses", func(t *testing.T) {
+		errs := harness.Exec(ExecInput{
+			UserID: viewerID,
+			Query: fmt.Sprintf(`
+				mutation {
+					banUser(input: {userID: %d, reason: "spam"}) {
+						status
+					}
+				}`, userID),
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "unauthorized", errs[0].Message)
+
+		errs = harness.Exec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					banUser(input: {userID: %d, reason: ""}) {
+						status
+					}
+				}`, userID),
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "a reason is required", errs[0].Message)
+	})
+
+	t.Run("suspensions are at most ten years", func(t *testing.T) {
+		for _, days := range []int32{server.MaxSuspensionDays + 1, math.MaxInt32} {
+			errs := harness.Exec(ExecInput{
+				UserID: adminID,
+				Query: fmt.Sprintf(`
+					mutation {
+						suspendUser(input: {userID: %d, days: %d, reason: "harassment"}) {
+							status
+						}
+					}`, userID, days),
+			}, nil)
+			require.Len(t, errs, 1)
+			require.Equal(t, "days must be at most 3650, ban the user instead", errs[0].Message)
+		}
+
+		require.NoError(t, harness.resolver.server.CheckAccountActive(userID))
+	})
+
+	t.Run("suspended users are locked out and their content hidden", func(t *testing.T) {
+		var res struct {
+			SuspendUser struct {
+				Status         string
+				SuspendedUntil *string
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					suspendUser(input: {userID: %d, days: 3, reason: "harassment"}) {
+						status
+						suspendedUntil
+					}
+				}`, userID),
+		}, &res)
+		require.Equal(t, "SUSPENDED", res.SuspendUser.Status)
+		require.NotNil(t, res.SuspendUser.SuspendedUntil)
+
+		require.Empty(t, postTitles(harness, viewerID, userID))
+		require.Equal(t, 0, commentCount())
+
+		err := harness.resolver.server.CheckAccountActive(userID)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "your account is suspended until")
+
+		require.Equal(t, map[string]interface{}{
+			"otherUser": map[string]interface{}{"accountStatus": nil},
+		}, accountStatus(viewerID))
+		require.Equal(t, map[string]interface{}{
+			"otherUser": map[string]interface{}{
+				"accountStatus": map[string]interface{}{"status": "SUSPENDED", "reason": "harassment"},
+			},
+		}, accountStatus(adminID))
+	})
+
+	t.Run("expired suspensions lapse", func(t *testing.T) {
+		_, err := connPool.Exec(`update users set suspended_until = now() - interval '1 minute' where id = $1`, userID)
+		require.NoError(t, err)
+
+		require.NoError(t, harness.resolver.server.CheckAccountActive(userID))
+		require.Equal(t, []string{"hello"}, postTitles(harness, viewerID, userID))
+	})
+
+	t.Run("bans last until the user is reinstated", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					banUser(input: {userID: %d, reason: "ban evasion"}) {
+						status
+					}
+				}`, userID),
+		}, nil)
+
+		err := harness.resolver.server.CheckAccountActive(userID)
+		require.Error(t, err)
+		require.Equal(t, "your account has been banned", err.Error())
+		require.Empty(t, postTitles(harness, viewerID, userID))
+
+		errs := harness.Exec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					suspendUser(input: {userID: %d, days: 1, reason: "harassment"}) {
+						status
+					}
+				}`, userID),
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "user is banned", errs[0].Message)
+
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					reinstateUser(input: {userID: %d, reason: "appeal accepted"}) {
+						status
+					}
+				}`, userID),
+		}, nil)
+
+		require.NoError(t, harness.resolver.server.CheckAccountActive(userID))
+		require.Equal(t, []string{"hello"}, postTitles(harness, viewerID, userID))
+		require.Equal(t, 1, commentCount())
+	})
+}
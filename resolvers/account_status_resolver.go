@@ -0,0 +1,51 @@
+package resolvers
+
+import (
+	"strings"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type AccountStatusResolver struct {
+	server *server.Server
+
+	status *server.AccountStatus
+}
+
+func (r *AccountStatusResolver) Status() string {
+	return strings.ToUpper(string(r.status.Status))
+}
+
+func (r *AccountStatusResolver) SuspendedUntil() *Timestamp {
+	if r.status.SuspendedUntil == nil {
+		return nil
+	}
+
+	return &Timestamp{*r.status.SuspendedUntil}
+}
+
+func (r *AccountStatusResolver) Reason() *string {
+	return r.status.Reason
+}
+
+// ChangedBy is the admin or moderator who last changed the status
+func (r *AccountStatusResolver) ChangedBy() (*UserResolver, error) {
+	if r.status.ChangedByID == nil {
+		return nil, nil
+	}
+
+	user, err := r.server.UserByID(*r.status.ChangedByID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserResolver{server: r.server, user: user}, nil
+}
+
+func (r *AccountStatusResolver) ChangedAt() *Timestamp {
+	if r.status.ChangedAt == nil {
+		return nil
+	}
+
+	return &Timestamp{*r.status.ChangedAt}
+}
@@ -0,0 +1,66 @@
+package resolvers
+
+import (
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type AuditEventResolver struct {
+	server *server.Server
+
+	event *server.AuditEvent
+}
+
+func (r *AuditEventResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.event.ID, 10))
+}
+
+// Actor is nil for actions the server took itself
+func (r *AuditEventResolver) Actor() (*UserResolver, error) {
+	if r.event.ActorID == nil {
+		return nil, nil
+	}
+
+	user, err := r.server.UserByID(*r.event.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserResolver{server: r.server, user: user}, nil
+}
+
+func (r *AuditEventResolver) Action() string {
+	return strings.ToUpper(string(r.event.Action))
+}
+
+func (r *AuditEventResolver) TargetType() string {
+	return strings.ToUpper(string(r.event.TargetType))
+}
+
+func (r *AuditEventResolver) TargetID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.event.TargetID, 10))
+}
+
+func (r *AuditEventResolver) Before() *string {
+	return rawJSON(r.event.Before)
+}
+
+func (r *AuditEventResolver) After() *string {
+	return rawJSON(r.event.After)
+}
+
+func (r *AuditEventResolver) CreatedAt() Timestamp {
+	return Timestamp{r.event.CreatedAt}
+}
+
+func rawJSON(b []byte) *string {
+	if b == nil {
+		return nil
+	}
+
+	s := string(b)
+	return &s
+}
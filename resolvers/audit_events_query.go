@@ -0,0 +1,109 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type AuditEventsInput struct {
+	ActorID    *graphql.ID
+	Action     *string
+	TargetType *string
+	TargetID   *graphql.ID
+	Since      *Timestamp
+	PageToken  *string
+	Limit      *int32
+}
+
+type AuditEventsResult struct {
+	events        []*AuditEventResolver
+	nextPageToken *string
+}
+
+// AuditEvents - admins only, the audit log newest first
+func (r *Resolver) AuditEvents(ctx context.Context, args struct {
+	Input *AuditEventsInput
+}) (*AuditEventsResult, error) {
+	if _, err := requireRole(ctx, server.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	in := args.Input
+	if in == nil {
+		in = &AuditEventsInput{}
+	}
+
+	var filter server.AuditEventFilter
+	if in.ActorID != nil {
+		id, err := strconv.ParseInt(string(*in.ActorID), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid actorID")
+		}
+		filter.ActorID = &id
+	}
+	if in.TargetID != nil {
+		id, err := strconv.ParseInt(string(*in.TargetID), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid targetID")
+		}
+		filter.TargetID = &id
+	}
+	if in.Action != nil {
+		action := server.AuditAction(strings.ToLower(*in.Action))
+		filter.Action = &action
+	}
+	if in.TargetType != nil {
+		targetType := server.AuditTargetType(strings.ToLower(*in.TargetType))
+		filter.TargetType = &targetType
+	}
+	if in.Since != nil {
+		filter.Since = &in.Since.Time
+	}
+
+	var limit int32 = 100
+	if in.Limit != nil && *in.Limit > 0 && *in.Limit < limit {
+		limit = *in.Limit
+	}
+
+	beforeID, err := DecodeAfterIDCursor(in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := r.server.AuditEvents(filter, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextPageToken *string
+	if int32(len(events)) > limit {
+		events = events[:limit]
+		nextPageToken = EncodeAfterIDCursor(events[limit-1].ID)
+	}
+
+	result := &AuditEventsResult{
+		events:        []*AuditEventResolver{},
+		nextPageToken: nextPageToken,
+	}
+	for i := range events {
+		result.events = append(result.events, &AuditEventResolver{
+			server: r.server,
+			event:  &events[i],
+		})
+	}
+
+	return result, nil
+}
+
+func (r *AuditEventsResult) AuditEvents() []*AuditEventResolver {
+	return r.events
+}
+
+func (r *AuditEventsResult) NextPageToken() *string {
+	return r.nextPageToken
+}
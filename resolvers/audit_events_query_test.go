@@ -0,0 +1,174 @@
+package resolvers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditEvents(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		authorID    int64 = 1
+		moderatorID int64 = 2
+		adminID     int64 = 3
+	)
+	for _, id := range []int64{authorID, moderatorID, adminID} {
+		harness.MustCreateUser(id)
+	}
+	_, err := connPool.Exec(`update users set roles = '{admin}' where id = $1`, adminID)
+	require.NoError(t, err)
+
+	harness.MustExec(ExecInput{
+		UserID: adminID,
+		Query: fmt.Sprintf(`
+			mutation {
+				setUserRoles(input: {userID: %d, roles: [MODERATOR]})
+			}`, moderatorID),
+	}, nil)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: authorID,
+		Query: `
+			mutation {
+				createPost(input: {title: "disputed", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	harness.MustExec(ExecInput{
+		UserID: moderatorID,
+		Query: fmt.Sprintf(`
+			mutation {
+				moderatePost(input: {postID: %q, action: REMOVE_POST}) {
+					id
+				}
+			}`, post.CreatePost.ID),
+	}, nil)
+
+	type auditEvents struct {
+		AuditEvents struct {
+			AuditEvents []struct {
+				Actor *struct {
+					ID string
+				}
+				Action     string
+				TargetType string
+				TargetID   string
+				Before     *string
+				After      *string
+			}
+			NextPageToken *string
+		}
+	}
+
+	t.Run("answers who removed a post", func(t *testing.T) {
+		var res auditEvents
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+			{
+				auditEvents(input: {targetType: POST, targetID: %q}) {
+					auditEvents {
+						actor {
+							id
+						}
+						action
+						targetType
+						targetID
+						before
+						after
+					}
+				}
+			}`, post.CreatePost.ID),
+		}, &res)
+
+		events := res.AuditEvents.AuditEvents
+		require.Len(t, events, 1)
+		require.Equal(t, fmt.Sprint(moderatorID), events[0].Actor.ID)
+		require.Equal(t, "REMOVE_POST", events[0].Action)
+		require.Equal(t, post.CreatePost.ID, events[0].TargetID)
+
+		var before, after struct {
+			Removed bool
+		}
+		require.NoError(t, json.Unmarshal([]byte(*events[0].Before), &before))
+		require.NoError(t, json.Unmarshal([]byte(*events[0].After), &after))
+		require.False(t, before.Removed)
+		require.True(t, after.Removed)
+	})
+
+	t.Run("filters and paginates", func(t *testing.T) {
+		var res auditEvents
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+			{
+				auditEvents(input: {actorID: %d, limit: 1}) {
+					auditEvents {
+						action
+						targetID
+						after
+					}
+					nextPageToken
+				}
+			}`, adminID),
+		}, &res)
+
+		events := res.AuditEvents.AuditEvents
+		require.Len(t, events, 1)
+		require.Equal(t, "SET_USER_ROLES", events[0].Action)
+		require.Equal(t, fmt.Sprint(moderatorID), events[0].TargetID)
+		require.JSONEq(t, `{"roles": ["moderator"]}`, *events[0].After)
+		require.Nil(t, res.AuditEvents.NextPageToken)
+
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query:  `{ auditEvents(input: {limit: 1}) { auditEvents { action } nextPageToken } }`,
+		}, &res)
+		require.Equal(t, "REMOVE_POST", res.AuditEvents.AuditEvents[0].Action)
+		require.NotNil(t, res.AuditEvents.NextPageToken)
+
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+			{
+				auditEvents(input: {limit: 1, pageToken: %q}) {
+					auditEvents {
+						action
+					}
+					nextPageToken
+				}
+			}`, *res.AuditEvents.NextPageToken),
+		}, &res)
+		require.Equal(t, "SET_USER_ROLES", res.AuditEvents.AuditEvents[0].Action)
+		require.Nil(t, res.AuditEvents.NextPageToken)
+	})
+
+	t.Run("only admins read the log", func(t *testing.T) {
+		errs := harness.Exec(ExecInput{
+			UserID: moderatorID,
+			Query:  `{ auditEvents { auditEvents { action } } }`,
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "unauthorized", errs[0].Message)
+	})
+
+	t.Run("is append only", func(t *testing.T) {
+		_, err := connPool.Exec(`update audit_events set actor_id = null`)
+		require.Error(t, err)
+
+		_, err = connPool.Exec(`delete from audit_events`)
+		require.Error(t, err)
+	})
+}
@@ -3,66 +3,118 @@ package resolvers
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/jackc/pgx"
 	"github.com/lambdacollective/cobbles-api/server"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/sns"
 )
 
 type LoginUserResultResolver struct {
 	server *server.Server
 
-	token *server.AuthToken
+	tokens *server.AuthTokens
 }
 
 func (r *LoginUserResultResolver) Token() string {
-	return r.token.Token
+	return r.tokens.Access.Token
 }
 
 func (r *LoginUserResultResolver) ExpiresAt() Timestamp {
-	return Timestamp{r.token.ExpiresAt}
+	return Timestamp{r.tokens.Access.ExpiresAt}
+}
+
+func (r *LoginUserResultResolver) RefreshToken() string {
+	return r.tokens.Refresh.Token
+}
+
+func (r *LoginUserResultResolver) RefreshTokenExpiresAt() Timestamp {
+	return Timestamp{r.tokens.Refresh.ExpiresAt}
+}
+
+// RefreshAuthToken trades a refresh token from LoginUser or a previous
+// RefreshAuthToken for a new pair of tokens. The refresh token given can't be
+// used again.
+func (r *Resolver) RefreshAuthToken(ctx context.Context, args struct {
+	Input struct {
+		RefreshToken string
+	}
+}) (*LoginUserResultResolver, error) {
+	tokens, err := r.server.RefreshAuthTokens(args.Input.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginUserResultResolver{
+		server: r.server,
+		tokens: tokens,
+	}, nil
 }
 
 // LoginUser uses a login code + phone number to produce an authentication JWT
-// of the authenticated user. A new user is created if none is found for the
+// and refresh token of the authenticated user. A new user is created if none is found for the
 // given phone number.
 func (r *Resolver) LoginUser(ctx context.Context, args struct {
 	Input struct {
 		PhoneNumber string
 		LoginCode   string
 		FCMToken    *string
+		DeviceID    *string
 	}
 }) (*LoginUserResultResolver, error) {
 	inputPhoneNumber := args.Input.PhoneNumber
 	inputLoginCode := args.Input.LoginCode
 	inputFCMToken := args.Input.FCMToken
-	fmt.Println("===inputFCMToken (not pointer) ===", inputFCMToken)
-	// fmt.Println("===inputFCMToken (pointer) ===", *inputFCMToken)
 	phoneNumber, err := parsePhoneNumber(inputPhoneNumber)
 	if err != nil {
 		return nil, err
 	}
 
-	var loginCodeResult struct {
-		userID *int64
+	clientIP := ctxClientIP(ctx)
+	if err := r.server.CheckLoginAllowed(phoneNumber, clientIP); err != nil {
+		return nil, err
 	}
-	err = r.server.ConnPool.QueryRow(`
-		select
-			users.id as user_id
+
+	// the attempt is counted before the code is compared, so concurrent
+	// wrong guesses can't all get in before any is recorded
+	claims, err := r.server.ClaimLoginAttempt(phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var loginCodeID int64
+	for _, claim := range claims {
+		if subtle.ConstantTimeCompare([]byte(claim.Code), []byte(inputLoginCode)) == 1 {
+			loginCodeID = claim.ID
+		}
+	}
+
+	if loginCodeID == 0 {
+		if err := r.server.RecordLoginFailure(phoneNumber, clientIP); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid login")
+	}
+
+	// the code's consumption and the user upsert happen together, so a code
+	// can't be used twice and concurrent logins see one user
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// for update makes a concurrent login with the same code wait, then find
+	// it disabled
+	err = tx.QueryRow(`
+		select id
 		from login_codes
-		left join users on users.phone_number = login_codes.phone_number
-		where login_codes.phone_number = $1
-			and login_codes.login_code = $2
-			and login_codes.expires_at >= now()
+		where id = $1
 			and enabled is true
-		order by login_codes.created_at desc
-		limit 1
-	`, phoneNumber, inputLoginCode).Scan(&loginCodeResult.userID)
+		for update
+	`, loginCodeID).Scan(&loginCodeID)
 	switch {
 	case err == pgx.ErrNoRows:
 		return nil, errors.New("invalid login")
@@ -70,57 +122,52 @@ func (r *Resolver) LoginUser(ctx context.Context, args struct {
 		return nil, err
 	}
 
-	// TODO: wrap this and previous query in tx
-	_, err = r.server.ConnPool.Exec(`
-		update login_codes 
-		set enabled = false
-		where phone_number = $1
-	`, phoneNumber)
+	_, err = tx.Exec(`
+		update login_codes
+		set enabled = false, failed_attempts = 0, updated_at = now()
+		where id = $1
+	`, loginCodeID)
 	if err != nil {
 		return nil, err
 	}
 
-	if loginCodeResult.userID != nil {
-		// TODO: update the fcm_token in user table.
-		_, err = r.server.ConnPool.Exec(`
-			update users 
-			set fcm_token = $1
-			where id = $2
-		`, inputFCMToken, *loginCodeResult.userID)
-		if err != nil {
-			return nil, err
-		}
+	// do update rather than do nothing so the row comes back even when
+	// another login created the user first
+	var userID int64
+	err = tx.QueryRow(`
+		insert into users (phone_number, created_at, updated_at)
+		values ($1, now(), now())
+		on conflict (phone_number) do update
+		set updated_at = now()
+		returning id
+	`, phoneNumber).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
 
-		authToken, err := r.server.GenerateAuthJWT(*loginCodeResult.userID)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := r.server.ResetLoginFailures(phoneNumber); err != nil {
+		return nil, err
+	}
+
+	if inputFCMToken != nil && *inputFCMToken != "" {
+		_, err := r.server.RegisterPushDevice(userID, server.PushPlatformFCM, *inputFCMToken, args.Input.DeviceID)
 		if err != nil {
 			return nil, err
 		}
-
-		return &LoginUserResultResolver{
-			token: authToken,
-		}, nil
 	}
 
-	var newUserResult struct {
-		userID int64
-	}
-	err = r.server.ConnPool.QueryRow(`
-		insert into users (phone_number, fcm_token, created_at, updated_at)
-		values ($1, $2, now(), now())
-		on conflict (phone_number) do nothing
-		returning users.id
-	`, phoneNumber, inputFCMToken).Scan(&newUserResult.userID)
+	authTokens, err := r.server.IssueAuthTokens(userID, args.Input.DeviceID)
 	if err != nil {
 		return nil, err
 	}
 
-	authToken, err := r.server.GenerateAuthJWT(newUserResult.userID)
-	if err != nil {
-		return nil, err
-	}
-	fmt.Println("=============authToken user success==========", authToken)
 	return &LoginUserResultResolver{
-		token: authToken,
+		server: r.server,
+		tokens: authTokens,
 	}, nil
 }
 
@@ -128,8 +175,9 @@ func (r *Resolver) LoginUser(ctx context.Context, args struct {
 // conjunction with a phone number in LoginUser to login a user.
 //
 // Enabled login codes are naively generated given there there has been no new
-// code in the checked duration serving as a rate limit, although LoginUser
-// only checks the most recently generated one.
+// code in the checked duration serving as a rate limit. Requesting a code
+// disables the previous ones, and none are sent while the phone number or
+// client IP is locked out of LoginUser.
 func (r *Resolver) RequestLoginCode(ctx context.Context, args struct {
 	Input struct {
 		PhoneNumber string
@@ -142,6 +190,11 @@ func (r *Resolver) RequestLoginCode(ctx context.Context, args struct {
 		return nil, err
 	}
 
+	// a new code would otherwise reset its failed attempts
+	if err := r.server.CheckLoginAllowed(phoneNumber, ctxClientIP(ctx)); err != nil {
+		return nil, err
+	}
+
 	var _id int64
 	err = r.server.ConnPool.QueryRow(`
 		select id from login_codes
@@ -164,6 +217,17 @@ func (r *Resolver) RequestLoginCode(ctx context.Context, args struct {
 	}
 	loginCode := fmt.Sprintf("%06d", randInt.Int64())
 
+	// only the newest code works, so guesses can't be spread across codes
+	_, err = r.server.ConnPool.Exec(`
+		update login_codes
+		set enabled = false
+		where phone_number = $1
+			and enabled is true
+	`, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = r.server.ConnPool.Exec(`
 		insert into login_codes (phone_number, login_code, expires_at, enabled, created_at)
 		values ($1, $2, now() + interval '15' minute, true, now())
@@ -174,11 +238,7 @@ func (r *Resolver) RequestLoginCode(ctx context.Context, args struct {
 
 	message := fmt.Sprintf("Your login code is %s", loginCode)
 
-	_, err = r.server.SNS.Publish(&sns.PublishInput{
-		Message:     aws.String(message),
-		PhoneNumber: aws.String(phoneNumber),
-	})
-	if err != nil {
+	if err := r.server.SMS.SendSMS(phoneNumber, message); err != nil {
 		return nil, err
 	}
 
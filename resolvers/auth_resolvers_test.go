@@ -0,0 +1,533 @@
+package resolvers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginUser(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	t.Run("wrong code", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			Query: `
+				mutation {
+					requestLoginCode(input: {phoneNumber: "+16175550100"})
+				}
+			`}, nil)
+
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				Query: `
+				mutation {
+					loginUser(input: {phoneNumber: "+16175550100", loginCode: "not a code"}) {
+						token
+					}
+				}`,
+			},
+			ExpectedErrors: []*errors.QueryError{
+				{
+					Message: "invalid login",
+				},
+			},
+		})
+	})
+
+	t.Run("texted code", func(t *testing.T) {
+		// wait out requestLoginCode's rate limit
+		_, err := connPool.Exec(`update login_codes set created_at = now() - interval '1' minute`)
+		require.NoError(t, err)
+
+		login := harness.MustLogin("+16175550100", "phone")
+
+		var userID int64
+		err = connPool.QueryRow(`select id from users where phone_number = '+16175550100'`).Scan(&userID)
+		require.NoError(t, err)
+
+		token, err := harness.resolver.server.ValidateAuthJWT(login.Token)
+		require.NoError(t, err)
+		require.Equal(t, userID, token.Claims.(*server.AuthJWTClaims).UserID)
+	})
+}
+
+func TestRefreshAuthToken(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	login := harness.MustLogin("+16175550100", "phone")
+	require.NotEmpty(t, login.Token)
+	require.NotEmpty(t, login.RefreshToken)
+
+	refresh := func(refreshToken string) (string, []*errors.QueryError) {
+		var res struct {
+			RefreshAuthToken struct {
+				RefreshToken string
+			}
+		}
+		errs := harness.Exec(ExecInput{
+			Variables: map[string]interface{}{
+				"refreshToken": refreshToken,
+			},
+			Query: `
+				mutation Refresh($refreshToken: String!) {
+					refreshAuthToken(input: {refreshToken: $refreshToken}) {
+						refreshToken
+					}
+				}
+			`}, &res)
+		return res.RefreshAuthToken.RefreshToken, errs
+	}
+
+	rotated, errs := refresh(login.RefreshToken)
+	require.Empty(t, errs)
+	require.NotEqual(t, login.RefreshToken, rotated)
+
+	t.Run("reuse revokes the rotated token", func(t *testing.T) {
+		_, errs := refresh(login.RefreshToken)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid refresh token", errs[0].Message)
+
+		_, errs = refresh(rotated)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid refresh token", errs[0].Message)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	login := func(deviceID string) (userID int64, sessionID string, refreshToken string) {
+		res := harness.MustLogin("+16175550100", deviceID)
+
+		err := connPool.QueryRow(`
+			select user_id, id::text from sessions
+			where device_id = $1 and revoked_at is null
+		`, deviceID).Scan(&userID, &sessionID)
+		require.NoError(t, err)
+
+		return userID, sessionID, res.RefreshToken
+	}
+
+	userID, phoneSessionID, phoneRefreshToken := login("phone")
+	_, tabletSessionID, _ := login("tablet")
+
+	var res struct {
+		Sessions []struct {
+			ID       string
+			DeviceID string
+			Current  bool
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID:    userID,
+		SessionID: tabletSessionID,
+		Query: `
+			{
+				sessions {
+					id
+					deviceID
+					current
+				}
+			}
+		`}, &res)
+	require.Len(t, res.Sessions, 2)
+	for _, session := range res.Sessions {
+		require.Equal(t, session.ID == tabletSessionID, session.Current)
+	}
+
+	t.Run("logout a lost phone", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID:    userID,
+			SessionID: tabletSessionID,
+			Variables: map[string]interface{}{
+				"sessionID": phoneSessionID,
+			},
+			Query: `
+				mutation Logout($sessionID: ID!) {
+					logout(input: {sessionID: $sessionID})
+				}
+			`}, nil)
+
+		active, err := harness.resolver.server.SessionActive(userID, phoneSessionID)
+		require.NoError(t, err)
+		require.False(t, active)
+
+		errs := harness.Exec(ExecInput{
+			Variables: map[string]interface{}{
+				"refreshToken": phoneRefreshToken,
+			},
+			Query: `
+				mutation Refresh($refreshToken: String!) {
+					refreshAuthToken(input: {refreshToken: $refreshToken}) {
+						token
+					}
+				}
+			`}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid refresh token", errs[0].Message)
+	})
+
+	t.Run("logout all devices", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID:    userID,
+			SessionID: tabletSessionID,
+			Query: `
+				mutation {
+					logoutAllDevices
+				}
+			`}, nil)
+
+		active, err := harness.resolver.server.SessionActive(userID, tabletSessionID)
+		require.NoError(t, err)
+		require.False(t, active)
+	})
+}
+
+func TestLoginBruteForce(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	const phoneNumber = "+16175550100"
+
+	harness.MustExec(ExecInput{
+		ClientIP: "192.0.2.1",
+		Query: `
+			mutation {
+				requestLoginCode(input: {phoneNumber: "+16175550100"})
+			}
+		`}, nil)
+	sms, ok := harness.sms.LastMessage(phoneNumber)
+	require.True(t, ok)
+	loginCode := strings.TrimPrefix(sms.Message, "Your login code is ")
+
+	login := func(clientIP string, phoneNumber string, loginCode string) []*errors.QueryError {
+		return harness.Exec(ExecInput{
+			ClientIP: clientIP,
+			Variables: map[string]interface{}{
+				"phoneNumber": phoneNumber,
+				"loginCode":   loginCode,
+			},
+			Query: `
+				mutation Login($phoneNumber: String!, $loginCode: String!) {
+					loginUser(input: {phoneNumber: $phoneNumber, loginCode: $loginCode}) {
+						token
+					}
+				}
+			`}, nil)
+	}
+
+	for i := 0; i < server.MaxLoginCodeAttempts; i++ {
+		errs := login("192.0.2.1", phoneNumber, "000000x")
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid login", errs[0].Message)
+	}
+
+	t.Run("phone is locked out", func(t *testing.T) {
+		errs := login("192.0.2.2", phoneNumber, loginCode)
+		require.Len(t, errs, 1)
+		require.Contains(t, errs[0].Message, "too many failed login attempts")
+	})
+
+	t.Run("code is invalidated", func(t *testing.T) {
+		_, err := connPool.Exec(`update login_failures set locked_until = null`)
+		require.NoError(t, err)
+
+		errs := login("192.0.2.2", phoneNumber, loginCode)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid login", errs[0].Message)
+	})
+
+	t.Run("ip is locked out", func(t *testing.T) {
+		_, err := connPool.Exec(`
+			update login_failures set locked_until = now() + interval '1' minute
+			where kind = 'ip' and key = '192.0.2.1'
+		`)
+		require.NoError(t, err)
+
+		errs := login("192.0.2.1", "+16175550199", "123456")
+		require.Len(t, errs, 1)
+		require.Contains(t, errs[0].Message, "too many failed login attempts")
+	})
+}
+
+func TestConcurrentLoginGuesses(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	const phoneNumber = "+16175550100"
+
+	harness.MustExec(ExecInput{
+		Query: `
+			mutation {
+				requestLoginCode(input: {phoneNumber: "+16175550100"})
+			}
+		`}, nil)
+	sms, ok := harness.sms.LastMessage(phoneNumber)
+	require.True(t, ok)
+	loginCode := strings.TrimPrefix(sms.Message, "Your login code is ")
+
+	login := func(clientIP string, loginCode string) []*errors.QueryError {
+		return harness.Exec(ExecInput{
+			ClientIP: clientIP,
+			Variables: map[string]interface{}{
+				"loginCode": loginCode,
+			},
+			Query: `
+				mutation Login($loginCode: String!) {
+					loginUser(input: {phoneNumber: "+16175550100", loginCode: $loginCode}) {
+						token
+					}
+				}
+			`}, nil)
+	}
+
+	// the guesses all get past the lockout check before any is recorded
+	const guesses = 4 * server.MaxLoginCodeAttempts
+	results := make(chan []*errors.QueryError, guesses)
+	for i := 0; i < guesses; i++ {
+		go func(i int) {
+			results <- login(fmt.Sprintf("192.0.2.%d", i+1), "000000x")
+		}(i)
+	}
+	for i := 0; i < guesses; i++ {
+		errs := <-results
+		require.Len(t, errs, 1)
+	}
+
+	// the code's attempts are used up, lockouts aside
+	_, err := connPool.Exec(`update login_failures set locked_until = null`)
+	require.NoError(t, err)
+
+	errs := login("198.51.100.1", loginCode)
+	require.Len(t, errs, 1)
+	require.Equal(t, "invalid login", errs[0].Message)
+}
+
+func TestConcurrentLogin(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	// login is the access token, it's called concurrently so it can't require
+	login := func(phoneNumber, loginCode string) (string, []*errors.QueryError) {
+		var res struct {
+			LoginUser struct {
+				Token string
+			}
+		}
+		errs := harness.Exec(ExecInput{
+			Variables: map[string]interface{}{
+				"phoneNumber": phoneNumber,
+				"loginCode":   loginCode,
+			},
+			Query: `
+				mutation Login($phoneNumber: String!, $loginCode: String!) {
+					loginUser(input: {phoneNumber: $phoneNumber, loginCode: $loginCode}) {
+						token
+					}
+				}
+			`}, &res)
+		return res.LoginUser.Token, errs
+	}
+
+	userIDFor := func(token string) int64 {
+		parsed, err := harness.resolver.server.ValidateAuthJWT(token)
+		require.NoError(t, err)
+		return parsed.Claims.(*server.AuthJWTClaims).UserID
+	}
+
+	countUsers := func(phoneNumber string) int {
+		var users int
+		err := connPool.QueryRow(`select count(*) from users where phone_number = $1`, phoneNumber).Scan(&users)
+		require.NoError(t, err)
+		return users
+	}
+
+	t.Run("a code is only used once", func(t *testing.T) {
+		const phoneNumber = "+16175550100"
+
+		harness.MustExec(ExecInput{
+			Query: `
+				mutation {
+					requestLoginCode(input: {phoneNumber: "+16175550100"})
+				}
+			`}, nil)
+		sms, ok := harness.sms.LastMessage(phoneNumber)
+		require.True(t, ok)
+		loginCode := strings.TrimPrefix(sms.Message, "Your login code is ")
+
+		const devices = 4
+		results := make(chan []*errors.QueryError, devices)
+		for i := 0; i < devices; i++ {
+			go func() {
+				_, errs := login(phoneNumber, loginCode)
+				results <- errs
+			}()
+		}
+
+		// the rest must fail cleanly rather than with a missing user
+		var succeeded int
+		for i := 0; i < devices; i++ {
+			errs := <-results
+			if len(errs) == 0 {
+				succeeded++
+				continue
+			}
+
+			require.Len(t, errs, 1)
+			require.Equal(t, "invalid login", errs[0].Message)
+		}
+		require.Equal(t, 1, succeeded)
+		require.Equal(t, 1, countUsers(phoneNumber))
+	})
+
+	t.Run("first logins with different codes create one user", func(t *testing.T) {
+		const phoneNumber = "+16175550101"
+
+		// requestLoginCode would disable the first code
+		codes := []string{"111111", "222222"}
+		for _, code := range codes {
+			_, err := connPool.Exec(`
+				insert into login_codes (login_code, phone_number, expires_at, created_at, updated_at, enabled)
+				values ($1, $2, now() + interval '5 minutes', now(), now(), true)
+			`, code, phoneNumber)
+			require.NoError(t, err)
+		}
+
+		type result struct {
+			token string
+			errs  []*errors.QueryError
+		}
+		results := make(chan result, len(codes))
+		for _, code := range codes {
+			go func(code string) {
+				token, errs := login(phoneNumber, code)
+				results <- result{token, errs}
+			}(code)
+		}
+
+		first, second := <-results, <-results
+		require.Empty(t, first.errs)
+		require.Empty(t, second.errs)
+		userID := userIDFor(first.token)
+		require.NotZero(t, userID)
+		require.Equal(t, userID, userIDFor(second.token))
+		require.Equal(t, 1, countUsers(phoneNumber))
+	})
+}
+
+func TestEmailLogin(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	requestLink := func(userID int64, email string) string {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Variables: map[string]interface{}{
+				"email": email,
+			},
+			Query: `
+				mutation RequestEmailLogin($email: String!) {
+					requestEmailLogin(input: {email: $email})
+				}
+			`}, nil)
+
+		sent, ok := harness.email.LastEmail(strings.ToLower(email))
+		require.True(t, ok)
+
+		i := strings.Index(sent.Body, "?token=")
+		require.True(t, i >= 0, "no link in %q", sent.Body)
+		link := strings.Fields(sent.Body[i+len("?token="):])[0]
+		return strings.Split(link, "&")[0]
+	}
+
+	loginWithEmail := func(token string) (string, []*errors.QueryError) {
+		var res struct {
+			LoginWithEmail struct {
+				Token string
+			}
+		}
+		errs := harness.Exec(ExecInput{
+			Variables: map[string]interface{}{
+				"token": token,
+			},
+			Query: `
+				mutation LoginWithEmail($token: String!) {
+					loginWithEmail(input: {token: $token}) {
+						token
+					}
+				}
+			`}, &res)
+		return res.LoginWithEmail.Token, errs
+	}
+
+	userIDOf := func(token string) int64 {
+		parsed, err := harness.resolver.server.ValidateAuthJWT(token)
+		require.NoError(t, err)
+		return parsed.Claims.(*server.AuthJWTClaims).UserID
+	}
+
+	t.Run("signs up a new user", func(t *testing.T) {
+		token := requestLink(0, "Neighbor@Example.com")
+
+		accessToken, errs := loginWithEmail(token)
+		require.Empty(t, errs)
+
+		var email string
+		err := connPool.QueryRow(`select email from users where id = $1`, userIDOf(accessToken)).Scan(&email)
+		require.NoError(t, err)
+		require.Equal(t, "neighbor@example.com", email)
+
+		_, errs = loginWithEmail(token)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid or expired login link", errs[0].Message)
+	})
+
+	confirmEmail := func(userID int64, token string) []*errors.QueryError {
+		return harness.Exec(ExecInput{
+			UserID: userID,
+			Variables: map[string]interface{}{
+				"token": token,
+			},
+			Query: `
+				mutation ConfirmEmail($token: String!) {
+					confirmEmail(input: {token: $token}) {
+						id
+					}
+				}
+			`}, &map[string]interface{}{})
+	}
+
+	t.Run("adds the email to a phone user", func(t *testing.T) {
+		phoneLogin := harness.MustLogin("+16175550100", "phone")
+		phoneUserID := userIDOf(phoneLogin.Token)
+		otherLogin := harness.MustLogin("+16175550101", "other phone")
+
+		token := requestLink(phoneUserID, "phone.user@example.com")
+		sent, _ := harness.email.LastEmail("phone.user@example.com")
+		require.Equal(t, "Confirm your email for Cobbles", sent.Subject)
+
+		// whoever the link reaches isn't logged in as the user who asked
+		// for it
+		_, errs := loginWithEmail(token)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid or expired login link", errs[0].Message)
+
+		errs = confirmEmail(userIDOf(otherLogin.Token), token)
+		require.Len(t, errs, 1)
+		require.Equal(t, "invalid or expired email confirmation link", errs[0].Message)
+
+		require.Empty(t, confirmEmail(phoneUserID, token))
+
+		var email string
+		err := connPool.QueryRow(`select email from users where id = $1`, phoneUserID).Scan(&email)
+		require.NoError(t, err)
+		require.Equal(t, "phone.user@example.com", email)
+	})
+}
@@ -0,0 +1,62 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// BlockUser hides a user's posts, comments and conversations from the current
+// user, and stops either messaging the other
+func (r *Resolver) BlockUser(ctx context.Context, args struct {
+	ID int32 // Blocked User ID
+}) (bool, error) {
+	return r.blockUser(ctx, int64(args.ID), server.BlockKindBlock)
+}
+
+// UnblockUser ...
+func (r *Resolver) UnblockUser(ctx context.Context, args struct {
+	ID int32 // Blocked User ID
+}) (bool, error) {
+	return r.unblockUser(ctx, int64(args.ID), server.BlockKindBlock)
+}
+
+// MuteUser hides a user's posts and comments from the current user
+func (r *Resolver) MuteUser(ctx context.Context, args struct {
+	ID int32 // Muted User ID
+}) (bool, error) {
+	return r.blockUser(ctx, int64(args.ID), server.BlockKindMute)
+}
+
+// UnmuteUser ...
+func (r *Resolver) UnmuteUser(ctx context.Context, args struct {
+	ID int32 // Muted User ID
+}) (bool, error) {
+	return r.unblockUser(ctx, int64(args.ID), server.BlockKindMute)
+}
+
+func (r *Resolver) blockUser(ctx context.Context, otherUserID int64, kind server.BlockKind) (bool, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.server.BlockUser(userID, otherUserID, kind); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *Resolver) unblockUser(ctx context.Context, otherUserID int64, kind server.BlockKind) (bool, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.server.UnblockUser(userID, otherUserID, kind); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
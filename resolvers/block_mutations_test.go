@@ -0,0 +1,203 @@
+package resolvers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocking(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		userID        int64 = 1
+		blockedUserID int64 = 2
+		mutedUserID   int64 = 3
+	)
+
+	for _, id := range []int64{userID, blockedUserID, mutedUserID} {
+		harness.MustCreateUser(id)
+	}
+
+	createPost := func(authorID int64) string {
+		var res struct {
+			CreatePost struct {
+				ID string
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: authorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPost(input: {title: "posted by %d", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}`, authorID),
+		}, &res)
+		return res.CreatePost.ID
+	}
+
+	var postIDs []string
+	for _, id := range []int64{userID, blockedUserID, mutedUserID} {
+		postIDs = append(postIDs, createPost(id))
+	}
+
+	for _, id := range []int64{blockedUserID, mutedUserID} {
+		harness.MustExec(ExecInput{
+			UserID: id,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPostComment(input: {postID: %s, comment: "comment by %d"})
+				}`, postIDs[0], id),
+		}, nil)
+	}
+
+	var convo struct {
+		GetOrCreateConversation struct {
+			Conversation struct {
+				ID string
+			}
+		}
+	}
+	getOrCreateConversation := fmt.Sprintf(`
+		mutation {
+			getOrCreateConversation(input: {postID: "%s"}) {
+				conversation {
+					id
+				}
+			}
+		}`, postIDs[0])
+	harness.MustExec(ExecInput{
+		UserID: blockedUserID,
+		Query:  getOrCreateConversation,
+	}, &convo)
+
+	feedTitles := func(viewerID int64) []string {
+		var res struct {
+			Feed struct {
+				Posts []struct {
+					Title string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: viewerID,
+			Query:  `{ feed { posts { title } } }`,
+		}, &res)
+
+		var titles []string
+		for _, post := range res.Feed.Posts {
+			titles = append(titles, post.Title)
+		}
+		return titles
+	}
+
+	comments := func(viewerID int64) []string {
+		var res struct {
+			PostComments struct {
+				Comments []struct {
+					Comment string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: viewerID,
+			Query: fmt.Sprintf(`
+			{
+				postComments(input: {postID: %s}) {
+					comments {
+						comment
+					}
+				}
+			}`, postIDs[0]),
+		}, &res)
+
+		var comments []string
+		for _, c := range res.PostComments.Comments {
+			comments = append(comments, c.Comment)
+		}
+		return comments
+	}
+
+	conversationCount := func(viewerID int64) int {
+		var res struct {
+			Conversations struct {
+				Conversations []struct {
+					ID string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: viewerID,
+			Query:  `{ conversations { conversations { id } } }`,
+		}, &res)
+		return len(res.Conversations.Conversations)
+	}
+
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: fmt.Sprintf(`
+			mutation {
+				blockUser(id: %d)
+				muteUser(id: %d)
+			}`, blockedUserID, mutedUserID),
+	}, nil)
+
+	t.Run("blocked and muted users are hidden", func(t *testing.T) {
+		require.Equal(t, []string{"posted by 1"}, feedTitles(userID))
+		require.Empty(t, comments(userID))
+		require.Len(t, comments(mutedUserID), 2)
+		require.Equal(t, 0, conversationCount(userID))
+	})
+
+	t.Run("blocked users can't message the blocker", func(t *testing.T) {
+		errs := harness.Exec(ExecInput{
+			UserID: blockedUserID,
+			Query:  getOrCreateConversation,
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "you can't message this user", errs[0].Message)
+
+		errs = harness.Exec(ExecInput{
+			UserID: blockedUserID,
+			Query: fmt.Sprintf(`
+				mutation {
+					sendMessage(input: {conversationID: "%s", body: "hello?"}) {
+						message {
+							body
+						}
+					}
+				}`, convo.GetOrCreateConversation.Conversation.ID),
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "you can't message this user", errs[0].Message)
+	})
+
+	t.Run("lists and lifts blocks", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: userID,
+				Query:  `{ blockedUsers { id } mutedUsers { id } }`,
+			},
+			ExpectedResult: map[string]interface{}{
+				"blockedUsers": []map[string]interface{}{{"id": "2"}},
+				"mutedUsers":   []map[string]interface{}{{"id": "3"}},
+			},
+		})
+
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+				mutation {
+					unblockUser(id: %d)
+					unmuteUser(id: %d)
+				}`, blockedUserID, mutedUserID),
+		}, nil)
+
+		require.Len(t, feedTitles(userID), 3)
+		require.Len(t, comments(userID), 2)
+		require.Equal(t, 1, conversationCount(userID))
+	})
+}
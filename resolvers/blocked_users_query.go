@@ -0,0 +1,41 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// BlockedUsers - who the current user has blocked, most recent first
+func (r *Resolver) BlockedUsers(ctx context.Context) ([]*UserResolver, error) {
+	return r.blockedUsers(ctx, server.BlockKindBlock)
+}
+
+// MutedUsers - who the current user has muted, most recent first
+func (r *Resolver) MutedUsers(ctx context.Context) ([]*UserResolver, error) {
+	return r.blockedUsers(ctx, server.BlockKindMute)
+}
+
+func (r *Resolver) blockedUsers(ctx context.Context, kind server.BlockKind) ([]*UserResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blockedUserIDs, err := r.server.BlockedUserIDs(userID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*UserResolver{}
+	for _, blockedUserID := range blockedUserIDs {
+		user, err := r.server.UserByID(blockedUserID)
+		if err != nil {
+			return nil, err
+		}
+
+		resolvers = append(resolvers, &UserResolver{server: r.server, user: user})
+	}
+
+	return resolvers, nil
+}
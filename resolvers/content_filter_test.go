@@ -0,0 +1,214 @@
+package resolvers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilter(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	dir, err := ioutil.TempDir("", "content-rules")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rulesPath := filepath.Join(dir, "rules.json")
+	err = ioutil.WriteFile(rulesPath, []byte(`{
+		"words": {"heck": "flag"},
+		"rules": [
+			{"pattern": "buy\\s+followers", "verdict": "shadow_hide", "reason": "spam"},
+			{"pattern": "free money", "verdict": "reject", "kinds": ["post"], "reason": "scam"}
+		]
+	}`), 0644)
+	require.NoError(t, err)
+
+	filters, err := server.LoadContentRuleFile(rulesPath)
+	require.NoError(t, err)
+	harness.resolver.server.ContentFilters = append(filters, &server.DBRuleFilter{Server: harness.resolver.server})
+
+	var (
+		authorID    int64 = 1
+		viewerID    int64 = 2
+		moderatorID int64 = 3
+		adminID     int64 = 4
+	)
+	for _, id := range []int64{authorID, viewerID, moderatorID, adminID} {
+		harness.MustCreateUser(id)
+	}
+	_, err = connPool.Exec(`update users set roles = '{moderator}' where id = $1`, moderatorID)
+	require.NoError(t, err)
+	_, err = connPool.Exec(`update users set roles = '{admin}' where id = $1`, adminID)
+	require.NoError(t, err)
+
+	createPost := func(title string) (string, error) {
+		var res struct {
+			CreatePost struct {
+				ID string
+			}
+		}
+		errs := harness.Exec(ExecInput{
+			UserID: authorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPost(input: {title: %q, kind: TEXT, poster: "default"}) {
+						id
+					}
+				}`, title),
+		}, &res)
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		return res.CreatePost.ID, nil
+	}
+
+	t.Run("rejected text isn't stored", func(t *testing.T) {
+		_, err := createPost("FREE MONEY inside")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "scam")
+
+		_, err = createPost("you n1gg3r")
+		require.Error(t, err)
+
+		require.Empty(t, postTitles(harness, authorID, authorID))
+	})
+
+	postID, err := createPost("buy  followers here")
+	require.NoError(t, err)
+
+	t.Run("shadow hidden content is only shown to its author", func(t *testing.T) {
+		require.Equal(t, []string{"buy  followers here"}, postTitles(harness, authorID, authorID))
+		require.Empty(t, postTitles(harness, viewerID, authorID))
+
+		harness.MustExec(ExecInput{
+			UserID: authorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPostComment(input: {postID: %s, comment: "please buy followers"})
+				}`, postID),
+		}, nil)
+
+		commentCount := func(viewerID int64) int {
+			var res struct {
+				PostComments struct {
+					Comments []struct {
+						Comment string
+					}
+				}
+			}
+			harness.MustExec(ExecInput{
+				UserID: viewerID,
+				Query: fmt.Sprintf(`
+				{
+					postComments(input: {postID: %s}) {
+						comments {
+							comment
+						}
+					}
+				}`, postID),
+			}, &res)
+			return len(res.PostComments.Comments)
+		}
+		require.Equal(t, 1, commentCount(authorID))
+		require.Equal(t, 0, commentCount(viewerID))
+	})
+
+	t.Run("flagged content is listed for moderators", func(t *testing.T) {
+		_, err := createPost("what the heck")
+		require.NoError(t, err)
+
+		query := `{ contentFlags { contentFlags { kind text verdict reason } } }`
+		errs := harness.Exec(ExecInput{UserID: viewerID, Query: query}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "unauthorized", errs[0].Message)
+
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{UserID: moderatorID, Query: query},
+			ExpectedResult: map[string]interface{}{
+				"contentFlags": map[string]interface{}{
+					"contentFlags": []map[string]interface{}{
+						{"kind": "POST", "text": "what the heck", "verdict": "FLAG", "reason": "wordlist"},
+						{"kind": "COMMENT", "text": "please buy followers", "verdict": "SHADOW_HIDE", "reason": "spam"},
+						{"kind": "POST", "text": "buy  followers here", "verdict": "SHADOW_HIDE", "reason": "spam"},
+					},
+				},
+			},
+		})
+	})
+
+	t.Run("admins add rules", func(t *testing.T) {
+		createRule := `
+			mutation {
+				createContentRule(input: {pattern: "crypto giveaway", verdict: REJECT, reason: "scam"}) {
+					id
+					enabled
+				}
+			}`
+		errs := harness.Exec(ExecInput{UserID: moderatorID, Query: createRule}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "unauthorized", errs[0].Message)
+
+		var res struct {
+			CreateContentRule struct {
+				ID      string
+				Enabled bool
+			}
+		}
+		harness.MustExec(ExecInput{UserID: adminID, Query: createRule}, &res)
+		require.True(t, res.CreateContentRule.Enabled)
+
+		_, err := createPost("Crypto Giveaway!")
+		require.Error(t, err)
+
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					setContentRuleEnabled(input: {id: %q, enabled: false}) {
+						enabled
+					}
+				}`, res.CreateContentRule.ID),
+		}, nil)
+
+		_, err = createPost("Crypto Giveaway!")
+		require.NoError(t, err)
+
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query: fmt.Sprintf(`
+				mutation {
+					setContentRuleEnabled(input: {id: %q, enabled: true}) {
+						enabled
+					}
+				}`, res.CreateContentRule.ID),
+		}, nil)
+
+		_, err = createPost("Crypto Giveaway!")
+		require.Error(t, err)
+
+		harness.MustExec(ExecInput{
+			UserID: adminID,
+			Query:  fmt.Sprintf(`mutation { deleteContentRule(id: %q) }`, res.CreateContentRule.ID),
+		}, nil)
+
+		_, err = createPost("Crypto Giveaway!")
+		require.NoError(t, err)
+
+		errs = harness.Exec(ExecInput{
+			UserID: adminID,
+			Query: `
+				mutation {
+					createContentRule(input: {pattern: "(unclosed", verdict: FLAG}) {
+						id
+					}
+				}`,
+		}, nil)
+		require.Len(t, errs, 1)
+	})
+}
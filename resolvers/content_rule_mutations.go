@@ -0,0 +1,184 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// CreateContentRule - admins only, adds a regular expression the content
+// filter checks posts, comments and messages against
+func (r *Resolver) CreateContentRule(ctx context.Context, args struct {
+	Input struct {
+		Pattern string
+		Verdict string
+		Kinds   *[]string
+		Reason  *string
+	}
+}) (*ContentRuleResolver, error) {
+	userID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := server.ContentRule{
+		Pattern: args.Input.Pattern,
+		Verdict: server.FilterVerdict(strings.ToLower(args.Input.Verdict)),
+	}
+	if args.Input.Kinds != nil {
+		for _, kind := range *args.Input.Kinds {
+			rule.Kinds = append(rule.Kinds, server.ContentKind(strings.ToLower(kind)))
+		}
+	}
+	if args.Input.Reason != nil {
+		rule.Reason = *args.Input.Reason
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := r.server.CreateContentRule(tx, userID, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.audit(
+		tx,
+		userID,
+		server.AuditActionCreateContentRule,
+		server.AuditTargetContentRule,
+		created.ID,
+		nil,
+		contentRuleAudit(created),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.server.InvalidateContentRules()
+
+	return &ContentRuleResolver{rule: created}, nil
+}
+
+// SetContentRuleEnabled - admins only, turns a rule on or off
+func (r *Resolver) SetContentRuleEnabled(ctx context.Context, args struct {
+	Input struct {
+		ID      graphql.ID
+		Enabled bool
+	}
+}) (*ContentRuleResolver, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(string(args.Input.ID), 10, 64)
+	if err != nil {
+		return nil, errors.New("content rule not found")
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.server.ContentRuleForUpdate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := r.server.SetContentRuleEnabled(tx, id, args.Input.Enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.audit(
+		tx,
+		adminID,
+		server.AuditActionUpdateContentRule,
+		server.AuditTargetContentRule,
+		id,
+		contentRuleAudit(before),
+		contentRuleAudit(rule),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.server.InvalidateContentRules()
+
+	return &ContentRuleResolver{rule: rule}, nil
+}
+
+// DeleteContentRule - admins only
+func (r *Resolver) DeleteContentRule(ctx context.Context, args struct {
+	ID graphql.ID
+}) (bool, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+
+	id, err := strconv.ParseInt(string(args.ID), 10, 64)
+	if err != nil {
+		return false, errors.New("content rule not found")
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.server.ContentRuleForUpdate(tx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.server.DeleteContentRule(tx, id); err != nil {
+		return false, err
+	}
+
+	if err := r.audit(
+		tx,
+		adminID,
+		server.AuditActionDeleteContentRule,
+		server.AuditTargetContentRule,
+		id,
+		contentRuleAudit(before),
+		nil,
+	); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	r.server.InvalidateContentRules()
+
+	return true, nil
+}
+
+// contentRuleAudit is a rule as the audit log stores it, ContentRule's JSON
+// is the rule file format which leaves out enabled
+func contentRuleAudit(rule *server.ContentRule) map[string]interface{} {
+	return map[string]interface{}{
+		"pattern": rule.Pattern,
+		"verdict": rule.Verdict,
+		"kinds":   rule.Kinds,
+		"reason":  rule.Reason,
+		"enabled": rule.Enabled,
+	}
+}
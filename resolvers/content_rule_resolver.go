@@ -0,0 +1,94 @@
+package resolvers
+
+import (
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type ContentRuleResolver struct {
+	rule *server.ContentRule
+}
+
+func (r *ContentRuleResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.rule.ID, 10))
+}
+
+func (r *ContentRuleResolver) Pattern() string {
+	return r.rule.Pattern
+}
+
+func (r *ContentRuleResolver) Verdict() string {
+	return strings.ToUpper(string(r.rule.Verdict))
+}
+
+func (r *ContentRuleResolver) Kinds() []string {
+	kinds := []string{}
+	for _, kind := range r.rule.Kinds {
+		kinds = append(kinds, strings.ToUpper(string(kind)))
+	}
+
+	return kinds
+}
+
+func (r *ContentRuleResolver) Reason() *string {
+	if r.rule.Reason == "" {
+		return nil
+	}
+
+	return &r.rule.Reason
+}
+
+func (r *ContentRuleResolver) Enabled() bool {
+	return r.rule.Enabled
+}
+
+func (r *ContentRuleResolver) CreatedAt() Timestamp {
+	return Timestamp{r.rule.CreatedAt}
+}
+
+type ContentFlagResolver struct {
+	server *server.Server
+
+	flag *server.ContentFlag
+}
+
+func (r *ContentFlagResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.flag.ID, 10))
+}
+
+func (r *ContentFlagResolver) Kind() string {
+	return strings.ToUpper(string(r.flag.Kind))
+}
+
+// ContentID is the post's, comment's or message's ID
+func (r *ContentFlagResolver) ContentID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.flag.ContentID, 10))
+}
+
+func (r *ContentFlagResolver) Author() (*UserResolver, error) {
+	user, err := r.server.UserByID(r.flag.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserResolver{server: r.server, user: user}, nil
+}
+
+func (r *ContentFlagResolver) Text() string {
+	return r.flag.Text
+}
+
+func (r *ContentFlagResolver) Verdict() string {
+	return strings.ToUpper(string(r.flag.Verdict))
+}
+
+func (r *ContentFlagResolver) Reason() *string {
+	return r.flag.Reason
+}
+
+func (r *ContentFlagResolver) CreatedAt() Timestamp {
+	return Timestamp{r.flag.CreatedAt}
+}
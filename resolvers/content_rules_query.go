@@ -0,0 +1,90 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// ContentRules - admins only, the rules admins added, disabled ones too
+func (r *Resolver) ContentRules(ctx context.Context) ([]*ContentRuleResolver, error) {
+	if _, err := requireRole(ctx, server.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	rules, err := r.server.ContentRules(false)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*ContentRuleResolver{}
+	for i := range rules {
+		resolvers = append(resolvers, &ContentRuleResolver{rule: &rules[i]})
+	}
+
+	return resolvers, nil
+}
+
+type ContentFlagsResult struct {
+	flags         []*ContentFlagResolver
+	nextPageToken *string
+}
+
+// ContentFlags - moderators only, content the filter flagged or shadow hid,
+// newest first
+func (r *Resolver) ContentFlags(ctx context.Context, args struct {
+	Input *struct {
+		PageToken *string
+		Limit     *int32
+	}
+}) (*ContentFlagsResult, error) {
+	if _, err := requireRole(ctx, server.RoleModerator); err != nil {
+		return nil, err
+	}
+
+	var pageToken *string
+	var limit int32 = 100
+	if args.Input != nil {
+		pageToken = args.Input.PageToken
+		if args.Input.Limit != nil && *args.Input.Limit > 0 && *args.Input.Limit < limit {
+			limit = *args.Input.Limit
+		}
+	}
+
+	beforeID, err := DecodeAfterIDCursor(pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := r.server.ContentFlags(beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextPageToken *string
+	if int32(len(flags)) > limit {
+		flags = flags[:limit]
+		nextPageToken = EncodeAfterIDCursor(flags[limit-1].ID)
+	}
+
+	result := &ContentFlagsResult{
+		flags:         []*ContentFlagResolver{},
+		nextPageToken: nextPageToken,
+	}
+	for i := range flags {
+		result.flags = append(result.flags, &ContentFlagResolver{
+			server: r.server,
+			flag:   &flags[i],
+		})
+	}
+
+	return result, nil
+}
+
+func (r *ContentFlagsResult) ContentFlags() []*ContentFlagResolver {
+	return r.flags
+}
+
+func (r *ContentFlagsResult) NextPageToken() *string {
+	return r.nextPageToken
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/lambdacollective/cobbles-api/server"
 	"github.com/pkg/errors"
 )
 
@@ -51,6 +52,10 @@ func (r *Resolver) GetOrCreateConversation(ctx context.Context, req struct {
 		return nil, errors.New("can't message your own post")
 	}
 
+	if err := r.server.CheckNotBlocked(userID, post.UserID); err != nil {
+		return nil, err
+	}
+
 	convo, exists, err := r.getConversation(getConversationInput{
 		StartedByUserID: userID,
 		PostID:          postID,
@@ -103,7 +108,7 @@ func (r *Resolver) insertConversation(postID, userID, otherUserID int64) (*conve
 		return nil, err
 	}
 
-	row := r.server.ConnPool.QueryRow(sql, args...)
+	row := tx.QueryRow(sql, args...)
 	convo, err := r.scanConversation(row)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to scan convo")
@@ -118,12 +123,22 @@ func (r *Resolver) insertConversation(postID, userID, otherUserID int64) (*conve
 		return nil, err
 	}
 
-	_, err = r.server.ConnPool.Exec(sql, args...)
+	_, err = tx.Exec(sql, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to insert conversation:user mapping")
 	}
 
 	convo.userIDs = []int64{userID, otherUserID}
+
+	err = r.server.PublishRealtime(tx, server.RealtimeEvent{
+		Kind:           server.RealtimeConversationAdded,
+		UserIDs:        convo.userIDs,
+		ConversationID: convo.id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
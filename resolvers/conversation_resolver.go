@@ -35,7 +35,7 @@ func (c *ConversationResolver) Post() (*PostResolver, error) {
 	if c.post != nil {
 		// ugh we should use dataloader or something, annoying to propagate everything
 		// im just gonna let neighborhoods n+1 for now _if_ theyre selected
-		return &PostResolver{c.server, c.post, nil}, nil
+		return &PostResolver{server: c.server, post: c.post}, nil
 	}
 
 	post, exists, err := c.resolver.getPost(c.conversation.postID)
@@ -47,7 +47,7 @@ func (c *ConversationResolver) Post() (*PostResolver, error) {
 		return nil, errors.New("post not found")
 	}
 
-	return &PostResolver{c.server, post, nil}, nil
+	return &PostResolver{server: c.server, post: post}, nil
 }
 
 func (c *ConversationResolver) StartedBy() (*UserResolver, error) {
@@ -163,6 +163,9 @@ func (c *ConversationResolver) Messages(ctx context.Context, req struct {
 	stmt := newSelectBuilder("id", "from_user_id", "conversation_id", "body", "created_at").
 		From("messages").
 		Where(sq.Eq{"conversation_id": c.conversation.id}).
+		Where(server.UnblockedSQL("from_user_id", false), userID).
+		// shadow hidden, only its sender still sees it
+		Where("(hidden is false or from_user_id = ?)", userID).
 		OrderBy("created_at desc").
 		Limit(uint64(limit + 1))
 
@@ -51,6 +51,15 @@ func (r *Resolver) Conversations(ctx context.Context, req struct {
 		where user_id=$1
 			and (post_id=$2 or $2 is null)
 		)
+		-- leave out conversations with someone the user blocked
+		and not exists (
+			select 1
+			from conversation_has_users cu
+			join user_blocks b on b.blocked_user_id = cu.user_id
+			where cu.conversation_id = conversations.id
+				and b.user_id = $1
+				and b.kind = 'block'
+		)
 		group by id, started_by_user_id, created_at 
 		order by id asc
 		limit $3
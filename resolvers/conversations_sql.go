@@ -1,9 +1,6 @@
 package resolvers
 
 import (
-	"fmt"
-	"log"
-
 	"github.com/jackc/pgx"
 	sq "gopkg.in/Masterminds/squirrel.v1"
 )
@@ -15,62 +12,6 @@ type getConversationInput struct {
 	PostID          int64
 }
 
-type conversationParticipant struct {
-	conversationID int64
-	userID         int64
-
-	userName *string
-}
-
-func (r *Resolver) notifyConversationParticipants(conversationID int64, senderID int64) error {
-	rows, err := r.server.ConnPool.Query(`
-			select conversation_id, user_id, users.name
-			from conversation_has_users
-			left join users on users.id = user_id
-			where conversation_id = $1
-		`, conversationID)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	var conversationParticipants []*conversationParticipant
-	for rows.Next() {
-		var c conversationParticipant
-		err := rows.Scan(&c.conversationID, &c.userID, &c.userName)
-		if err != nil {
-			return err
-		}
-
-		conversationParticipants = append(conversationParticipants, &c)
-	}
-
-	if err := rows.Err(); err != nil {
-		return err
-	}
-
-	senderName := "Somebody"
-	for _, p := range conversationParticipants {
-		if p.userID == senderID && p.userName != nil {
-			senderName = *p.userName
-			break
-		}
-	}
-
-	for _, p := range conversationParticipants {
-		if senderID == p.userID {
-			continue
-		}
-
-		notifBody := fmt.Sprintf("%s replied to your post", senderName)
-		if err := r.server.PublishNotificationToUser(p.userID, notifBody); err != nil {
-			log.Println(err)
-		}
-	}
-
-	return nil
-}
-
 func (r *Resolver) getConversation(in getConversationInput) (*conversation, bool, error) {
 	stmt := newSelectBuilder("id", "post_id", "started_by_user_id", "created_at").From("conversations")
 
@@ -0,0 +1,73 @@
+package resolvers
+
+import (
+	"context"
+)
+
+// RequestEmailLogin emails a login link. Logged in users get a link that
+// confirms the email for their account instead, see ConfirmEmail.
+func (r *Resolver) RequestEmailLogin(ctx context.Context, args struct {
+	Input struct {
+		Email string
+	}
+}) (*bool, error) {
+	var userID *int64
+	if currentUserID, err := ctxUserID(ctx); err == nil {
+		userID = &currentUserID
+	}
+
+	if err := r.server.RequestEmailLogin(args.Input.Email, userID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// LoginWithEmail uses the token from an emailed login link, creating a new
+// user if none has the email yet
+func (r *Resolver) LoginWithEmail(ctx context.Context, args struct {
+	Input struct {
+		Token    string
+		FCMToken *string
+		DeviceID *string
+	}
+}) (*LoginUserResultResolver, error) {
+	userID, err := r.server.LoginWithEmailToken(args.Input.Token, args.Input.FCMToken)
+	if err != nil {
+		return nil, err
+	}
+
+	authTokens, err := r.server.IssueAuthTokens(userID, args.Input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginUserResultResolver{
+		server: r.server,
+		tokens: authTokens,
+	}, nil
+}
+
+// ConfirmEmail uses the token from a link emailed to a logged in user, adding
+// the email to their account. Only the user who asked for the link can.
+func (r *Resolver) ConfirmEmail(ctx context.Context, args struct {
+	Input struct {
+		Token string
+	}
+}) (*UserResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.server.ConfirmEmail(userID, args.Input.Token); err != nil {
+		return nil, err
+	}
+
+	user, err := r.server.UserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserResolver{server: r.server, user: user}, nil
+}
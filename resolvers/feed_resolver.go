@@ -2,12 +2,19 @@ package resolvers
 
 import (
 	"context"
+	"errors"
+
+	"github.com/jackc/pgx"
+	"github.com/lambdacollective/cobbles-api/server"
 )
 
 type FeedInput struct {
 	PageToken *string
 	Tags      *[]string
 	Limit     *int32
+
+	// NeighborhoodSlug defaults to the current user's home neighborhood
+	NeighborhoodSlug *string
 }
 
 type FeedResult struct {
@@ -29,11 +36,95 @@ func (r *Resolver) Feed(ctx context.Context, req struct {
 		limit = *req.Input.Limit
 	}
 
+	neighborhood, err := r.feedNeighborhood(ctx, req.Input.NeighborhoodSlug)
+	if err != nil {
+		return nil, err
+	}
+
 	postResolvers, nextPageToken, err := resolvePosts(ctx, r.server, resolvePostsInput{
 		// like a twitter feed, most recent at top
-		Tags:      req.Input.Tags,
-		PageToken: req.Input.PageToken,
-		Limit:     limit,
+		Tags:           req.Input.Tags,
+		NeighborhoodID: neighborhood.ID,
+		PageToken:      req.Input.PageToken,
+		Limit:          limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeedResult{
+		posts:         postResolvers,
+		nextPageToken: nextPageToken,
+	}, nil
+}
+
+// feedNeighborhood resolves the neighborhood a feed is scoped to. Anonymous
+// requests get the default neighborhood.
+func (r *Resolver) feedNeighborhood(ctx context.Context, slug *string) (*server.Neighborhood, error) {
+	if slug != nil {
+		neighborhood, err := r.server.NeighborhoodBySlug(*slug)
+		switch {
+		case err == pgx.ErrNoRows:
+			return nil, errors.New("neighborhood not found")
+		case err != nil:
+			return nil, err
+		}
+
+		return neighborhood, nil
+	}
+
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return r.server.NeighborhoodBySlug(server.DefaultNeighborhoodSlug)
+	}
+
+	return r.server.HomeNeighborhood(userID)
+}
+
+const (
+	defaultNearbyRadiusMeters = 1000
+	maxNearbyRadiusMeters     = 50000
+)
+
+type NearbyFeedInput struct {
+	Lat          float64
+	Lng          float64
+	RadiusMeters *int32
+
+	PageToken *string
+	Limit     *int32
+}
+
+// NearbyFeed - posts made around a location, closest first
+func (r *Resolver) NearbyFeed(ctx context.Context, req struct {
+	Input NearbyFeedInput
+}) (*FeedResult, error) {
+	near := server.Location{Lat: req.Input.Lat, Lng: req.Input.Lng}
+	if !near.Valid() {
+		return nil, errors.New("invalid location")
+	}
+
+	var radiusMeters int32
+	if req.Input.RadiusMeters == nil || *req.Input.RadiusMeters <= 0 {
+		radiusMeters = defaultNearbyRadiusMeters
+	} else if *req.Input.RadiusMeters > maxNearbyRadiusMeters {
+		radiusMeters = maxNearbyRadiusMeters
+	} else {
+		radiusMeters = *req.Input.RadiusMeters
+	}
+
+	var limit int32
+	if req.Input.Limit == nil || *req.Input.Limit == 0 || *req.Input.Limit > 100 {
+		limit = 100
+	} else {
+		limit = *req.Input.Limit
+	}
+
+	postResolvers, nextPageToken, err := resolvePosts(ctx, r.server, resolvePostsInput{
+		Near:         &near,
+		RadiusMeters: float64(radiusMeters),
+		PageToken:    req.Input.PageToken,
+		Limit:        limit,
 	})
 	if err != nil {
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/graph-gophers/graphql-go/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,6 +13,9 @@ func TestFeedResolver(t *testing.T) {
 	harness := NewTestHarness(t)
 	harness.ResetDB()
 
+	var userID int64 = 1
+	harness.MustCreateUser(userID)
+
 	for i := 0; i < 6; i++ {
 		iStr := strconv.Itoa(i)
 
@@ -44,6 +48,7 @@ func TestFeedResolver(t *testing.T) {
 		}
 
 		harness.MustExec(ExecInput{
+			UserID: userID,
 			Variables: map[string]interface{}{
 				"input": inputVars,
 			},
@@ -63,6 +68,7 @@ func TestFeedResolver(t *testing.T) {
 			t.Run(fmt.Sprintf("args: %s", args), func(t *testing.T) {
 				harness.GQLAssert("should return posts", GQLAssertInput{
 					ExecInput: ExecInput{
+						UserID: userID,
 						Query: fmt.Sprintf(`
 						{
 							feed(%s) {
@@ -139,6 +145,7 @@ func TestFeedResolver(t *testing.T) {
 
 		var res1 map[string]interface{}
 		harness.MustExec(ExecInput{
+			UserID: userID,
 			Query: `
 			{
 				feed(input: {limit: 2}) {
@@ -160,6 +167,7 @@ func TestFeedResolver(t *testing.T) {
 
 		var res2 map[string]interface{}
 		harness.MustExec(ExecInput{
+			UserID: userID,
 			Query: fmt.Sprintf(`
 			{
 				feed(input: {pageToken: "%s", limit: 2}) {
@@ -181,6 +189,7 @@ func TestFeedResolver(t *testing.T) {
 
 		var res3 map[string]interface{}
 		harness.Exec(ExecInput{
+			UserID: userID,
 			Query: fmt.Sprintf(`
 			{
 				feed(input: {limit: 2, pageToken: "%s"}) {
@@ -196,6 +205,7 @@ func TestFeedResolver(t *testing.T) {
 		harness := NewTestHarness(t)
 		harness.GQLAssert("should select posts with ANY matching tag", GQLAssertInput{
 			ExecInput: ExecInput{
+				UserID: userID,
 				Query: `
 				{
 					feed(input: {tags: ["for sale"]}) {
@@ -227,3 +237,197 @@ func TestFeedResolver(t *testing.T) {
 		})
 	})
 }
+
+func TestFeedNeighborhoods(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	// neighborhoods aren't truncated by ResetDB
+	_, err := connPool.Exec(`
+		insert into neighborhoods (name, slug, created_at, updated_at)
+		select 'Eastie', 'eastie', now(), now()
+		where not exists (select 1 from neighborhoods where slug = 'eastie')
+	`)
+	require.NoError(t, err)
+
+	var (
+		southieUserID int64 = 1
+		eastieUserID  int64 = 2
+	)
+
+	harness.MustCreateUser(southieUserID)
+	harness.MustCreateUser(eastieUserID)
+
+	harness.MustExec(ExecInput{
+		UserID: eastieUserID,
+		Query: `
+			mutation {
+				updateUser(input: {neighborhoodSlug: "eastie"}) {
+					id
+				}
+			}
+		`}, nil)
+
+	for _, userID := range []int64{southieUserID, eastieUserID} {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Variables: map[string]interface{}{
+				"input": map[string]interface{}{
+					"title":  fmt.Sprintf("posted by %d", userID),
+					"kind":   "TEXT",
+					"poster": "default",
+				},
+			},
+			Query: `
+				mutation CreatePost($input: CreatePostInput!) {
+					createPost(input: $input) {
+						id
+					}
+				}
+			`}, nil)
+	}
+
+	expectFeed := func(userID int64, args string, title string, slug string) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: userID,
+				Query: fmt.Sprintf(`
+				{
+					feed(%s) {
+						posts {
+							title
+							neighborhood {
+								slug
+							}
+						}
+					}
+				}`, args),
+			},
+			ExpectedResult: map[string]interface{}{
+				"feed": map[string]interface{}{
+					"posts": []map[string]interface{}{
+						{
+							"title": title,
+							"neighborhood": map[string]interface{}{
+								"slug": slug,
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	t.Run("defaults to home neighborhood", func(t *testing.T) {
+		expectFeed(southieUserID, "", "posted by 1", "southie")
+		expectFeed(eastieUserID, "", "posted by 2", "eastie")
+	})
+
+	t.Run("other neighborhood by slug", func(t *testing.T) {
+		expectFeed(southieUserID, `input: {neighborhoodSlug: "eastie"}`, "posted by 2", "eastie")
+	})
+
+	t.Run("unknown neighborhood", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: southieUserID,
+				Query: `
+				{
+					feed(input: {neighborhoodSlug: "atlantis"}) {
+						nextPageToken
+					}
+				}`,
+			},
+			ExpectedErrors: []*errors.QueryError{
+				{
+					Message: "neighborhood not found",
+				},
+			},
+		})
+	})
+}
+
+func TestNearbyFeed(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var userID int64 = 1
+	harness.MustCreateUser(userID)
+
+	// roughly 0m, 200m and 2km north of Broadway station
+	for i, lat := range []float64{42.3426, 42.3444, 42.3606} {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Variables: map[string]interface{}{
+				"input": map[string]interface{}{
+					"title":  "title " + strconv.Itoa(i),
+					"kind":   "TEXT",
+					"poster": "default",
+					"location": map[string]interface{}{
+						"lat": lat,
+						"lng": -71.0570,
+					},
+				},
+			},
+			Query: `
+				mutation CreatePost($input: CreatePostInput!) {
+					createPost(input: $input) {
+						id
+					}
+				}
+			`}, nil)
+	}
+
+	// no location, never nearby
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: `
+			mutation {
+				createPost(input: {title: "nowhere", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}
+		`}, nil)
+
+	nearbyTitles := func(args string) ([]string, interface{}) {
+		var res map[string]interface{}
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+			{
+				nearbyFeed(input: {lat: 42.3426, lng: -71.0570, %s}) {
+					posts {
+						title
+					}
+					nextPageToken
+				}
+			}`, args),
+		}, &res)
+
+		feed := res["nearbyFeed"].(map[string]interface{})
+		var titles []string
+		for _, post := range feed["posts"].([]interface{}) {
+			titles = append(titles, post.(map[string]interface{})["title"].(string))
+		}
+
+		return titles, feed["nextPageToken"]
+	}
+
+	t.Run("closest first within radius", func(t *testing.T) {
+		titles, _ := nearbyTitles("radiusMeters: 1000")
+		require.Equal(t, []string{"title 0", "title 1"}, titles)
+
+		titles, _ = nearbyTitles("radiusMeters: 5000")
+		require.Equal(t, []string{"title 0", "title 1", "title 2"}, titles)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		titles, pageToken := nearbyTitles("radiusMeters: 5000, limit: 2")
+		require.Equal(t, []string{"title 0", "title 1"}, titles)
+		require.NotNil(t, pageToken)
+
+		titles, pageToken = nearbyTitles(fmt.Sprintf(`radiusMeters: 5000, limit: 2, pageToken: "%s"`, pageToken))
+		require.Equal(t, []string{"title 2"}, titles)
+		require.Nil(t, pageToken)
+	})
+}
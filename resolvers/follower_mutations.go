@@ -2,6 +2,7 @@ package resolvers
 
 import (
 	"context"
+	"log"
 )
 
 // CreateFollower ...
@@ -18,6 +19,10 @@ func (r *Resolver) CreateFollower(ctx context.Context, args struct {
 		return nil, err
 	}
 
+	if err := r.server.NotifyFollow(userID, int64(args.ID)); err != nil {
+		log.Println(err)
+	}
+
 	return &FollowerResolver{server: r.server, follower: follower}, nil
 }
 
@@ -1,6 +1,8 @@
 package resolvers
 
-import "context"
+import (
+	"context"
+)
 
 // LikePost ...
 func (r *Resolver) LikePost(ctx context.Context, args struct {
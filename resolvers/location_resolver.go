@@ -0,0 +1,24 @@
+package resolvers
+
+import "github.com/lambdacollective/cobbles-api/server"
+
+type LocationInput struct {
+	Lat float64
+	Lng float64
+}
+
+func (in *LocationInput) location() server.Location {
+	return server.Location{Lat: in.Lat, Lng: in.Lng}
+}
+
+type LocationResolver struct {
+	location server.Location
+}
+
+func (r *LocationResolver) Lat() float64 {
+	return r.location.Lat
+}
+
+func (r *LocationResolver) Lng() float64 {
+	return r.location.Lng
+}
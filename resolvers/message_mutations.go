@@ -5,12 +5,14 @@ import (
 	"log"
 	"strconv"
 
+	"github.com/lambdacollective/cobbles-api/server"
 	"github.com/pkg/errors"
 )
 
 type SendMessageInput struct {
-	ConversationID    string `validate:"required"`
-	DestinationUserID string `validate:"required"`
+	ConversationID string `validate:"required"`
+	// DestinationUserID defaults to the other participant of the conversation
+	DestinationUserID *string
 	Body              string `validate:"required"`
 }
 
@@ -42,54 +44,72 @@ func (r *Resolver) SendMessage(ctx context.Context, in struct {
 		return nil, err
 	}
 
-	sql, args, err := newInsertBuilder("messages").
-		Columns("from_user_id", "conversation_id", "body").
-		Values(userID, convoID, req.Body).
-		Suffix("RETURNING id, from_user_id, conversation_id, body, created_at").
-		ToSql()
+	destUserID, err := convoDestination(convo, userID, req.DestinationUserID)
 	if err != nil {
 		return nil, err
 	}
 
-	row := r.server.ConnPool.QueryRow(sql, args...)
-	msg, err := r.scanMessage(row)
-	if err != nil {
+	if err := r.server.CheckNotBlocked(userID, destUserID); err != nil {
 		return nil, err
 	}
 
-	if err := r.notifyConversationParticipants(convoID, userID); err != nil {
-		log.Println(err)
+	filtered, err := r.server.FilterContent(server.ContentKindMessage, req.Body)
+	if err != nil {
+		return nil, err
 	}
 
-	user, err := r.server.UserByID(userID)
+	// the message and its notification are stored together, its push goes
+	// out from the outbox so a push outage can't fail the send
+	tx, err := r.server.ConnPool.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	destUserID, err := strconv.ParseInt(req.DestinationUserID, 10, 64)
+	sql, args, err := newInsertBuilder("messages").
+		Columns("from_user_id", "conversation_id", "body", "hidden").
+		Values(userID, convoID, req.Body, filtered.Hidden()).
+		Suffix("RETURNING id, from_user_id, conversation_id, body, created_at").
+		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	var fcmToken string
-	// get the destination user's fcm token
-	err = r.server.ConnPool.QueryRow(`
-		select fcm_token from users where id = $1
-	`, destUserID).Scan(
-		&fcmToken,
-	)
+	msg, err := r.scanMessage(tx.QueryRow(sql, args...))
 	if err != nil {
 		return nil, err
 	}
 
-	// send notification
-	msgType := "messaging"
-	if msg.id > 0 {
-		err = r.server.SendNotification(fcmToken, msgType, msg.id, req.Body, *user.Name, convoID, userID)
-		if err != nil {
+	// shadow hidden messages look sent, but nobody else hears of them
+	recipients := convo.userIDs
+	if filtered.Hidden() {
+		recipients = []int64{userID}
+	} else {
+		if err := r.server.NotifyMessage(tx, userID, destUserID, convoID, msg.id, msg.body); err != nil {
 			return nil, err
 		}
-	} else {
+	}
+
+	err = r.server.PublishRealtime(tx, server.RealtimeEvent{
+		Kind:           server.RealtimeMessageAdded,
+		UserIDs:        recipients,
+		ConversationID: convoID,
+		MessageID:      msg.id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := r.server.RecordContentFlag(server.ContentKindMessage, msg.id, userID, req.Body, filtered); err != nil {
+		log.Println(err)
+	}
+
+	user, err := r.server.UserByID(userID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -134,6 +154,33 @@ func (r *Resolver) validateConvoOwnership(userID int64, convoID int64) (*convers
 	return convo, nil
 }
 
+// convoDestination picks who a message from userID goes to, only another
+// participant of the conversation may be notified
+func convoDestination(convo *conversation, userID int64, destUserID *string) (int64, error) {
+	if destUserID == nil {
+		for _, convoUserID := range convo.userIDs {
+			if convoUserID != userID {
+				return convoUserID, nil
+			}
+		}
+
+		return 0, errors.New("convo: no other participant")
+	}
+
+	id, err := strconv.ParseInt(*destUserID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, convoUserID := range convo.userIDs {
+		if convoUserID == id && id != userID {
+			return id, nil
+		}
+	}
+
+	return 0, errors.New("convo: destination user is not a participant")
+}
+
 func (s *SendMessageResult) Message() *MessageResolver {
 	return s.message
 }
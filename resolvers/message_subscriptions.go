@@ -0,0 +1,148 @@
+package resolvers
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/jackc/pgx"
+	"github.com/lambdacollective/cobbles-api/server"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// MessageAdded is each message sent in one of the current user's
+// conversations from now on, including their own
+func (r *Resolver) MessageAdded(ctx context.Context, args struct {
+	ConversationID graphql.ID
+}) (<-chan *MessageResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	convoID, err := strconv.ParseInt(string(args.ConversationID), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	convo, err := r.validateConvoOwnership(userID, convoID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, unsubscribe := r.server.SubscribeRealtime(userID)
+	c := make(chan *MessageResolver)
+	go func() {
+		defer close(c)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if e.Kind != server.RealtimeMessageAdded || e.ConversationID != convoID {
+					continue
+				}
+
+				msg, err := r.visibleMessage(userID, e.MessageID)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				if msg == nil {
+					continue
+				}
+				msg.conversation = convo
+
+				select {
+				case <-ctx.Done():
+					return
+				case c <- msg:
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// ConversationUpdated is each of the current user's conversations when it's
+// started or gets a message, from now on
+func (r *Resolver) ConversationUpdated(ctx context.Context) (<-chan *ConversationResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, unsubscribe := r.server.SubscribeRealtime(userID)
+	c := make(chan *ConversationResolver)
+	go func() {
+		defer close(c)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if e.Kind != server.RealtimeMessageAdded && e.Kind != server.RealtimeConversationAdded {
+					continue
+				}
+
+				convo, err := r.validateConvoOwnership(userID, e.ConversationID)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case c <- &ConversationResolver{
+					resolver:     r,
+					server:       r.server,
+					conversation: convo,
+				}:
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// visibleMessage is a message as userID sees it in its conversation's
+// messages, nil if they can't
+func (r *Resolver) visibleMessage(userID, messageID int64) (*MessageResolver, error) {
+	sql, args, err := newSelectBuilder("id", "from_user_id", "conversation_id", "body", "created_at").
+		From("messages").
+		Where(sq.Eq{"id": messageID}).
+		Where(server.UnblockedSQL("from_user_id", false), userID).
+		Where("(hidden is false or from_user_id = ?)", userID).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := r.scanMessage(r.server.ConnPool.QueryRow(sql, args...))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.server.UserByID(msg.fromUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageResolver{
+		resolver: r,
+		server:   r.server,
+		message:  msg,
+		from:     user,
+	}, nil
+}
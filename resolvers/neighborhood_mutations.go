@@ -0,0 +1,246 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/jackc/pgx"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// neighborhoodGeography converts the GraphQL center and boundary inputs,
+// leaving nil ones nil
+func neighborhoodGeography(center *LocationInput, boundary *[]LocationInput) (*server.Location, *[]server.Location) {
+	var outCenter *server.Location
+	if center != nil {
+		loc := center.location()
+		outCenter = &loc
+	}
+
+	var outBoundary *[]server.Location
+	if boundary != nil {
+		locations := make([]server.Location, len(*boundary))
+		for i, loc := range *boundary {
+			locations[i] = loc.location()
+		}
+		outBoundary = &locations
+	}
+
+	return outCenter, outBoundary
+}
+
+// CreateNeighborhood - admin only
+func (r *Resolver) CreateNeighborhood(ctx context.Context, args struct {
+	Input struct {
+		Slug     string
+		Name     string
+		ZIPCodes *[]string
+		Center   *LocationInput
+		Boundary *[]LocationInput
+	}
+}) (*NeighborhoodResolver, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	center, boundary := neighborhoodGeography(args.Input.Center, args.Input.Boundary)
+	in := server.NeighborhoodInput{
+		Slug:     &args.Input.Slug,
+		Name:     &args.Input.Name,
+		ZIPCodes: args.Input.ZIPCodes,
+		Center:   center,
+		Boundary: boundary,
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	neighborhood, err := r.server.CreateNeighborhood(tx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.audit(
+		tx,
+		adminID,
+		server.AuditActionCreateNeighborhood,
+		server.AuditTargetNeighborhood,
+		neighborhood.ID,
+		nil,
+		neighborhood,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &NeighborhoodResolver{
+		server:       r.server,
+		neighborhood: neighborhood,
+	}, nil
+}
+
+// UpdateNeighborhood - admin only, renames, edits or (un)retires a
+// neighborhood
+func (r *Resolver) UpdateNeighborhood(ctx context.Context, args struct {
+	Input struct {
+		Slug     string
+		NewSlug  *string
+		Name     *string
+		ZIPCodes *[]string
+		Center   *LocationInput
+		Boundary *[]LocationInput
+		Retired  *bool
+	}
+}) (*NeighborhoodResolver, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	neighborhood, err := neighborhoodBySlug(r.server, args.Input.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	center, boundary := neighborhoodGeography(args.Input.Center, args.Input.Boundary)
+	in := server.NeighborhoodInput{
+		Slug:     args.Input.NewSlug,
+		Name:     args.Input.Name,
+		ZIPCodes: args.Input.ZIPCodes,
+		Center:   center,
+		Boundary: boundary,
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.server.NeighborhoodForUpdate(tx, neighborhood.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	neighborhood, err = r.server.UpdateNeighborhood(tx, before.ID, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Input.Retired != nil && *args.Input.Retired != (neighborhood.RetiredAt != nil) {
+		neighborhood, err = r.server.SetNeighborhoodRetired(tx, neighborhood.ID, *args.Input.Retired)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.audit(
+		tx,
+		adminID,
+		server.AuditActionUpdateNeighborhood,
+		server.AuditTargetNeighborhood,
+		neighborhood.ID,
+		before,
+		neighborhood,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &NeighborhoodResolver{
+		server:       r.server,
+		neighborhood: neighborhood,
+	}, nil
+}
+
+// MergeNeighborhoods - admin only, moves everything in fromSlug to intoSlug
+// and retires fromSlug. Returns the neighborhood merged into.
+func (r *Resolver) MergeNeighborhoods(ctx context.Context, args struct {
+	Input struct {
+		FromSlug string
+		IntoSlug string
+	}
+}) (*NeighborhoodResolver, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := neighborhoodBySlug(r.server, args.Input.FromSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	into, err := neighborhoodBySlug(r.server, args.Input.IntoSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// locked in id order, the same as MergeNeighborhoods locks them
+	before := map[int64]*server.Neighborhood{}
+	ids := []int64{from.ID, into.ID}
+	if into.ID < from.ID {
+		ids = []int64{into.ID, from.ID}
+	}
+	for _, id := range ids {
+		if before[id], err = r.server.NeighborhoodForUpdate(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	neighborhood, err := r.server.MergeNeighborhoods(tx, from.ID, into.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := r.server.NeighborhoodForUpdate(tx, from.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the merged neighborhood is the target, into changes too
+	if err := r.audit(
+		tx,
+		adminID,
+		server.AuditActionMergeNeighborhoods,
+		server.AuditTargetNeighborhood,
+		from.ID,
+		map[string]*server.Neighborhood{"from": before[from.ID], "into": before[into.ID]},
+		map[string]*server.Neighborhood{"from": merged, "into": neighborhood},
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &NeighborhoodResolver{
+		server:       r.server,
+		neighborhood: neighborhood,
+	}, nil
+}
+
+func neighborhoodBySlug(s *server.Server, slug string) (*server.Neighborhood, error) {
+	neighborhood, err := s.NeighborhoodBySlug(slug)
+	if err == pgx.ErrNoRows {
+		return nil, server.ErrNeighborhoodNotFound
+	}
+
+	return neighborhood, err
+}
@@ -0,0 +1,237 @@
+package resolvers
+
+import (
+	"testing"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeighborhoodAdministration(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	// neighborhoods aren't truncated by ResetDB
+	_, err := connPool.Exec(`delete from neighborhoods where slug in ('fort-point', 'seaport')`)
+	require.NoError(t, err)
+
+	var (
+		adminUserID int64 = 1
+		userID      int64 = 2
+	)
+
+	harness.MustCreateUser(adminUserID)
+	harness.MustCreateUser(userID)
+
+	_, err = connPool.Exec(`update users set roles = '{admin}' where id = $1`, adminUserID)
+	require.NoError(t, err)
+
+	t.Run("admins only", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: userID,
+				Query: `
+				mutation {
+					createNeighborhood(input: {slug: "fort-point", name: "Fort Point"}) {
+						id
+					}
+				}`,
+			},
+			ExpectedErrors: []*errors.QueryError{
+				{
+					Message: "unauthorized",
+				},
+			},
+		})
+	})
+
+	for _, slug := range []string{"fort-point", "seaport"} {
+		harness.MustExec(ExecInput{
+			UserID: adminUserID,
+			Variables: map[string]interface{}{
+				"input": map[string]interface{}{
+					"slug":     slug,
+					"name":     slug,
+					"zipCodes": []string{"02210"},
+				},
+			},
+			Query: `
+				mutation CreateNeighborhood($input: CreateNeighborhoodInput!) {
+					createNeighborhood(input: $input) {
+						id
+					}
+				}
+			`}, nil)
+	}
+
+	harness.GQLAssert("rename", GQLAssertInput{
+		ExecInput: ExecInput{
+			UserID: adminUserID,
+			Query: `
+			mutation {
+				updateNeighborhood(input: {slug: "seaport", name: "Seaport"}) {
+					slug
+					name
+					retired
+				}
+			}`,
+		},
+		ExpectedResult: map[string]interface{}{
+			"updateNeighborhood": map[string]interface{}{
+				"slug":    "seaport",
+				"name":    "Seaport",
+				"retired": false,
+			},
+		},
+	})
+
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: `
+			mutation {
+				updateUser(input: {neighborhoodSlug: "fort-point"}) {
+					id
+				}
+			}
+		`}, nil)
+
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: `
+			mutation {
+				createPost(input: {title: "by the channel", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}
+		`}, nil)
+
+	harness.GQLAssert("merge", GQLAssertInput{
+		ExecInput: ExecInput{
+			UserID: adminUserID,
+			Query: `
+			mutation {
+				mergeNeighborhoods(input: {fromSlug: "fort-point", intoSlug: "seaport"}) {
+					slug
+					zipCodes
+				}
+			}`,
+		},
+		ExpectedResult: map[string]interface{}{
+			"mergeNeighborhoods": map[string]interface{}{
+				"slug":     "seaport",
+				"zipCodes": []string{"02210"},
+			},
+		},
+	})
+
+	t.Run("posts and users moved", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: userID,
+				Query: `
+				{
+					currentUser {
+						neighborhood {
+							slug
+						}
+					}
+					feed {
+						posts {
+							title
+						}
+					}
+				}`,
+			},
+			ExpectedResult: map[string]interface{}{
+				"currentUser": map[string]interface{}{
+					"neighborhood": map[string]interface{}{
+						"slug": "seaport",
+					},
+				},
+				"feed": map[string]interface{}{
+					"posts": []map[string]interface{}{
+						{
+							"title": "by the channel",
+						},
+					},
+				},
+			},
+		})
+	})
+
+	t.Run("merged neighborhood is retired", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: userID,
+				Query: `
+				mutation {
+					updateUser(input: {neighborhoodSlug: "fort-point"}) {
+						id
+					}
+				}`,
+			},
+			ExpectedErrors: []*errors.QueryError{
+				{
+					Message: "neighborhood has been retired",
+				},
+			},
+		})
+
+		var res struct {
+			Neighborhoods []struct {
+				Slug       string
+				Retired    bool
+				MergedInto *struct {
+					Slug string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: adminUserID,
+			Query: `
+				{
+					neighborhoods(input: {includeRetired: true}) {
+						slug
+						retired
+						mergedInto {
+							slug
+						}
+					}
+				}
+			`}, &res)
+
+		var found bool
+		for _, n := range res.Neighborhoods {
+			if n.Slug == "fort-point" {
+				found = true
+				require.True(t, n.Retired)
+				require.NotNil(t, n.MergedInto)
+				require.Equal(t, "seaport", n.MergedInto.Slug)
+			}
+		}
+		require.True(t, found)
+	})
+
+	t.Run("a failed update changes nothing", func(t *testing.T) {
+		name := func() string {
+			var name string
+			err := connPool.QueryRow(`select name from neighborhoods where slug = 'southie'`).Scan(&name)
+			require.NoError(t, err)
+			return name
+		}
+		before := name()
+
+		// the default neighborhood can't be retired
+		errs := harness.Exec(ExecInput{
+			UserID: adminUserID,
+			Query: `
+				mutation {
+					updateNeighborhood(input: {slug: "southie", name: "Renamed", retired: true}) {
+						name
+					}
+				}`,
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, before, name())
+	})
+}
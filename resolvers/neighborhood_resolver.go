@@ -1,10 +1,38 @@
 package resolvers
 
 import (
+	"context"
+	"errors"
+
 	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/lambdacollective/cobbles-api/server"
 )
 
+// NeighborhoodForLocation - Neighborhood whose boundary contains a coordinate
+func (r *Resolver) NeighborhoodForLocation(ctx context.Context, args struct {
+	Lat float64
+	Lng float64
+}) (*NeighborhoodResolver, error) {
+	loc := server.Location{Lat: args.Lat, Lng: args.Lng}
+	if !loc.Valid() {
+		return nil, errors.New("invalid location")
+	}
+
+	neighborhood, err := r.server.NeighborhoodForLocation(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if neighborhood == nil {
+		return nil, nil
+	}
+
+	return &NeighborhoodResolver{
+		server:       r.server,
+		neighborhood: neighborhood,
+	}, nil
+}
+
 type NeighborhoodResolver struct {
 	server *server.Server
 
@@ -22,3 +50,52 @@ func (r *NeighborhoodResolver) Slug() string {
 func (r *NeighborhoodResolver) Name() string {
 	return r.neighborhood.Name
 }
+
+func (r *NeighborhoodResolver) ZIPCodes() []string {
+	if r.neighborhood.ZIPCodes == nil {
+		return []string{}
+	}
+
+	return r.neighborhood.ZIPCodes
+}
+
+func (r *NeighborhoodResolver) Center() *LocationResolver {
+	if r.neighborhood.Center == nil {
+		return nil
+	}
+
+	return &LocationResolver{location: *r.neighborhood.Center}
+}
+
+func (r *NeighborhoodResolver) Boundary() *[]*LocationResolver {
+	if r.neighborhood.Boundary == nil {
+		return nil
+	}
+
+	resolvers := make([]*LocationResolver, 0, len(r.neighborhood.Boundary))
+	for _, loc := range r.neighborhood.Boundary {
+		resolvers = append(resolvers, &LocationResolver{location: loc})
+	}
+
+	return &resolvers
+}
+
+func (r *NeighborhoodResolver) Retired() bool {
+	return r.neighborhood.RetiredAt != nil
+}
+
+func (r *NeighborhoodResolver) MergedInto() (*NeighborhoodResolver, error) {
+	if r.neighborhood.MergedIntoID == nil {
+		return nil, nil
+	}
+
+	neighborhood, err := r.server.NeighborhoodByID(*r.neighborhood.MergedIntoID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NeighborhoodResolver{
+		server:       r.server,
+		neighborhood: neighborhood,
+	}, nil
+}
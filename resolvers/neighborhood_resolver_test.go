@@ -0,0 +1,90 @@
+package resolvers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeighborhoodAssignment(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	t.Run("by location", func(t *testing.T) {
+		var res struct {
+			NeighborhoodForLocation *struct {
+				Slug string
+			}
+		}
+		forLocation := func(lat, lng float64) {
+			res.NeighborhoodForLocation = nil
+			harness.MustExec(ExecInput{
+				Variables: map[string]interface{}{
+					"lat": lat,
+					"lng": lng,
+				},
+				Query: `
+					query ForLocation($lat: Float!, $lng: Float!) {
+						neighborhoodForLocation(lat: $lat, lng: $lng) {
+							slug
+						}
+					}`,
+			}, &res)
+		}
+
+		forLocation(42.3334, -71.0471)
+		require.NotNil(t, res.NeighborhoodForLocation)
+		assert.Equal(t, "southie", res.NeighborhoodForLocation.Slug)
+
+		// lower Manhattan
+		forLocation(40.7128, -74.0060)
+		assert.Nil(t, res.NeighborhoodForLocation)
+
+		errs := harness.Exec(ExecInput{
+			Query: `query { neighborhoodForLocation(lat: 91, lng: 0) { slug } }`,
+		}, nil)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "invalid location", errs[0].Message)
+	})
+
+	t.Run("by ZIP code", func(t *testing.T) {
+		var res struct {
+			UpdateUser struct {
+				Neighborhood *struct {
+					Slug string
+				}
+			}
+		}
+		updateZIPCode := func(userID int64, zipCode string) {
+			harness.MustCreateUser(userID)
+			res.UpdateUser.Neighborhood = nil
+			harness.MustExec(ExecInput{
+				UserID: userID,
+				Variables: map[string]interface{}{
+					"zipCode": zipCode,
+				},
+				Query: `
+					mutation UpdateZIPCode($zipCode: String!) {
+						updateUser(input: {zipCode: $zipCode}) {
+							neighborhood {
+								slug
+							}
+						}
+					}`,
+			}, &res)
+		}
+
+		updateZIPCode(1, "02127")
+		require.NotNil(t, res.UpdateUser.Neighborhood)
+		assert.Equal(t, "southie", res.UpdateUser.Neighborhood.Slug)
+
+		// ZIP+4 codes match on their first five digits
+		updateZIPCode(2, "02127-1234")
+		require.NotNil(t, res.UpdateUser.Neighborhood)
+		assert.Equal(t, "southie", res.UpdateUser.Neighborhood.Slug)
+
+		updateZIPCode(3, "99999")
+		assert.Nil(t, res.UpdateUser.Neighborhood)
+	})
+}
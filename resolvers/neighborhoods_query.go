@@ -0,0 +1,36 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// Neighborhoods - every neighborhood, retired ones only for admins
+func (r *Resolver) Neighborhoods(ctx context.Context, args struct {
+	Input *struct {
+		IncludeRetired *bool
+	}
+}) ([]*NeighborhoodResolver, error) {
+	includeRetired := args.Input != nil && args.Input.IncludeRetired != nil && *args.Input.IncludeRetired
+	if includeRetired {
+		if _, err := requireRole(ctx, server.RoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+
+	neighborhoods, err := r.server.Neighborhoods(includeRetired)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*NeighborhoodResolver{}
+	for _, n := range neighborhoods {
+		resolvers = append(resolvers, &NeighborhoodResolver{
+			server:       r.server,
+			neighborhood: n,
+		})
+	}
+
+	return resolvers, nil
+}
@@ -0,0 +1,285 @@
+package resolvers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationCoalescing(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var posterID int64 = 1
+	likers := map[int64]string{2: "Sam", 3: "Robin", 4: "Alex", 5: "Jo"}
+	harness.MustCreateUser(posterID)
+	for id, name := range likers {
+		harness.MustCreateUser(id)
+		_, err := connPool.Exec(`update users set name = $2 where id = $1`, id, name)
+		require.NoError(t, err)
+	}
+
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query:  `mutation { registerPushDevice(input: {platform: FCM, token: "phone"}) }`,
+	}, nil)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query: `
+			mutation {
+				createPost(input: {title: "block party", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	like := func(userID int64) {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query:  fmt.Sprintf(`mutation { likePost(id: %s) }`, post.CreatePost.ID),
+		}, nil)
+		harness.DrainOutbox()
+	}
+
+	type notification struct {
+		Content    string
+		ActorCount int32
+		Actor      struct {
+			Name string
+		}
+	}
+	inbox := func() []notification {
+		var res struct {
+			Notifications struct {
+				Notifications []notification
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query:  `query { notifications { notifications { content actorCount actor { name } } } }`,
+		}, &res)
+		return res.Notifications.Notifications
+	}
+
+	t.Run("the first like is pushed right away", func(t *testing.T) {
+		like(2)
+
+		pushes := harness.push.PushesTo(posterID)
+		require.Len(t, pushes, 1)
+		assert.Equal(t, "Sam liked your post", pushes[0].Message.Body)
+	})
+
+	t.Run("likes inside the window coalesce", func(t *testing.T) {
+		like(3)
+		like(4)
+
+		notifications := inbox()
+		require.Len(t, notifications, 1)
+		assert.Equal(t, "Alex and 2 others liked your post", notifications[0].Content)
+		assert.EqualValues(t, 3, notifications[0].ActorCount)
+		assert.Equal(t, "Alex", notifications[0].Actor.Name)
+
+		// held until the window closes
+		assert.Len(t, harness.push.PushesTo(posterID), 1)
+
+		_, err := connPool.Exec(`update outbox_events set next_attempt_at = now() where status = 'pending'`)
+		require.NoError(t, err)
+		harness.DrainOutbox()
+
+		pushes := harness.push.PushesTo(posterID)
+		require.Len(t, pushes, 2)
+		assert.Equal(t, "Alex and 2 others liked your post", pushes[1].Message.Body)
+		assert.Equal(t, pushes[0].Message.CollapseKey, pushes[1].Message.CollapseKey)
+	})
+
+	t.Run("liking again isn't another actor", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: 2,
+			Query:  fmt.Sprintf(`mutation { unlikePost(id: %s) }`, post.CreatePost.ID),
+		}, nil)
+		like(2)
+
+		notifications := inbox()
+		require.Len(t, notifications, 1)
+		assert.Equal(t, "Sam and 2 others liked your post", notifications[0].Content)
+		assert.EqualValues(t, 3, notifications[0].ActorCount)
+		assert.Equal(t, "Sam", notifications[0].Actor.Name)
+	})
+
+	t.Run("a like after the window starts over", func(t *testing.T) {
+		_, err := connPool.Exec(`
+			update notifications set created_at = $1
+		`, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+
+		like(5)
+
+		notifications := inbox()
+		require.Len(t, notifications, 2)
+		assert.Equal(t, "Jo liked your post", notifications[0].Content)
+		assert.EqualValues(t, 1, notifications[0].ActorCount)
+
+		pushes := harness.push.PushesTo(posterID)
+		require.Len(t, pushes, 3)
+		assert.Equal(t, "Jo liked your post", pushes[2].Message.Body)
+	})
+}
+
+func TestNeighborhoodDigest(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		readerID int64 = 1
+		posterID int64 = 2
+		optOutID int64 = 3
+		mutedID  int64 = 4
+	)
+	for _, id := range []int64{readerID, posterID, optOutID, mutedID} {
+		harness.MustCreateUser(id)
+	}
+
+	for _, title := range []string{"yard sale", "lost dog", "road closed"} {
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPost(input: {title: "%s", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}`, title),
+		}, nil)
+	}
+	_, err := connPool.Exec(`update posts set likes = 10 where title = 'lost dog'`)
+	require.NoError(t, err)
+
+	// popular posts that aren't for the reader to see
+	for _, title := range []string{"removed", "processing", "muted"} {
+		userID := posterID
+		if title == "muted" {
+			userID = mutedID
+		}
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPost(input: {title: "%s", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}`, title),
+		}, nil)
+	}
+	_, err = connPool.Exec(`
+		update posts
+		set likes = 100,
+			removed = title = 'removed',
+			processing = title = 'processing'
+		where title in ('removed', 'processing', 'muted')
+	`)
+	require.NoError(t, err)
+
+	harness.MustExec(ExecInput{
+		UserID: readerID,
+		Query: `
+			mutation {
+				updateNotificationSettings(input: {neighborhoodPosts: true, timeZone: "America/New_York"}) {
+					neighborhoodPosts
+				}
+			}`,
+	}, nil)
+	harness.MustExec(ExecInput{
+		UserID: readerID,
+		Query:  `mutation { registerPushDevice(input: {platform: APNS, token: "phone"}) }`,
+	}, nil)
+	harness.MustExec(ExecInput{
+		UserID: readerID,
+		Query:  fmt.Sprintf(`mutation { muteUser(id: %d) }`, mutedID),
+	}, nil)
+
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	now := time.Now().In(loc)
+	morning := time.Date(now.Year(), now.Month(), now.Day(), 8, 30, 0, 0, loc)
+	if morning.After(time.Now()) {
+		morning = morning.Add(-24 * time.Hour)
+	}
+	// the posts need to be from the day before the digest
+	_, err = connPool.Exec(`update posts set created_at = $1`, morning.Add(-time.Hour))
+	require.NoError(t, err)
+
+	srv := harness.resolver.server
+
+	t.Run("not before the digest hour", func(t *testing.T) {
+		sent, err := srv.SendNeighborhoodDigests(morning.Add(-2 * time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+	})
+
+	t.Run("opted in users get the top posts", func(t *testing.T) {
+		sent, err := srv.SendNeighborhoodDigests(morning)
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		harness.DrainOutbox()
+
+		var res struct {
+			Notifications struct {
+				Notifications []struct {
+					Kind    string
+					Content string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: readerID,
+			Query:  `query { notifications { notifications { kind content } } }`,
+		}, &res)
+		require.Len(t, res.Notifications.Notifications, 1)
+		assert.Equal(t, "DIGEST", res.Notifications.Notifications[0].Kind)
+		assert.Equal(t, `"lost dog" and 2 more posts are popular in your neighborhood`, res.Notifications.Notifications[0].Content)
+
+		pushes := harness.push.PushesTo(readerID)
+		require.Len(t, pushes, 1)
+		assert.Equal(t, "Today in Southie", pushes[0].Message.Title)
+	})
+
+	t.Run("once a day", func(t *testing.T) {
+		sent, err := srv.SendNeighborhoodDigests(morning.Add(10 * time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+	})
+
+	t.Run("only one of several workers sends it", func(t *testing.T) {
+		_, err := connPool.Exec(`update notification_settings set last_digest_at = null`)
+		require.NoError(t, err)
+
+		const workers = 4
+		var wg sync.WaitGroup
+		sent := make([]int, workers)
+		errs := make([]error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				sent[i], errs[i] = srv.SendNeighborhoodDigests(morning)
+			}(i)
+		}
+		wg.Wait()
+
+		var total int
+		for i := 0; i < workers; i++ {
+			require.NoError(t, errs[i])
+			total += sent[i]
+		}
+		assert.Equal(t, 1, total)
+	})
+}
@@ -2,8 +2,10 @@ package resolvers
 
 import (
 	"context"
+	"strconv"
 
 	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
 )
 
 type MarkNotificationReadInput struct {
@@ -18,12 +20,25 @@ func (r *Resolver) MarkNotificationRead(ctx context.Context, args struct {
 		return nil, err
 	}
 
-	r.server.ConnPool.Query(`
-		update notifications
-		set read = true
-		where user_id = $1
-			and id = $2
-	`, currentUserID, args.Input.NotificationID)
+	notificationID, err := strconv.ParseInt(string(args.Input.NotificationID), 10, 64)
+	if err != nil {
+		return nil, server.ErrNotificationNotFound
+	}
+
+	if err := r.server.MarkNotificationRead(currentUserID, notificationID); err != nil {
+		return nil, err
+	}
+
+	read := true
+	return &read, nil
+}
+
+// MarkAllNotificationsRead returns how many notifications were unread
+func (r *Resolver) MarkAllNotificationsRead(ctx context.Context) (int32, error) {
+	currentUserID, err := ctxUserID(ctx)
+	if err != nil {
+		return 0, err
+	}
 
-	return nil, nil
+	return r.server.MarkAllNotificationsRead(currentUserID)
 }
@@ -2,36 +2,109 @@ package resolvers
 
 import (
 	"strconv"
-	"time"
+	"strings"
 
 	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/lambdacollective/cobbles-api/server"
 )
 
-type notification struct {
-	ID        int64
-	Unread    bool
-	Timestamp time.Time
-	CreatedAt time.Time
-}
-
 type NotificationResolver struct {
+	resolver     *Resolver
 	server       *server.Server
-	notification *notification
+	notification *server.Notification
 }
 
 func (r *NotificationResolver) ID() graphql.ID {
 	return graphql.ID(strconv.FormatInt(r.notification.ID, 10))
 }
 
+func (r *NotificationResolver) Kind() string {
+	return strings.ToUpper(string(r.notification.Kind))
+}
+
 func (r *NotificationResolver) Content() string {
-	return ""
+	return r.notification.Content
 }
 
 func (r *NotificationResolver) Unread() bool {
-	return r.notification.Unread
+	return !r.notification.Read
 }
 
 func (r *NotificationResolver) Timestamp() Timestamp {
-	return Timestamp{}
+	return Timestamp{r.notification.CreatedAt}
+}
+
+// Actor is who caused the notification, nil once they're deleted
+func (r *NotificationResolver) Actor() (*UserResolver, error) {
+	if r.notification.ActorID == nil {
+		return nil, nil
+	}
+
+	user, err := r.server.UserByID(*r.notification.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserResolver{server: r.server, user: user}, nil
+}
+
+func (r *NotificationResolver) ActorCount() int32 {
+	return r.notification.ActorCount
+}
+
+// Post is nil once the post's deleted, or when the recipient can't see it
+func (r *NotificationResolver) Post() (*PostResolver, error) {
+	if r.notification.PostID == nil {
+		return nil, nil
+	}
+
+	post, exists, err := r.resolver.getVisiblePost(*r.notification.PostID, r.notification.UserID)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &PostResolver{server: r.server, post: post}, nil
+}
+
+// Comment is nil once the comment's deleted, or when the recipient can't see
+// it
+func (r *NotificationResolver) Comment() (*PostCommentResolver, error) {
+	if r.notification.CommentID == nil {
+		return nil, nil
+	}
+
+	visible, err := postCommentVisible(r.server, *r.notification.CommentID, r.notification.UserID)
+	if err != nil || !visible {
+		return nil, err
+	}
+
+	comment, err := r.server.PostCommentByID(*r.notification.CommentID)
+	if err != nil || comment == nil {
+		return nil, err
+	}
+
+	return &PostCommentResolver{server: r.server, postComment: comment}, nil
+}
+
+func (r *NotificationResolver) Conversation() (*ConversationResolver, error) {
+	if r.notification.ConversationID == nil {
+		return nil, nil
+	}
+
+	convo, exists, err := r.resolver.getConversation(getConversationInput{ID: *r.notification.ConversationID})
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &ConversationResolver{
+		resolver:     r.resolver,
+		server:       r.server,
+		conversation: convo,
+	}, nil
+}
+
+// DeepLink opens the notification's target in the app, push notifications
+// carry the same link
+func (r *NotificationResolver) DeepLink() string {
+	return r.notification.DeepLink()
 }
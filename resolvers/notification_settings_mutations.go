@@ -0,0 +1,82 @@
+package resolvers
+
+import "context"
+
+type UpdateNotificationSettingsInput struct {
+	Messages          *bool
+	Comments          *bool
+	Likes             *bool
+	Follows           *bool
+	NeighborhoodPosts *bool
+	QuietHours        *QuietHoursInput
+	ClearQuietHours   *bool
+	TimeZone          *string
+}
+
+type QuietHoursInput struct {
+	Start string
+	End   string
+}
+
+// UpdateNotificationSettings changes the current user's notification
+// settings, fields left out keep their value
+func (r *Resolver) UpdateNotificationSettings(ctx context.Context, args struct {
+	Input UpdateNotificationSettingsInput
+}) (*NotificationSettingsResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := r.server.NotificationSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	in := args.Input
+	for _, toggle := range []struct {
+		in      *bool
+		setting *bool
+	}{
+		{in.Messages, &settings.Messages},
+		{in.Comments, &settings.Comments},
+		{in.Likes, &settings.Likes},
+		{in.Follows, &settings.Follows},
+		{in.NeighborhoodPosts, &settings.NeighborhoodPosts},
+	} {
+		if toggle.in != nil {
+			*toggle.setting = *toggle.in
+		}
+	}
+
+	if in.ClearQuietHours != nil && *in.ClearQuietHours {
+		settings.QuietHoursStart = nil
+		settings.QuietHoursEnd = nil
+	}
+
+	if in.QuietHours != nil {
+		start, err := parseClock(in.QuietHours.Start)
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parseClock(in.QuietHours.End)
+		if err != nil {
+			return nil, err
+		}
+
+		settings.QuietHoursStart = &start
+		settings.QuietHoursEnd = &end
+	}
+
+	if in.TimeZone != nil {
+		settings.TimeZone = *in.TimeZone
+	}
+
+	updated, err := r.server.UpdateNotificationSettings(*settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationSettingsResolver{settings: updated}, nil
+}
@@ -0,0 +1,18 @@
+package resolvers
+
+import "context"
+
+// NotificationSettings - the current user's notification settings
+func (r *Resolver) NotificationSettings(ctx context.Context) (*NotificationSettingsResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := r.server.NotificationSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationSettingsResolver{settings: settings}, nil
+}
@@ -0,0 +1,78 @@
+package resolvers
+
+import (
+	"fmt"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type NotificationSettingsResolver struct {
+	settings *server.NotificationSettings
+}
+
+func (r *NotificationSettingsResolver) Messages() bool {
+	return r.settings.Messages
+}
+
+func (r *NotificationSettingsResolver) Comments() bool {
+	return r.settings.Comments
+}
+
+func (r *NotificationSettingsResolver) Likes() bool {
+	return r.settings.Likes
+}
+
+func (r *NotificationSettingsResolver) Follows() bool {
+	return r.settings.Follows
+}
+
+func (r *NotificationSettingsResolver) NeighborhoodPosts() bool {
+	return r.settings.NeighborhoodPosts
+}
+
+func (r *NotificationSettingsResolver) QuietHours() *QuietHoursResolver {
+	if r.settings.QuietHoursStart == nil || r.settings.QuietHoursEnd == nil {
+		return nil
+	}
+
+	return &QuietHoursResolver{
+		start: *r.settings.QuietHoursStart,
+		end:   *r.settings.QuietHoursEnd,
+	}
+}
+
+func (r *NotificationSettingsResolver) TimeZone() string {
+	return r.settings.TimeZone
+}
+
+type QuietHoursResolver struct {
+	start int32
+	end   int32
+}
+
+func (r *QuietHoursResolver) Start() string {
+	return formatClock(r.start)
+}
+
+func (r *QuietHoursResolver) End() string {
+	return formatClock(r.end)
+}
+
+// formatClock is minutes after midnight as HH:MM
+func formatClock(minutes int32) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// parseClock is HH:MM as minutes after midnight
+func parseClock(clock string) (int32, error) {
+	var hours, minutes int32
+	if _, err := fmt.Sscanf(clock, "%d:%d", &hours, &minutes); err != nil || len(clock) != 5 {
+		return 0, fmt.Errorf("%q isn't a HH:MM time", clock)
+	}
+
+	if hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("%q isn't a HH:MM time", clock)
+	}
+
+	return hours*60 + minutes, nil
+}
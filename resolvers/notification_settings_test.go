@@ -0,0 +1,186 @@
+package resolvers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationSettings(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		posterID int64 = 1
+		fanID    int64 = 2
+	)
+	harness.MustCreateUser(posterID)
+	harness.MustCreateUser(fanID)
+
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query:  `mutation { registerPushDevice(input: {platform: APNS, token: "phone"}) }`,
+	}, nil)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query: `
+			mutation {
+				createPost(input: {title: "garage sale", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	type settings struct {
+		Messages          bool
+		Likes             bool
+		NeighborhoodPosts bool
+		QuietHours        *struct {
+			Start string
+			End   string
+		}
+		TimeZone string
+	}
+	const settingsFields = `messages likes neighborhoodPosts quietHours { start end } timeZone`
+
+	inboxKinds := func() []string {
+		var inbox struct {
+			Notifications struct {
+				Notifications []struct {
+					Kind string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query:  `query { notifications { notifications { kind } } }`,
+		}, &inbox)
+
+		var kinds []string
+		for _, n := range inbox.Notifications.Notifications {
+			kinds = append(kinds, n.Kind)
+		}
+		return kinds
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		var res struct {
+			NotificationSettings settings
+		}
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query:  `query { notificationSettings { ` + settingsFields + ` } }`,
+		}, &res)
+
+		assert.True(t, res.NotificationSettings.Messages)
+		assert.True(t, res.NotificationSettings.Likes)
+		assert.False(t, res.NotificationSettings.NeighborhoodPosts)
+		assert.Nil(t, res.NotificationSettings.QuietHours)
+		assert.Equal(t, "UTC", res.NotificationSettings.TimeZone)
+	})
+
+	t.Run("turned off kinds skip the inbox and push", func(t *testing.T) {
+		var res struct {
+			UpdateNotificationSettings settings
+		}
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query:  `mutation { updateNotificationSettings(input: {likes: false}) { ` + settingsFields + ` } }`,
+		}, &res)
+		assert.False(t, res.UpdateNotificationSettings.Likes)
+		assert.True(t, res.UpdateNotificationSettings.Messages)
+
+		harness.MustExec(ExecInput{
+			UserID: fanID,
+			Query:  fmt.Sprintf(`mutation { likePost(id: %s) }`, post.CreatePost.ID),
+		}, nil)
+		harness.DrainOutbox()
+
+		assert.Empty(t, inboxKinds())
+		assert.Empty(t, harness.push.PushesTo(posterID))
+	})
+
+	t.Run("quiet hours hold back pushes but not the inbox", func(t *testing.T) {
+		// quiet from an hour ago to an hour from now in New York
+		loc, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		now := time.Now().In(loc)
+		start := now.Add(-time.Hour).Format("15:04")
+		end := now.Add(time.Hour).Format("15:04")
+
+		var res struct {
+			UpdateNotificationSettings settings
+		}
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query: fmt.Sprintf(`
+				mutation {
+					updateNotificationSettings(input: {
+						quietHours: {start: "%s", end: "%s"},
+						timeZone: "America/New_York"
+					}) { %s }
+				}`, start, end, settingsFields),
+		}, &res)
+		require.NotNil(t, res.UpdateNotificationSettings.QuietHours)
+		assert.Equal(t, start, res.UpdateNotificationSettings.QuietHours.Start)
+		assert.Equal(t, end, res.UpdateNotificationSettings.QuietHours.End)
+		assert.False(t, res.UpdateNotificationSettings.Likes)
+
+		var convo struct {
+			GetOrCreateConversation struct {
+				Conversation struct {
+					ID string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: fanID,
+			Query: fmt.Sprintf(`
+				mutation {
+					getOrCreateConversation(input: {postID: "%s"}) {
+						conversation {
+							id
+						}
+					}
+				}`, post.CreatePost.ID),
+		}, &convo)
+		harness.MustExec(ExecInput{
+			UserID: fanID,
+			Query: fmt.Sprintf(`
+				mutation {
+					sendMessage(input: {conversationID: "%s", body: "is the bike still there?"}) {
+						message {
+							id
+						}
+					}
+				}`, convo.GetOrCreateConversation.Conversation.ID),
+		}, nil)
+		harness.DrainOutbox()
+
+		assert.Equal(t, []string{"MESSAGE"}, inboxKinds())
+		assert.Empty(t, harness.push.PushesTo(posterID))
+	})
+
+	t.Run("bad settings are refused", func(t *testing.T) {
+		for _, input := range []string{
+			`timeZone: "Mars/Olympus_Mons"`,
+			`quietHours: {start: "22:00", end: "25:00"}`,
+			`quietHours: {start: "22:00", end: "22:00"}`,
+		} {
+			errs := harness.Exec(ExecInput{
+				UserID: posterID,
+				Query:  `mutation { updateNotificationSettings(input: {` + input + `}) { timeZone } }`,
+			}, nil)
+			assert.NotEmpty(t, errs, input)
+		}
+	})
+}
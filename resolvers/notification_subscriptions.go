@@ -0,0 +1,52 @@
+package resolvers
+
+import (
+	"context"
+	"log"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// NotificationAdded is each notification added to the current user's inbox
+// from now on, and again each time a like or comment coalesces into one
+func (r *Resolver) NotificationAdded(ctx context.Context) (<-chan *NotificationResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, unsubscribe := r.server.SubscribeRealtime(userID)
+	c := make(chan *NotificationResolver)
+	go func() {
+		defer close(c)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if e.Kind != server.RealtimeNotificationAdded {
+					continue
+				}
+
+				n, err := r.server.Notification(userID, e.NotificationID)
+				if err == server.ErrNotificationNotFound {
+					continue
+				}
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case c <- &NotificationResolver{resolver: r, server: r.server, notification: n}:
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
@@ -2,36 +2,84 @@ package resolvers
 
 import (
 	"context"
-	"errors"
 )
 
 type NotificationsInput struct {
-	KeepSchemaHappy *bool
+	UnreadOnly *bool
+	PageToken  *string
+	Limit      *int32
 }
 
+// Notifications is the current user's inbox, newest first
 func (r *Resolver) Notifications(ctx context.Context, args struct{ Input *NotificationsInput }) (*NotificationsResultResolver, error) {
-	return nil, errors.New("not implemented")
-
 	currentUserID, err := ctxUserID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	r.server.ConnPool.Query(`
-		select 
-			id
-			data
-			created_at
-		from notifications
-		where user_id = $1
-		order by created_at desc
-	`, currentUserID)
+	in := args.Input
+	if in == nil {
+		in = &NotificationsInput{}
+	}
+
+	var limit int32 = 50
+	if in.Limit != nil && *in.Limit > 0 && *in.Limit < limit {
+		limit = *in.Limit
+	}
+
+	beforeID, err := DecodeAfterIDCursor(in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	unreadOnly := in.UnreadOnly != nil && *in.UnreadOnly
+	notifications, err := r.server.Notifications(currentUserID, unreadOnly, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	unreadCount, err := r.server.UnreadNotificationCount(currentUserID)
+	if err != nil {
+		return nil, err
+	}
 
-	return &NotificationsResultResolver{}, nil
+	var nextPageToken *string
+	if int32(len(notifications)) > limit {
+		notifications = notifications[:limit]
+		nextPageToken = EncodeAfterIDCursor(notifications[limit-1].ID)
+	}
+
+	result := &NotificationsResultResolver{
+		notifications: []*NotificationResolver{},
+		unreadCount:   unreadCount,
+		nextPageToken: nextPageToken,
+	}
+	for i := range notifications {
+		result.notifications = append(result.notifications, &NotificationResolver{
+			resolver:     r,
+			server:       r.server,
+			notification: &notifications[i],
+		})
+	}
+
+	return result, nil
 }
 
-type NotificationsResultResolver struct{}
+type NotificationsResultResolver struct {
+	notifications []*NotificationResolver
+	unreadCount   int32
+	nextPageToken *string
+}
 
 func (r *NotificationsResultResolver) Notifications() []*NotificationResolver {
-	return []*NotificationResolver{}
+	return r.notifications
+}
+
+// UnreadCount is the user's unread notifications, whichever page this is
+func (r *NotificationsResultResolver) UnreadCount() int32 {
+	return r.unreadCount
+}
+
+func (r *NotificationsResultResolver) NextPageToken() *string {
+	return r.nextPageToken
 }
@@ -0,0 +1,280 @@
+package resolvers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		authorID    int64 = 1
+		commenterID int64 = 2
+		replierID   int64 = 3
+		blockedID   int64 = 4
+	)
+	for _, id := range []int64{authorID, commenterID, replierID, blockedID} {
+		harness.MustCreateUser(id)
+	}
+	_, err := connPool.Exec(`update users set name = 'Alex' where id = $1`, commenterID)
+	require.NoError(t, err)
+
+	harness.MustExec(ExecInput{
+		UserID: authorID,
+		Query:  fmt.Sprintf(`mutation { blockUser(id: %d) }`, blockedID),
+	}, nil)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: authorID,
+		Query: `
+			mutation {
+				createPost(input: {title: "hello", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	for _, id := range []int64{authorID, commenterID, blockedID} {
+		harness.MustExec(ExecInput{
+			UserID: id,
+			Query:  fmt.Sprintf(`mutation { likePost(id: %s) }`, post.CreatePost.ID),
+		}, nil)
+	}
+
+	harness.MustExec(ExecInput{
+		UserID: commenterID,
+		Query: fmt.Sprintf(`
+			mutation {
+				createPostComment(input: {postID: %s, comment: "nice"})
+			}`, post.CreatePost.ID),
+	}, nil)
+
+	var commentID int64
+	err = connPool.QueryRow(`select id from post_comments where user_id = $1`, commenterID).Scan(&commentID)
+	require.NoError(t, err)
+
+	harness.MustExec(ExecInput{
+		UserID: replierID,
+		Query: fmt.Sprintf(`
+			mutation {
+				createPostComment(input: {postID: %s, parentCommentID: %d, comment: "agreed"})
+			}`, post.CreatePost.ID, commentID),
+	}, nil)
+
+	harness.MustExec(ExecInput{
+		UserID: commenterID,
+		Query:  fmt.Sprintf(`mutation { createFollower(id: %d) { id } }`, authorID),
+	}, nil)
+
+	type inbox struct {
+		Notifications struct {
+			Notifications []struct {
+				ID      string
+				Content string
+				Unread  bool
+			}
+			UnreadCount   int32
+			NextPageToken *string
+		}
+	}
+	notifications := func(userID int64, input string) inbox {
+		var res inbox
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+			{
+				notifications%s {
+					notifications {
+						id
+						content
+						unread
+					}
+					unreadCount
+					nextPageToken
+				}
+			}`, input),
+		}, &res)
+		return res
+	}
+
+	t.Run("activity is in the inbox newest first", func(t *testing.T) {
+		res := notifications(authorID, "")
+		var contents []string
+		for _, n := range res.Notifications.Notifications {
+			contents = append(contents, n.Content)
+			require.True(t, n.Unread)
+		}
+		require.Equal(t, []string{
+			"Alex started following you",
+			"Alex commented on your post",
+			"Alex liked your post",
+		}, contents)
+		require.Equal(t, int32(3), res.Notifications.UnreadCount)
+
+		res = notifications(commenterID, "")
+		require.Len(t, res.Notifications.Notifications, 1)
+		require.Equal(t, "Somebody replied to your comment", res.Notifications.Notifications[0].Content)
+	})
+
+	t.Run("notifications link to their targets", func(t *testing.T) {
+		var res struct {
+			Notifications struct {
+				Notifications []struct {
+					Kind  string
+					Actor struct {
+						ID string
+					}
+					Post *struct {
+						ID string
+					}
+					Comment *struct {
+						Comment string
+					}
+					DeepLink string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: authorID,
+			Query: `
+			{
+				notifications {
+					notifications {
+						kind
+						actor {
+							id
+						}
+						post {
+							id
+						}
+						comment {
+							comment
+						}
+						deepLink
+					}
+				}
+			}`,
+		}, &res)
+
+		notifications := res.Notifications.Notifications
+		require.Len(t, notifications, 3)
+
+		follow, comment, like := notifications[0], notifications[1], notifications[2]
+		require.Equal(t, "FOLLOW", follow.Kind)
+		require.Equal(t, fmt.Sprint(commenterID), follow.Actor.ID)
+		require.Nil(t, follow.Post)
+		require.Equal(t, fmt.Sprintf("cobbles://users/%d", commenterID), follow.DeepLink)
+
+		require.Equal(t, "COMMENT", comment.Kind)
+		require.Equal(t, post.CreatePost.ID, comment.Post.ID)
+		require.Equal(t, "nice", comment.Comment.Comment)
+		require.Equal(t, fmt.Sprintf("cobbles://posts/%s/comments/%d", post.CreatePost.ID, commentID), comment.DeepLink)
+
+		require.Equal(t, "LIKE", like.Kind)
+		require.Equal(t, post.CreatePost.ID, like.Post.ID)
+		require.Nil(t, like.Comment)
+		require.Equal(t, "cobbles://posts/"+post.CreatePost.ID, like.DeepLink)
+	})
+
+	t.Run("paginates", func(t *testing.T) {
+		res := notifications(authorID, "(input: {limit: 2})")
+		require.Len(t, res.Notifications.Notifications, 2)
+		require.NotNil(t, res.Notifications.NextPageToken)
+
+		res = notifications(authorID, fmt.Sprintf("(input: {limit: 2, pageToken: %q})", *res.Notifications.NextPageToken))
+		require.Len(t, res.Notifications.Notifications, 1)
+		require.Equal(t, "Alex liked your post", res.Notifications.Notifications[0].Content)
+		require.Nil(t, res.Notifications.NextPageToken)
+	})
+
+	t.Run("marks notifications read", func(t *testing.T) {
+		res := notifications(authorID, "")
+		newest := res.Notifications.Notifications[0].ID
+		markRead := fmt.Sprintf(`
+			mutation {
+				markNotificationRead(input: {notificationID: %q})
+			}`, newest)
+
+		errs := harness.Exec(ExecInput{UserID: commenterID, Query: markRead}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "notification not found", errs[0].Message)
+
+		harness.MustExec(ExecInput{UserID: authorID, Query: markRead}, nil)
+
+		res = notifications(authorID, "(input: {unreadOnly: true})")
+		require.Len(t, res.Notifications.Notifications, 2)
+		require.Equal(t, int32(2), res.Notifications.UnreadCount)
+
+		var marked struct {
+			MarkAllNotificationsRead int32
+		}
+		harness.MustExec(ExecInput{
+			UserID: authorID,
+			Query:  `mutation { markAllNotificationsRead }`,
+		}, &marked)
+		require.Equal(t, int32(2), marked.MarkAllNotificationsRead)
+
+		res = notifications(authorID, "")
+		require.Len(t, res.Notifications.Notifications, 3)
+		require.Equal(t, int32(0), res.Notifications.UnreadCount)
+	})
+	t.Run("targets the recipient can't see are left out", func(t *testing.T) {
+		type targets struct {
+			Notifications struct {
+				Notifications []struct {
+					Post *struct {
+						ID string
+					}
+					Comment *struct {
+						Comment string
+					}
+				}
+			}
+		}
+		targetsOf := func(userID int64) targets {
+			var res targets
+			harness.MustExec(ExecInput{
+				UserID: userID,
+				Query:  `{ notifications { notifications { post { id } comment { comment } } } }`,
+			}, &res)
+			return res
+		}
+
+		// the reply's by someone the commenter blocked since
+		harness.MustExec(ExecInput{
+			UserID: commenterID,
+			Query:  fmt.Sprintf(`mutation { blockUser(id: %d) }`, replierID),
+		}, nil)
+		reply := targetsOf(commenterID).Notifications.Notifications
+		require.Len(t, reply, 1)
+		require.NotNil(t, reply[0].Post)
+		require.Nil(t, reply[0].Comment)
+
+		// hidden posts are only their author's
+		_, err := connPool.Exec(`update posts set hidden = true where id = $1`, post.CreatePost.ID)
+		require.NoError(t, err)
+		reply = targetsOf(commenterID).Notifications.Notifications
+		require.Nil(t, reply[0].Post)
+
+		own := targetsOf(authorID).Notifications.Notifications
+		require.Len(t, own, 3)
+		require.NotNil(t, own[1].Post)
+		require.NotNil(t, own[1].Comment)
+
+		_, err = connPool.Exec(`update posts set removed = true where id = $1`, post.CreatePost.ID)
+		require.NoError(t, err)
+		own = targetsOf(authorID).Notifications.Notifications
+		require.Nil(t, own[1].Post)
+		require.Nil(t, own[1].Comment)
+		require.Nil(t, own[2].Post)
+	})
+}
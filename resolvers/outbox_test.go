@@ -0,0 +1,187 @@
+package resolvers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		posterID int64 = 1
+		likerID  int64 = 2
+	)
+	harness.MustCreateUser(posterID)
+	harness.MustCreateUser(likerID)
+	_, err := connPool.Exec(`update users set name = 'Alex' where id = $1`, likerID)
+	require.NoError(t, err)
+
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query:  `mutation { registerPushDevice(input: {platform: FCM, token: "phone"}) }`,
+	}, nil)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query: `
+			mutation {
+				createPost(input: {title: "lost cat", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	type event struct {
+		status    string
+		attempts  int32
+		lastError *string
+	}
+	lastEvent := func() event {
+		var e event
+		err := connPool.QueryRow(`
+			select status, attempts, last_error from outbox_events order by id desc limit 1
+		`).Scan(&e.status, &e.attempts, &e.lastError)
+		require.NoError(t, err)
+		return e
+	}
+
+	harness.push.Err = errors.New("fcm is down")
+
+	t.Run("an outage doesn't fail the like", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: likerID,
+			Query:  fmt.Sprintf(`mutation { likePost(id: %s) }`, post.CreatePost.ID),
+		}, nil)
+		assert.Empty(t, harness.push.PushesTo(posterID))
+
+		harness.DrainOutbox()
+		assert.Empty(t, harness.push.PushesTo(posterID))
+
+		e := lastEvent()
+		assert.Equal(t, string(server.OutboxPending), e.status)
+		assert.EqualValues(t, 1, e.attempts)
+		require.NotNil(t, e.lastError)
+		assert.Equal(t, "fcm is down", *e.lastError)
+	})
+
+	t.Run("failed events are retried after backing off", func(t *testing.T) {
+		harness.push.Err = nil
+
+		// still backing off
+		harness.DrainOutbox()
+		assert.Empty(t, harness.push.PushesTo(posterID))
+
+		_, err := connPool.Exec(`update outbox_events set next_attempt_at = now()`)
+		require.NoError(t, err)
+		harness.DrainOutbox()
+
+		pushes := harness.push.PushesTo(posterID)
+		require.Len(t, pushes, 1)
+		assert.Equal(t, "Alex liked your post", pushes[0].Message.Body)
+		assert.Equal(t, "cobbles://posts/"+post.CreatePost.ID, pushes[0].Message.Link)
+		assert.Equal(t, string(server.OutboxDone), lastEvent().status)
+	})
+
+	t.Run("events that keep failing are dead", func(t *testing.T) {
+		harness.push.Err = errors.New("fcm is down")
+
+		var convo struct {
+			GetOrCreateConversation struct {
+				Conversation struct {
+					ID string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: likerID,
+			Query: fmt.Sprintf(`
+				mutation {
+					getOrCreateConversation(input: {postID: "%s"}) {
+						conversation {
+							id
+						}
+					}
+				}`, post.CreatePost.ID),
+		}, &convo)
+
+		harness.MustExec(ExecInput{
+			UserID: likerID,
+			Query: fmt.Sprintf(`
+				mutation {
+					sendMessage(input: {conversationID: "%s", body: "is this her?"}) {
+						message {
+							id
+						}
+					}
+				}`, convo.GetOrCreateConversation.Conversation.ID),
+		}, nil)
+
+		_, err := connPool.Exec(`
+			update outbox_events set attempts = $1 where status = 'pending'
+		`, server.OutboxMaxAttempts-1)
+		require.NoError(t, err)
+		harness.DrainOutbox()
+
+		e := lastEvent()
+		assert.Equal(t, string(server.OutboxDead), e.status)
+		assert.EqualValues(t, server.OutboxMaxAttempts, e.attempts)
+		assert.Len(t, harness.push.PushesTo(posterID), 1)
+	})
+
+	t.Run("retries only push to devices that failed", func(t *testing.T) {
+		harness.push.Err = nil
+		for _, token := range []string{"tablet", "watch"} {
+			harness.MustExec(ExecInput{
+				UserID: posterID,
+				Query:  fmt.Sprintf(`mutation { registerPushDevice(input: {platform: APNS, token: "%s"}) }`, token),
+			}, nil)
+		}
+		harness.push.TokenErrs = map[string]error{
+			"tablet": errors.New("apns is down"),
+			"watch":  server.ErrPushPlatformNotConfigured,
+		}
+		defer func() { harness.push.TokenErrs = nil }()
+
+		pushesTo := func(token string) int {
+			var n int
+			for _, push := range harness.push.PushesTo(posterID) {
+				if push.Device.Token == token && push.Message.Body == "Alex started following you" {
+					n++
+				}
+			}
+			return n
+		}
+
+		harness.MustExec(ExecInput{
+			UserID: likerID,
+			Query:  fmt.Sprintf(`mutation { createFollower(id: %d) { id } }`, posterID),
+		}, nil)
+		harness.DrainOutbox()
+
+		assert.Equal(t, 1, pushesTo("phone"))
+		assert.Equal(t, 0, pushesTo("tablet"))
+		assert.Equal(t, string(server.OutboxPending), lastEvent().status)
+
+		// the unconfigured platform isn't retried, the outage is
+		delete(harness.push.TokenErrs, "tablet")
+		_, err := connPool.Exec(`update outbox_events set next_attempt_at = now() where status = 'pending'`)
+		require.NoError(t, err)
+		harness.DrainOutbox()
+
+		assert.Equal(t, 1, pushesTo("phone"))
+		assert.Equal(t, 1, pushesTo("tablet"))
+		assert.Equal(t, string(server.OutboxDone), lastEvent().status)
+	})
+}
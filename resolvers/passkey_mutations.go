@@ -0,0 +1,138 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// BeginPasskeyRegistration - options for navigator.credentials.create() to
+// add a passkey to the current user
+func (r *Resolver) BeginPasskeyRegistration(ctx context.Context) (*PasskeyChallengeResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := r.server.BeginPasskeyRegistration(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyChallengeResolver{challenge: challenge}, nil
+}
+
+// FinishPasskeyRegistration saves the credential navigator.credentials.create()
+// returned, binary fields are base64url
+func (r *Resolver) FinishPasskeyRegistration(ctx context.Context, args struct {
+	Input struct {
+		ChallengeID       graphql.ID
+		ClientDataJSON    string
+		AttestationObject string
+		Name              *string
+	}
+}) (*PasskeyResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataJSON, err := server.DecodeBase64URL(args.Input.ClientDataJSON)
+	if err != nil {
+		return nil, server.ErrInvalidPasskey
+	}
+
+	attestationObject, err := server.DecodeBase64URL(args.Input.AttestationObject)
+	if err != nil {
+		return nil, server.ErrInvalidPasskey
+	}
+
+	passkey, err := r.server.FinishPasskeyRegistration(userID, string(args.Input.ChallengeID), clientDataJSON, attestationObject, args.Input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyResolver{passkey: passkey}, nil
+}
+
+// BeginPasskeyLogin - options for navigator.credentials.get()
+func (r *Resolver) BeginPasskeyLogin(ctx context.Context) (*PasskeyChallengeResolver, error) {
+	challenge, err := r.server.BeginPasskeyLogin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyChallengeResolver{challenge: challenge}, nil
+}
+
+// LoginWithPasskey checks the assertion navigator.credentials.get() returned,
+// binary fields are base64url
+func (r *Resolver) LoginWithPasskey(ctx context.Context, args struct {
+	Input struct {
+		ChallengeID       graphql.ID
+		CredentialID      string
+		ClientDataJSON    string
+		AuthenticatorData string
+		Signature         string
+		FCMToken          *string
+		DeviceID          *string
+	}
+}) (*LoginUserResultResolver, error) {
+	var decoded [4][]byte
+	for i, field := range []string{
+		args.Input.CredentialID,
+		args.Input.ClientDataJSON,
+		args.Input.AuthenticatorData,
+		args.Input.Signature,
+	} {
+		b, err := server.DecodeBase64URL(field)
+		if err != nil {
+			return nil, server.ErrInvalidPasskey
+		}
+		decoded[i] = b
+	}
+
+	userID, err := r.server.FinishPasskeyLogin(string(args.Input.ChallengeID), decoded[0], decoded[1], decoded[2], decoded[3], args.Input.FCMToken)
+	if err != nil {
+		return nil, err
+	}
+
+	authTokens, err := r.server.IssueAuthTokens(userID, args.Input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginUserResultResolver{
+		server: r.server,
+		tokens: authTokens,
+	}, nil
+}
+
+// RemovePasskey deletes one of the current user's passkeys
+func (r *Resolver) RemovePasskey(ctx context.Context, args struct {
+	ID graphql.ID
+}) (bool, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	passkeyID, err := strconv.ParseInt(string(args.ID), 10, 64)
+	if err != nil {
+		return false, errors.New("passkey not found")
+	}
+
+	removed, err := r.server.RemovePasskey(userID, passkeyID)
+	if err != nil {
+		return false, err
+	}
+
+	if !removed {
+		return false, errors.New("passkey not found")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,244 @@
+package resolvers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuthenticator plays the browser and authenticator side of WebAuthn
+type fakeAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+func newFakeAuthenticator(t *testing.T) *fakeAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+
+	return &fakeAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+func (a *fakeAuthenticator) clientData(kind string, options string) []byte {
+	var parsed struct {
+		Challenge string
+	}
+	require.NoError(a.t, json.Unmarshal([]byte(options), &parsed))
+
+	b, err := json.Marshal(map[string]string{
+		"type":      kind,
+		"challenge": parsed.Challenge,
+		"origin":    "https://localhost",
+	})
+	require.NoError(a.t, err)
+	return b
+}
+
+func (a *fakeAuthenticator) authData(flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte("localhost"))
+	b := append(rpIDHash[:], flags)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], a.signCount)
+
+	if attested {
+		b = append(b, make([]byte, 16)...) // aaguid
+		b = append(b, byte(len(a.credentialID)>>8), byte(len(a.credentialID)))
+		b = append(b, a.credentialID...)
+		b = append(b, cborMap(
+			cborInt(1), cborInt(2),
+			cborInt(3), cborInt(-7),
+			cborInt(-1), cborInt(1),
+			cborInt(-2), cborBytes(padTo32(a.key.X)),
+			cborInt(-3), cborBytes(padTo32(a.key.Y)),
+		)...)
+	}
+
+	return b
+}
+
+func (a *fakeAuthenticator) sign(authData []byte, clientData []byte) []byte {
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	require.NoError(a.t, err)
+
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	require.NoError(a.t, err)
+	return sig
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func padTo32(n *big.Int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+func cborMap(pairs ...[]byte) []byte {
+	b := cborHead(5, uint64(len(pairs)/2))
+	for _, p := range pairs {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestPasskeys(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	login := harness.MustLogin("+16175550100", "phone")
+	token, err := harness.resolver.server.ValidateAuthJWT(login.Token)
+	require.NoError(t, err)
+	userID := token.Claims.(*server.AuthJWTClaims).UserID
+
+	authenticator := newFakeAuthenticator(t)
+
+	var begin struct {
+		BeginPasskeyRegistration struct {
+			ChallengeID string
+			Options     string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: `
+			mutation {
+				beginPasskeyRegistration {
+					challengeID
+					options
+				}
+			}
+		`}, &begin)
+
+	attestationObject := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(authenticator.authData(0x41, true)),
+	)
+
+	var registered struct {
+		FinishPasskeyRegistration struct {
+			CredentialID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Variables: map[string]interface{}{
+			"input": map[string]interface{}{
+				"challengeID":       begin.BeginPasskeyRegistration.ChallengeID,
+				"clientDataJSON":    b64(authenticator.clientData("webauthn.create", begin.BeginPasskeyRegistration.Options)),
+				"attestationObject": b64(attestationObject),
+				"name":              "test phone",
+			},
+		},
+		Query: `
+			mutation FinishPasskeyRegistration($input: FinishPasskeyRegistrationInput!) {
+				finishPasskeyRegistration(input: $input) {
+					credentialID
+				}
+			}
+		`}, &registered)
+	require.Equal(t, b64(authenticator.credentialID), registered.FinishPasskeyRegistration.CredentialID)
+
+	var beginLogin struct {
+		BeginPasskeyLogin struct {
+			ChallengeID string
+			Options     string
+		}
+	}
+	harness.MustExec(ExecInput{
+		Query: `
+			mutation {
+				beginPasskeyLogin {
+					challengeID
+					options
+				}
+			}
+		`}, &beginLogin)
+
+	authenticator.signCount++
+	authData := authenticator.authData(0x01, false)
+	clientData := authenticator.clientData("webauthn.get", beginLogin.BeginPasskeyLogin.Options)
+	assertion := map[string]interface{}{
+		"challengeID":       beginLogin.BeginPasskeyLogin.ChallengeID,
+		"credentialID":      b64(authenticator.credentialID),
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(authenticator.sign(authData, clientData)),
+	}
+	loginWithPasskey := func() (string, int) {
+		var res struct {
+			LoginWithPasskey struct {
+				Token string
+			}
+		}
+		errs := harness.Exec(ExecInput{
+			Variables: map[string]interface{}{
+				"input": assertion,
+			},
+			Query: `
+				mutation LoginWithPasskey($input: LoginWithPasskeyInput!) {
+					loginWithPasskey(input: $input) {
+						token
+					}
+				}
+			`}, &res)
+		return res.LoginWithPasskey.Token, len(errs)
+	}
+
+	accessToken, errCount := loginWithPasskey()
+	require.Equal(t, 0, errCount)
+	passkeyToken, err := harness.resolver.server.ValidateAuthJWT(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, userID, passkeyToken.Claims.(*server.AuthJWTClaims).UserID)
+
+	t.Run("assertions can't be replayed", func(t *testing.T) {
+		_, errCount := loginWithPasskey()
+		require.Equal(t, 1, errCount)
+	})
+}
@@ -0,0 +1,49 @@
+package resolvers
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type PasskeyResolver struct {
+	passkey *server.Passkey
+}
+
+func (r *PasskeyResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.passkey.ID, 10))
+}
+
+func (r *PasskeyResolver) Name() *string {
+	return r.passkey.Name
+}
+
+func (r *PasskeyResolver) CredentialID() string {
+	return base64.RawURLEncoding.EncodeToString(r.passkey.CredentialID)
+}
+
+func (r *PasskeyResolver) CreatedAt() Timestamp {
+	return Timestamp{r.passkey.CreatedAt}
+}
+
+func (r *PasskeyResolver) LastUsedAt() *Timestamp {
+	if r.passkey.LastUsedAt == nil {
+		return nil
+	}
+
+	return &Timestamp{*r.passkey.LastUsedAt}
+}
+
+type PasskeyChallengeResolver struct {
+	challenge *server.PasskeyChallenge
+}
+
+func (r *PasskeyChallengeResolver) ChallengeID() graphql.ID {
+	return graphql.ID(r.challenge.ID)
+}
+
+func (r *PasskeyChallengeResolver) Options() string {
+	return r.challenge.Options
+}
@@ -0,0 +1,23 @@
+package resolvers
+
+import "context"
+
+// Passkeys - the current user's registered passkeys
+func (r *Resolver) Passkeys(ctx context.Context) ([]*PasskeyResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := r.server.Passkeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*PasskeyResolver{}
+	for _, passkey := range passkeys {
+		resolvers = append(resolvers, &PasskeyResolver{passkey: passkey})
+	}
+
+	return resolvers, nil
+}
@@ -1,6 +1,11 @@
 package resolvers
 
-import "context"
+import (
+	"context"
+	"log"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
 
 // CreatePostComment ...
 func (r *Resolver) CreatePostComment(ctx context.Context, args struct {
@@ -10,17 +15,35 @@ func (r *Resolver) CreatePostComment(ctx context.Context, args struct {
 	if err != nil {
 		return false, err
 	}
-	_, err = r.server.CreatePostComment(
+
+	filtered, err := r.server.FilterContent(server.ContentKindComment, args.Input.Comment)
+	if err != nil {
+		return false, err
+	}
+
+	pc, err := r.server.CreatePostComment(
 		args.Input.ParentCommentID,
 		int32(userID),
 		args.Input.PostID,
 		args.Input.Comment,
+		filtered.Hidden(),
 	)
 
 	if err != nil {
 		return false, err
 	}
 
+	if err := r.server.RecordContentFlag(server.ContentKindComment, pc.ID, userID, args.Input.Comment, filtered); err != nil {
+		log.Println(err)
+	}
+
+	// shadow hidden comments look posted, but nobody else hears of them
+	if !filtered.Hidden() {
+		if err := r.server.NotifyComment(pc); err != nil {
+			log.Println(err)
+		}
+	}
+
 	return true, nil
 }
 
@@ -28,7 +51,13 @@ func (r *Resolver) CreatePostComment(ctx context.Context, args struct {
 func (r *Resolver) RemovePostComment(ctx context.Context, args struct {
 	Input RemovePostCommentInput
 }) (bool, error) {
-	err := r.server.RemovePostComment(
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.server.RemovePostComment(
+		userID,
 		args.Input.CommentID,
 		args.Input.PostID,
 	)
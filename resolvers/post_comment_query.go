@@ -16,11 +16,39 @@ func (r *Resolver) GetCommentsByPostID(ctx context.Context, args struct {
 
 type resolvePostCommentsInput struct {
 	// ByUserID int64
-	PostID    int32
+	PostID int32
+
+	// ViewerID's blocked and muted users' comments are left out
+	ViewerID int64
+
 	PageToken *string
 	Limit     int32
 }
 
+// postCommentVisible is whether viewerID sees comment id, on a post they see,
+// with the same filters as resolvePostComments
+func postCommentVisible(s *server.Server, id, viewerID int64) (bool, error) {
+	sql, args, err := newSelectBuilder("count(*) > 0").
+		From("post_comments pc").
+		Join("posts p on p.id = pc.post_id").
+		Where(squirrel.Eq{"pc.id": id}).
+		Where(server.UnblockedSQL("pc.user_id", true), viewerID).
+		Where("(pc.hidden is not true or pc.user_id = ?)", viewerID).
+		Where(server.ActiveUserSQL("pc.user_id")).
+		Where("p.removed is false").
+		Where("(p.hidden is false or p.user_id = ?)", viewerID).
+		Where(server.UnblockedSQL("p.user_id", true), viewerID).
+		Where(server.ActiveUserSQL("p.user_id")).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var visible bool
+	err = s.ConnPool.QueryRow(sql, args...).Scan(&visible)
+	return visible, err
+}
+
 // resolve post comments
 func resolvePostComments(s *server.Server, in resolvePostCommentsInput) ([]*PostCommentResolver, *string, error) {
 
@@ -50,6 +78,15 @@ func resolvePostComments(s *server.Server, in resolvePostCommentsInput) ([]*Post
 		sqlStmt = sqlStmt.Where(squirrel.Eq{"post_id": in.PostID})
 	}
 
+	if in.ViewerID > 0 {
+		sqlStmt = sqlStmt.Where(server.UnblockedSQL("pc.user_id", true), in.ViewerID)
+	}
+
+	// shadow hidden, only its author still sees it
+	sqlStmt = sqlStmt.Where("(pc.hidden is not true or pc.user_id = ?)", in.ViewerID)
+
+	sqlStmt = sqlStmt.Where(server.ActiveUserSQL("pc.user_id"))
+
 	sql, args, err := sqlStmt.ToSql()
 	if err != nil {
 		return nil, nil, err
@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"log"
 	"strconv"
 
@@ -107,9 +108,11 @@ func (r *PostCommentResolver) UpdatedAt() Timestamp {
 }
 
 // PostComments : postComments Resolver
-func (r *Resolver) PostComments(req struct {
+func (r *Resolver) PostComments(ctx context.Context, req struct {
 	Input *UserPostCommentsInput
 }) (*PostCommentsResult, error) {
+	// zero, hiding nothing, when logged out
+	viewerID, _ := ctxUserID(ctx)
 
 	// if req.Input.OtherUserID != nil {
 	// 	userID = int64(*req.Input.OtherUserID)
@@ -129,6 +132,7 @@ func (r *Resolver) PostComments(req struct {
 	postCommentResolvers, nextPageToken, err := resolvePostComments(r.server, resolvePostCommentsInput{
 		// ByUserID: userID,
 		PostID:    req.Input.PostID,
+		ViewerID:  viewerID,
 		PageToken: req.Input.PageToken,
 		Limit:     limit,
 	})
@@ -32,6 +32,8 @@ func (r *Resolver) CreatePost(ctx context.Context, args struct {
 		}
 
 		Tags *[]string
+
+		Location *LocationInput
 	}
 }) (*PostResolver, error) {
 	currentUserID, err := ctxUserID(ctx)
@@ -39,10 +41,22 @@ func (r *Resolver) CreatePost(ctx context.Context, args struct {
 		return nil, err
 	}
 
+	// authMiddleware turns suspended users away too, this covers callers
+	// that don't go through it
+	if err := r.server.CheckAccountActive(currentUserID); err != nil {
+		return nil, err
+	}
+
 	// inputNeighborhood := args.Input.Neighborhood
 	inputTitle := args.Input.Title
 
 	inputDescription := args.Input.Description
+
+	postText := postText(&inputTitle, inputDescription)
+	filtered, err := r.server.FilterContent(server.ContentKindPost, postText)
+	if err != nil {
+		return nil, err
+	}
 	inputPoster := args.Input.Poster
 	inputTags := args.Input.Tags
 
@@ -50,7 +64,17 @@ func (r *Resolver) CreatePost(ctx context.Context, args struct {
 	inputMediaURL := args.Input.MediaURL
 	inputMediaMetadata := args.Input.MediaMetadata
 
-	neighborhood, err := r.server.NeighborhoodBySlug("southie")
+	var lat, lng *float64
+	if args.Input.Location != nil {
+		loc := args.Input.Location.location()
+		if !loc.Valid() {
+			return nil, errors.New("invalid location")
+		}
+
+		lat, lng = &loc.Lat, &loc.Lng
+	}
+
+	neighborhood, err := r.server.HomeNeighborhood(currentUserID)
 	if err != nil {
 		return nil, err
 	}
@@ -130,10 +154,13 @@ func (r *Resolver) CreatePost(ctx context.Context, args struct {
 			media,
 			preview,
 			tags,
+			lat,
+			lng,
+			hidden,
 			created_at,
 			updated_at
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, now(), now())
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, now(), now())
 		returning 
 			id,
 			user_id,
@@ -147,6 +174,8 @@ func (r *Resolver) CreatePost(ctx context.Context, args struct {
 			preview,
 			tags,
 			view_times,
+			lat,
+			lng,
 			processing,
 			created_at
 	`, currentUserID,
@@ -162,12 +191,19 @@ func (r *Resolver) CreatePost(ctx context.Context, args struct {
 		postMedia,
 		postPreview,
 		inputTags,
+		lat,
+		lng,
+		filtered.Hidden(),
 	)
 	p, err := r.scanPost(row)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := r.server.RecordContentFlag(server.ContentKindPost, p.ID, currentUserID, postText, filtered); err != nil {
+		log.Println(err)
+	}
+
 	// calculate the post count
 	_, err = r.server.RecalculatePostCount(currentUserID)
 	if err != nil {
@@ -206,6 +242,13 @@ func (r *Resolver) UpdatePost(ctx context.Context, args struct {
 	inputDescription := args.Input.Description
 	inputTags := args.Input.Tags
 
+	// edits are filtered like new posts, so they can't sneak past it
+	postText := postText(inputTitle, inputDescription)
+	filtered, err := r.server.FilterContent(server.ContentKindPost, postText)
+	if err != nil {
+		return nil, err
+	}
+
 	row := r.server.ConnPool.QueryRow(`
 		update posts
 		set
@@ -213,6 +256,7 @@ func (r *Resolver) UpdatePost(ctx context.Context, args struct {
 			description = coalesce($4, description),
 			poster = coalesce($5, poster),
 			tags = coalesce($6, tags),
+			hidden = hidden or $7,
 			updated_at = now()
 		where user_id = $1 and id = $2 and removed is false
 		returning
@@ -228,9 +272,11 @@ func (r *Resolver) UpdatePost(ctx context.Context, args struct {
 			preview,
 			tags,
 			view_times,
+			lat,
+			lng,
 			processing,
 			created_at
-	`, currentUserID, inputPostID, inputTitle, inputDescription, inputPoster, inputTags)
+	`, currentUserID, inputPostID, inputTitle, inputDescription, inputPoster, inputTags, filtered.Hidden())
 
 	p, err := r.scanPost(row)
 	switch {
@@ -243,12 +289,29 @@ func (r *Resolver) UpdatePost(ctx context.Context, args struct {
 		return nil, errors.New("internal server error")
 	}
 
+	if err := r.server.RecordContentFlag(server.ContentKindPost, p.ID, currentUserID, postText, filtered); err != nil {
+		log.Println(err)
+	}
+
 	return &PostResolver{
 		server: r.server,
 		post:   p,
 	}, nil
 }
 
+// postText is what of a post goes through the content filter
+func postText(title *string, description *string) string {
+	var parts []string
+	if title != nil {
+		parts = append(parts, *title)
+	}
+	if description != nil {
+		parts = append(parts, *description)
+	}
+
+	return strings.Join(parts, "\n")
+}
+
 // RemovePostInput ...
 type RemovePostInput struct {
 	ID graphql.ID
@@ -284,6 +347,8 @@ func (r *Resolver) RemovePost(ctx context.Context, args struct {
 			preview,
 			tags,
 			view_times,
+			lat,
+			lng,
 			processing,
 			created_at
 	`, currentUserID, inputPostID)
@@ -339,7 +404,7 @@ func (r *Resolver) PostViewTimes(ctx context.Context, args struct {
 		&result.userID,
 		&result.viewTimes,
 	)
-	
+
 	if err != nil {
 		return nil, err
 	}
@@ -16,6 +16,8 @@ func (r *Resolver) scanPost(row scannable) (*server.Post, error) {
 	var result struct {
 		createdAt  pgtype.Timestamptz
 		processing pgtype.Bool
+		lat        *float64
+		lng        *float64
 	}
 	err := row.Scan(
 		&p.ID,
@@ -30,6 +32,8 @@ func (r *Resolver) scanPost(row scannable) (*server.Post, error) {
 		&p.Preview,
 		&p.Tags,
 		&p.ViewTimes,
+		&result.lat,
+		&result.lng,
 		&result.processing,
 		&result.createdAt,
 	)
@@ -37,6 +41,7 @@ func (r *Resolver) scanPost(row scannable) (*server.Post, error) {
 		return nil, err
 	}
 
+	p.SetLocation(result.lat, result.lng)
 	p.Processing = result.processing.Bool
 	p.CreatedAt = result.createdAt.Time
 	return &p, nil
@@ -45,7 +50,7 @@ func (r *Resolver) scanPost(row scannable) (*server.Post, error) {
 func (r *Resolver) getPost(id int64) (*server.Post, bool, error) {
 	sql, args, err := newSelectBuilder("id", "user_id", "neighborhood_id",
 		"kind", "title", "description", "poster", "uploaded_media_url", "media", "preview",
-		"tags", "view_times", "processing", "created_at").
+		"tags", "view_times", "lat", "lng", "processing", "created_at").
 		From("posts").
 		Where(sq.Eq{"id": id}).
 		ToSql()
@@ -63,18 +68,66 @@ func (r *Resolver) getPost(id int64) (*server.Post, bool, error) {
 	return post, true, nil
 }
 
+// getVisiblePost is getPost for viewerID, it's not found when they couldn't
+// see the post in a feed
+func (r *Resolver) getVisiblePost(id, viewerID int64) (*server.Post, bool, error) {
+	sql, args, err := newSelectBuilder("id", "user_id", "neighborhood_id",
+		"kind", "title", "description", "poster", "uploaded_media_url", "media", "preview",
+		"tags", "view_times", "lat", "lng", "processing", "created_at").
+		From("posts p").
+		Where(sq.Eq{"p.id": id}).
+		Where("p.removed is false").
+		Where("p.processing is false").
+		Where("(p.hidden is false or p.user_id = ?)", viewerID).
+		Where(server.UnblockedSQL("p.user_id", true), viewerID).
+		Where(server.ActiveUserSQL("p.user_id")).
+		ToSql()
+	if err != nil {
+		return nil, false, err
+	}
+
+	row := r.server.ConnPool.QueryRow(sql, args...)
+	post, err := r.scanPost(row)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return post, true, nil
+}
+
 type resolvePostsInput struct {
 	// (optional) Limit field to a user, eg currentUsers { posts() }
 	ByUserID int64
 	Tags     *[]string
 
+	// (optional) Limit to posts in a neighborhood, eg feed()
+	NeighborhoodID int64
+
+	// (optional) Limit to posts within RadiusMeters of Near, closest first,
+	// eg nearbyFeed()
+	Near         *server.Location
+	RadiusMeters float64
+
 	PageToken *string
 	Limit     int32
 }
 
 // TODO: actual isolated models code?
 func resolvePosts(ctx context.Context, s *server.Server, in resolvePostsInput) ([]*PostResolver, *string, error) {
-	// TODO(tejasmanohar): sq.Where neighborhood is user's neighborhood by default
+	distance := "null::float8"
+	var distanceArgs []interface{}
+	if in.Near != nil {
+		distance = "earth_distance(ll_to_earth(p.lat, p.lng), ll_to_earth(?, ?))"
+		distanceArgs = []interface{}{in.Near.Lat, in.Near.Lng}
+	}
+
+	// zero, matching no one, when logged out
+	viewerID, _ := ctxUserID(ctx)
+
 	sqlStmt := newSelectBuilder(
 		"p.id",
 		"p.user_id",
@@ -91,16 +144,29 @@ func resolvePosts(ctx context.Context, s *server.Server, in resolvePostsInput) (
 		"coalesce(p.likes, 0)",
 		"p.comment_count",
 		"p.view_times",
+		"p.lat",
+		"p.lng",
 		"p.created_at",
 		"p.updated_at",
 		"n.id",
 		"n.name",
-		"n.slug").
+		"n.slug",
+		"n.zip_codes",
+		"n.center_lat",
+		"n.center_lng",
+		"n.boundary",
+		"n.retired_at",
+		"n.merged_into_id").
+		Column(distance+" as distance", distanceArgs...).
 		From("posts p").
 		Where("p.removed is false").
 		Where("p.processing is false").
+		// hidden by a moderator, only its author still sees it
+		Where("(p.hidden is false or p.user_id = ?)", viewerID).
+		Where(server.UnblockedSQL("p.user_id", true), viewerID).
+		// suspended and banned users' posts are hidden while they're locked out
+		Where(server.ActiveUserSQL("p.user_id")).
 		Join("neighborhoods n on n.id = p.neighborhood_id").
-		OrderBy("created_at desc").
 		Limit(uint64(in.Limit + 1))
 
 	afterID, err := DecodeAfterIDCursor(in.PageToken)
@@ -108,15 +174,39 @@ func resolvePosts(ctx context.Context, s *server.Server, in resolvePostsInput) (
 		return nil, nil, err
 	}
 
-	if afterID > 0 {
+	switch {
+	case afterID > 0 && in.Near != nil:
+		// Greater than the page's last post in (distance, recency) order
+		sqlStmt = sqlStmt.Where(`(`+distance+`, -p.id) > (
+			select earth_distance(ll_to_earth(c.lat, c.lng), ll_to_earth(?, ?)), -c.id
+			from posts c
+			where c.id = ?
+		)`, in.Near.Lat, in.Near.Lng, in.Near.Lat, in.Near.Lng, afterID)
+	case afterID > 0:
 		// Less than because we're paginating backwards
 		sqlStmt = sqlStmt.Where(squirrel.Lt{"p.id": afterID})
 	}
 
+	if in.Near != nil {
+		// earth_box uses the index, but it's a cube so trim its corners
+		sqlStmt = sqlStmt.
+			Where("earth_box(ll_to_earth(?, ?), ?) @> ll_to_earth(p.lat, p.lng)", in.Near.Lat, in.Near.Lng, in.RadiusMeters).
+			Where(distance+" <= ?", append(distanceArgs, in.RadiusMeters)...).
+			// ids are assigned in creation order so id desc is most recent
+			// first, and it keeps the cursor comparison above exact
+			OrderBy("distance asc", "p.id desc")
+	} else {
+		sqlStmt = sqlStmt.OrderBy("created_at desc")
+	}
+
 	if in.ByUserID > 0 {
 		sqlStmt = sqlStmt.Where(squirrel.Eq{"user_id": in.ByUserID})
 	}
 
+	if in.NeighborhoodID > 0 {
+		sqlStmt = sqlStmt.Where(squirrel.Eq{"p.neighborhood_id": in.NeighborhoodID})
+	}
+
 	if in.Tags != nil {
 		sqlStmt = sqlStmt.Where("tags && ?", in.Tags)
 	}
@@ -147,6 +237,14 @@ func resolvePosts(ctx context.Context, s *server.Server, in resolvePostsInput) (
 			postKind  string
 			createdAt pgtype.Timestamptz
 			updatedAt pgtype.Timestamptz
+
+			lat      *float64
+			lng      *float64
+			distance *float64
+
+			neighborhoodCenterLat *float64
+			neighborhoodCenterLng *float64
+			neighborhoodBoundary  pgtype.Polygon
 		}
 		err := rows.Scan(
 			&post.ID,
@@ -164,24 +262,37 @@ func resolvePosts(ctx context.Context, s *server.Server, in resolvePostsInput) (
 			&post.Likes,
 			&post.CommentCount,
 			&post.ViewTimes,
+			&result.lat,
+			&result.lng,
 			&result.createdAt,
 			&result.updatedAt,
 			&neighborhood.ID,
 			&neighborhood.Name,
 			&neighborhood.Slug,
+			&neighborhood.ZIPCodes,
+			&result.neighborhoodCenterLat,
+			&result.neighborhoodCenterLng,
+			&result.neighborhoodBoundary,
+			&neighborhood.RetiredAt,
+			&neighborhood.MergedIntoID,
+			&result.distance,
 		)
 		if err != nil {
 			return nil, nil, err
 		}
 
+		neighborhood.SetGeography(result.neighborhoodCenterLat, result.neighborhoodCenterLng, result.neighborhoodBoundary)
+
+		post.SetLocation(result.lat, result.lng)
 		post.Kind = server.PostKind(strings.ToUpper(result.postKind))
 		post.CreatedAt = result.createdAt.Time
 		post.UpdatedAt = result.updatedAt.Time
 
 		postResolvers = append(postResolvers, &PostResolver{
-			server:       s,
-			post:         &post,
-			neighborhood: &neighborhood,
+			server:         s,
+			post:           &post,
+			neighborhood:   &neighborhood,
+			distanceMeters: result.distance,
 		})
 		i++
 	}
@@ -237,6 +348,7 @@ func postByID(s *server.Server, postID int64) (*server.Post, error) {
 		return nil, err
 	}
 
+	p.Kind = server.PostKind(strings.ToUpper(string(p.Kind)))
 	p.Processing = result.processing.Bool
 	p.CreatedAt = result.createdAt.Time
 
@@ -26,6 +26,9 @@ type PostResolver struct {
 
 	post         *server.Post
 	neighborhood *server.Neighborhood
+
+	// distanceMeters is set when posts are resolved by location
+	distanceMeters *float64
 }
 
 func (r *PostResolver) ID() graphql.ID {
@@ -63,7 +66,10 @@ func (r *PostResolver) Author() (*UserResolver, error) {
 
 func (r *PostResolver) Neighborhood() (*NeighborhoodResolver, error) {
 	if r.neighborhood != nil {
-		return &NeighborhoodResolver{neighborhood: r.neighborhood}, nil
+		return &NeighborhoodResolver{
+			server:       r.server,
+			neighborhood: r.neighborhood,
+		}, nil
 	}
 
 	neighborhood, err := r.server.NeighborhoodByID(r.post.NeighborhoodID)
@@ -78,6 +84,18 @@ func (r *PostResolver) Neighborhood() (*NeighborhoodResolver, error) {
 	}, nil
 }
 
+func (r *PostResolver) Location() *LocationResolver {
+	if r.post.Location == nil {
+		return nil
+	}
+
+	return &LocationResolver{location: *r.post.Location}
+}
+
+func (r *PostResolver) DistanceMeters() *float64 {
+	return r.distanceMeters
+}
+
 func (r *PostResolver) Preview() *PostMediaResolver {
 	if r.post.Preview != nil {
 		return &PostMediaResolver{
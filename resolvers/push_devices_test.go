@@ -0,0 +1,139 @@
+package resolvers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushDevices(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		posterID int64 = 1
+		askerID  int64 = 2
+	)
+	harness.MustCreateUser(posterID)
+	harness.MustCreateUser(askerID)
+	_, err := connPool.Exec(`update users set name = 'Alex' where id = $1`, askerID)
+	require.NoError(t, err)
+
+	register := func(userID int64, platform string, token string) {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+				mutation {
+					registerPushDevice(input: {platform: %s, token: "%s"})
+				}`, platform, token),
+		}, nil)
+	}
+
+	register(posterID, "APNS", "phone")
+	register(posterID, "FCM", "tablet")
+	register(posterID, "FCM", "uninstalled")
+	harness.push.InvalidTokens["uninstalled"] = true
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query: `
+			mutation {
+				createPost(input: {title: "free couch", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	var convo struct {
+		GetOrCreateConversation struct {
+			Conversation struct {
+				ID string
+			}
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: askerID,
+		Query: fmt.Sprintf(`
+			mutation {
+				getOrCreateConversation(input: {postID: "%s"}) {
+					conversation {
+						id
+					}
+				}
+			}`, post.CreatePost.ID),
+	}, &convo)
+	convoID := convo.GetOrCreateConversation.Conversation.ID
+
+	sendMessage := func() {
+		harness.MustExec(ExecInput{
+			UserID: askerID,
+			Query: fmt.Sprintf(`
+				mutation {
+					sendMessage(input: {conversationID: "%s", body: "still available?"}) {
+						message {
+							id
+						}
+					}
+				}`, convoID),
+		}, nil)
+		harness.DrainOutbox()
+	}
+
+	t.Run("every device gets the push", func(t *testing.T) {
+		sendMessage()
+
+		pushes := harness.push.PushesTo(posterID)
+		require.Len(t, pushes, 2)
+
+		var platforms []server.PushPlatform
+		for _, push := range pushes {
+			platforms = append(platforms, push.Device.Platform)
+			assert.Equal(t, "Alex", push.Message.Title)
+			assert.Equal(t, "still available?", push.Message.Body)
+			assert.Equal(t, "cobbles://conversations/"+convoID, push.Message.Link)
+		}
+		assert.ElementsMatch(t, []server.PushPlatform{server.PushPlatformAPNs, server.PushPlatformFCM}, platforms)
+	})
+
+	t.Run("dead tokens are disabled", func(t *testing.T) {
+		var enabled bool
+		var reason *string
+		err := connPool.QueryRow(`
+			select enabled, disabled_reason from user_device_tokens where device_token = 'uninstalled'
+		`).Scan(&enabled, &reason)
+		require.NoError(t, err)
+		assert.False(t, enabled)
+		require.NotNil(t, reason)
+
+		sendMessage()
+		assert.Len(t, harness.push.PushesTo(posterID), 4)
+	})
+
+	t.Run("a token moves to whoever registers it last", func(t *testing.T) {
+		register(askerID, "APNS", "phone")
+
+		var userID int64
+		err := connPool.QueryRow(`select user_id from user_device_tokens where device_token = 'phone'`).Scan(&userID)
+		require.NoError(t, err)
+		assert.Equal(t, askerID, userID)
+	})
+
+	t.Run("unregistered devices get nothing", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: posterID,
+			Query:  `mutation { unregisterPushDevice(token: "tablet") }`,
+		}, nil)
+
+		before := len(harness.push.PushesTo(posterID))
+		sendMessage()
+		assert.Len(t, harness.push.PushesTo(posterID), before)
+	})
+}
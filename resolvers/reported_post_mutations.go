@@ -2,21 +2,43 @@ package resolvers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/jackc/pgx"
+	"github.com/lambdacollective/cobbles-api/server"
 )
 
 type ReportedPostInput struct {
-	PostID              int32
-	PersonReportingID   int32
-	ReportingReasonText string
+	PostID int32
+	// Deprecated: ignored, the report is always from the current user
+	PersonReportingID   *int32
+	Reason              string
+	ReportingReasonText *string
 }
 
 func (r *Resolver) CreateReportedPost(ctx context.Context, args struct {
 	Input ReportedPostInput
 }) (*ReportedPostResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reasonText string
+	if args.Input.ReportingReasonText != nil {
+		reasonText = *args.Input.ReportingReasonText
+	}
+
 	reportedPost, err := r.server.CreateReportedPost(
 		args.Input.PostID,
-		args.Input.PersonReportingID,
-		args.Input.ReportingReasonText,
+		int32(userID),
+		server.ReportReason(strings.ToLower(args.Input.Reason)),
+		reasonText,
 	)
 
 	if err != nil {
@@ -25,3 +47,168 @@ func (r *Resolver) CreateReportedPost(ctx context.Context, args struct {
 
 	return &ReportedPostResolver{server: r.server, reportedPost: reportedPost}, nil
 }
+
+// UpdateReportedPost takes action on a report's post, the post's other open
+// reports get the same action
+func (r *Resolver) UpdateReportedPost(ctx context.Context, args struct {
+	Input struct {
+		ID     graphql.ID
+		Action string
+	}
+}) (*ReportedPostResolver, error) {
+	moderatorID, err := requireRole(ctx, server.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(string(args.Input.ID), 10, 64)
+	if err != nil {
+		return nil, errors.New("reported post not found")
+	}
+
+	reportedPost, err := r.server.ReportedPostByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	action := moderationAction(args.Input.Action)
+	err = r.auditModeration(tx, moderatorID, int64(reportedPost.PostID), action, func() error {
+		return r.server.UpdateReportedPost(tx, moderatorID, id, action)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	reportedPost, err = r.server.ReportedPostByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReportedPostResolver{server: r.server, reportedPost: reportedPost}, nil
+}
+
+// ModeratePost - moderators only, takes action on a post and closes its open
+// reports
+func (r *Resolver) ModeratePost(ctx context.Context, args struct {
+	Input struct {
+		PostID      graphql.ID
+		Action      string
+		SuspendDays *int32
+	}
+}) (*PostResolver, error) {
+	moderatorID, err := requireRole(ctx, server.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, err := strconv.ParseInt(string(args.Input.PostID), 10, 64)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+
+	var suspension time.Duration
+	if args.Input.SuspendDays != nil {
+		if *args.Input.SuspendDays < 1 {
+			return nil, errors.New("suspendDays must be at least 1")
+		}
+		if *args.Input.SuspendDays > server.MaxSuspensionDays {
+			return nil, fmt.Errorf("suspendDays must be at most %d, ban the user instead", server.MaxSuspensionDays)
+		}
+		suspension = time.Duration(*args.Input.SuspendDays) * 24 * time.Hour
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	action := moderationAction(args.Input.Action)
+	err = r.auditModeration(tx, moderatorID, postID, action, func() error {
+		return r.server.ModeratePost(tx, moderatorID, postID, action, suspension)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	post, err := postByID(r.server, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostResolver{server: r.server, post: post}, nil
+}
+
+// auditModeration runs moderate, a moderator's action on a post in tx, and
+// records the post before and after it. Suspending the author records their
+// account status too.
+func (r *Resolver) auditModeration(tx *pgx.Tx, moderatorID, postID int64, action server.ModerationAction, moderate func() error) error {
+	before, err := r.server.PostModerationForUpdate(tx, postID)
+	if err != nil {
+		return err
+	}
+
+	authorBefore, err := r.server.UserAccountStatusForUpdate(tx, before.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := moderate(); err != nil {
+		return err
+	}
+
+	after, err := r.server.PostModerationForUpdate(tx, postID)
+	if err != nil {
+		return err
+	}
+
+	if err := r.audit(
+		tx,
+		moderatorID,
+		server.AuditActionForModeration(action),
+		server.AuditTargetPost,
+		postID,
+		before,
+		after,
+	); err != nil {
+		return err
+	}
+
+	if action != server.ModerationActionSuspendAuthor {
+		return nil
+	}
+
+	authorAfter, err := r.server.UserAccountStatusForUpdate(tx, before.UserID)
+	if err != nil {
+		return err
+	}
+
+	return r.audit(
+		tx,
+		moderatorID,
+		server.AuditActionSuspendUser,
+		server.AuditTargetUser,
+		before.UserID,
+		authorBefore,
+		authorAfter,
+	)
+}
+
+// moderationAction converts from the GraphQL ModerationAction enum
+func moderationAction(action string) server.ModerationAction {
+	return server.ModerationAction(strings.ToLower(action))
+}
@@ -0,0 +1,358 @@
+package resolvers
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerationQueue(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		authorID    int64 = 1
+		reporter1ID int64 = 2
+		reporter2ID int64 = 3
+		moderatorID int64 = 4
+	)
+
+	for _, userID := range []int64{authorID, reporter1ID, reporter2ID, moderatorID} {
+		harness.MustCreateUser(userID)
+	}
+
+	_, err := connPool.Exec(`update users set roles = '{moderator}' where id = $1`, moderatorID)
+	require.NoError(t, err)
+
+	createPost := func(title string) []*errors.QueryError {
+		return harness.Exec(ExecInput{
+			UserID: authorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPost(input: {title: "%s", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}`, title),
+		}, nil)
+	}
+
+	postID := func(title string) string {
+		var id int64
+		err := connPool.QueryRow(`select id from posts where title = $1`, title).Scan(&id)
+		require.NoError(t, err)
+		return fmt.Sprint(id)
+	}
+
+	report := func(userID int64, title string, reason string) {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createReportedPost(input: {postID: "%s", reason: %s}) {
+						id
+					}
+				}`, postID(title), reason),
+		}, nil)
+	}
+
+	require.Empty(t, createPost("spammy"))
+	require.Empty(t, createPost("rude"))
+
+	report(reporter1ID, "spammy", "SPAM")
+	report(reporter2ID, "spammy", "HARASSMENT")
+	report(reporter1ID, "rude", "HARASSMENT")
+
+	queue := `
+	{
+		reportedPosts {
+			reportedPosts {
+				post {
+					title
+				}
+				reportCount
+				reasons
+			}
+		}
+	}`
+
+	t.Run("moderators see reports grouped by post", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: reporter1ID,
+				Query:  queue,
+			},
+			ExpectedErrors: []*errors.QueryError{
+				{
+					Message: "unauthorized",
+				},
+			},
+		})
+
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: moderatorID,
+				Query:  queue,
+			},
+			ExpectedResult: map[string]interface{}{
+				"reportedPosts": map[string]interface{}{
+					"reportedPosts": []map[string]interface{}{
+						{
+							"post":        map[string]interface{}{"title": "spammy"},
+							"reportCount": 2,
+							"reasons":     []string{"HARASSMENT", "SPAM"},
+						},
+						{
+							"post":        map[string]interface{}{"title": "rude"},
+							"reportCount": 1,
+							"reasons":     []string{"HARASSMENT"},
+						},
+					},
+				},
+			},
+		})
+	})
+
+	t.Run("hidden posts are only in their author's posts", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: moderatorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					moderatePost(input: {postID: "%s", action: HIDE_POST}) {
+						id
+					}
+				}`, postID("spammy")),
+		}, nil)
+
+		require.Equal(t, []string{"rude", "spammy"}, postTitles(harness, authorID, authorID))
+		require.Equal(t, []string{"rude"}, postTitles(harness, reporter1ID, authorID))
+
+		var spammyReports []string
+		rows, err := connPool.Query(`
+			select action_taken from reported_posts where post_id = $1
+		`, postID("spammy"))
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var action string
+			require.NoError(t, rows.Scan(&action))
+			spammyReports = append(spammyReports, action)
+		}
+		require.NoError(t, rows.Err())
+		require.Equal(t, []string{"hide_post", "hide_post"}, spammyReports)
+	})
+
+	t.Run("dismissing shows a moderator hidden post again", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: moderatorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					moderatePost(input: {postID: "%s", action: DISMISS}) {
+						id
+					}
+				}`, postID("spammy")),
+		}, nil)
+
+		require.Equal(t, []string{"rude", "spammy"}, postTitles(harness, reporter1ID, authorID))
+	})
+
+	t.Run("suspensions are at most ten years", func(t *testing.T) {
+		for _, days := range []int32{server.MaxSuspensionDays + 1, math.MaxInt32} {
+			errs := harness.Exec(ExecInput{
+				UserID: moderatorID,
+				Query: fmt.Sprintf(`
+					mutation {
+						moderatePost(input: {postID: "%s", action: SUSPEND_AUTHOR, suspendDays: %d}) {
+							id
+						}
+					}`, postID("rude"), days),
+			}, nil)
+			require.Len(t, errs, 1)
+			require.Equal(t, "suspendDays must be at most 3650, ban the user instead", errs[0].Message)
+		}
+
+		require.NoError(t, harness.resolver.server.CheckAccountActive(authorID))
+	})
+
+	t.Run("suspended authors can't post", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: moderatorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					moderatePost(input: {postID: "%s", action: SUSPEND_AUTHOR, suspendDays: 3}) {
+						id
+					}
+				}`, postID("rude")),
+		}, nil)
+
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: moderatorID,
+				Query:  queue,
+			},
+			ExpectedResult: map[string]interface{}{
+				"reportedPosts": map[string]interface{}{
+					"reportedPosts": []interface{}{},
+				},
+			},
+		})
+
+		errs := createPost("again")
+		require.Len(t, errs, 1)
+		require.Contains(t, errs[0].Message, "your account is suspended until")
+	})
+}
+
+// postTitles is the titles of authorID's posts that viewerID sees
+func postTitles(harness *Harness, viewerID, authorID int64) []string {
+	var res struct {
+		OtherUser struct {
+			Posts struct {
+				Posts []struct {
+					Title string
+				}
+			}
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: viewerID,
+		Query: fmt.Sprintf(`
+		{
+			otherUser(id: %d) {
+				posts(input: {otherUserID: %d}) {
+					posts {
+						title
+					}
+				}
+			}
+		}`, authorID, authorID),
+	}, &res)
+
+	var titles []string
+	for _, post := range res.OtherUser.Posts.Posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func TestAutoHide(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		authorID    int64 = 1
+		moderatorID int64 = 2
+		// established neighbors count 1, the new one half
+		reporterIDs         = []int64{3, 4, 5}
+		newReporterID int64 = 6
+	)
+
+	for _, userID := range append(reporterIDs, authorID, moderatorID, newReporterID) {
+		harness.MustCreateUser(userID)
+	}
+
+	_, err := connPool.Exec(`
+		update users
+		set created_at = now() - interval '30 days'
+		where id = any($1)
+	`, reporterIDs)
+	require.NoError(t, err)
+
+	_, err = connPool.Exec(`update users set roles = '{moderator}' where id = $1`, moderatorID)
+	require.NoError(t, err)
+
+	var postIDs []string
+	for _, title := range []string{"first", "second"} {
+		var res struct {
+			CreatePost struct {
+				ID string
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: authorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPost(input: {title: "%s", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}`, title),
+		}, &res)
+		postIDs = append(postIDs, res.CreatePost.ID)
+	}
+
+	report := func(userID int64, postID string) {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createReportedPost(input: {postID: "%s", reason: SPAM}) {
+						id
+					}
+				}`, postID),
+		}, nil)
+	}
+
+	t.Run("hidden once reports weigh enough", func(t *testing.T) {
+		// reporting again doesn't count twice
+		report(reporterIDs[0], postIDs[0])
+		report(reporterIDs[0], postIDs[0])
+		report(reporterIDs[1], postIDs[0])
+		report(newReporterID, postIDs[0])
+		require.Equal(t, []string{"second", "first"}, postTitles(harness, newReporterID, authorID))
+
+		report(reporterIDs[2], postIDs[0])
+		require.Equal(t, []string{"second"}, postTitles(harness, newReporterID, authorID))
+		require.Equal(t, []string{"second", "first"}, postTitles(harness, authorID, authorID))
+
+		var res struct {
+			ReportedPosts struct {
+				ReportedPosts []struct {
+					ReportCount  int32
+					ReportWeight float64
+					AutoHiddenAt *string
+				}
+			}
+		}
+		harness.MustExec(ExecInput{
+			UserID: moderatorID,
+			Query: `
+			{
+				reportedPosts {
+					reportedPosts {
+						reportCount
+						reportWeight
+						autoHiddenAt
+					}
+				}
+			}`,
+		}, &res)
+
+		groups := res.ReportedPosts.ReportedPosts
+		require.Len(t, groups, 1)
+		require.Equal(t, int32(5), groups[0].ReportCount)
+		require.Equal(t, 3.5, groups[0].ReportWeight)
+		require.NotNil(t, groups[0].AutoHiddenAt)
+	})
+
+	t.Run("dismissing shows it again", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: moderatorID,
+			Query: fmt.Sprintf(`
+				mutation {
+					moderatePost(input: {postID: "%s", action: DISMISS}) {
+						id
+					}
+				}`, postIDs[0]),
+		}, nil)
+
+		require.Equal(t, []string{"second", "first"}, postTitles(harness, newReporterID, authorID))
+	})
+
+	t.Run("a moderator's report hides on its own", func(t *testing.T) {
+		report(moderatorID, postIDs[1])
+		require.Equal(t, []string{"first"}, postTitles(harness, newReporterID, authorID))
+	})
+}
@@ -1,24 +1,13 @@
 package resolvers
 
 import (
-	"database/sql"
 	"strconv"
-	"time"
+	"strings"
 
 	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/lambdacollective/cobbles-api/server"
 )
 
-type ReportedPost struct {
-	ID                  int64
-	PostID              int64
-	PersonReportingID   int64
-	ReportingReasonText string
-	ActionTaken         sql.NullInt64
-	CreatedAt           time.Time
-	UpdatedAt           time.Time
-}
-
 type ReportedPostResolver struct {
 	server       *server.Server
 	reportedPost *server.ReportedPost
@@ -40,10 +29,26 @@ func (r *ReportedPostResolver) ReportingReasonText() string {
 	return r.reportedPost.ReportingReasonText
 }
 
-func (r *ReportedPostResolver) ActionTaken() int32 {
-	// r.reportedPost.ActionTaken.
-	// actionStr := strconv.Itoa(r.reportedPost.ActionTaken)
-	return int32(r.reportedPost.ActionTaken.Int64)
+func (r *ReportedPostResolver) Reason() string {
+	return strings.ToUpper(string(r.reportedPost.Reason))
+}
+
+// ActionTaken is null while the report is open
+func (r *ReportedPostResolver) ActionTaken() *string {
+	if r.reportedPost.ActionTaken == nil {
+		return nil
+	}
+
+	action := strings.ToUpper(string(*r.reportedPost.ActionTaken))
+	return &action
+}
+
+func (r *ReportedPostResolver) ActionTakenAt() *Timestamp {
+	if r.reportedPost.ActionTakenAt == nil {
+		return nil
+	}
+
+	return &Timestamp{*r.reportedPost.ActionTakenAt}
 }
 
 func (r *ReportedPostResolver) CreatedAt() Timestamp {
@@ -53,3 +58,72 @@ func (r *ReportedPostResolver) CreatedAt() Timestamp {
 func (r *ReportedPostResolver) UpdatedAt() Timestamp {
 	return Timestamp{r.reportedPost.UpdatedAt}
 }
+
+// ReportedPostGroupResolver is a post in the moderation queue
+type ReportedPostGroupResolver struct {
+	server *server.Server
+
+	group *server.ReportedPostGroup
+}
+
+func (r *ReportedPostGroupResolver) Post() (*PostResolver, error) {
+	post, err := postByID(r.server, r.group.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostResolver{server: r.server, post: post}, nil
+}
+
+func (r *ReportedPostGroupResolver) ReportCount() int32 {
+	return r.group.Reports
+}
+
+// ReportWeight is what the reports count toward auto hiding the post
+func (r *ReportedPostGroupResolver) ReportWeight() float64 {
+	return r.group.Weight
+}
+
+// AutoHiddenAt is when reports hid the post, while it waits for a moderator
+func (r *ReportedPostGroupResolver) AutoHiddenAt() *Timestamp {
+	if r.group.AutoHiddenAt == nil {
+		return nil
+	}
+
+	return &Timestamp{*r.group.AutoHiddenAt}
+}
+
+func (r *ReportedPostGroupResolver) Reasons() []string {
+	reasons := []string{}
+	for _, reason := range r.group.Reasons {
+		reasons = append(reasons, strings.ToUpper(string(reason)))
+	}
+
+	return reasons
+}
+
+// Reports are the post's open reports, newest first
+func (r *ReportedPostGroupResolver) Reports() ([]*ReportedPostResolver, error) {
+	reportedPosts, err := r.server.ReportsForPost(r.group.PostID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*ReportedPostResolver{}
+	for i := range reportedPosts {
+		resolvers = append(resolvers, &ReportedPostResolver{
+			server:       r.server,
+			reportedPost: &reportedPosts[i],
+		})
+	}
+
+	return resolvers, nil
+}
+
+func (r *ReportedPostGroupResolver) FirstReportedAt() Timestamp {
+	return Timestamp{r.group.FirstReportedAt}
+}
+
+func (r *ReportedPostGroupResolver) LastReportedAt() Timestamp {
+	return Timestamp{r.group.LastReportedAt}
+}
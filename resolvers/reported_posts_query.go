@@ -0,0 +1,71 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type ReportedPostsResult struct {
+	groups        []*ReportedPostGroupResolver
+	nextPageToken *string
+}
+
+// ReportedPosts - moderators only, the posts with open reports grouped by
+// post, oldest post first
+func (r *Resolver) ReportedPosts(ctx context.Context, args struct {
+	Input *struct {
+		PageToken *string
+		Limit     *int32
+	}
+}) (*ReportedPostsResult, error) {
+	if _, err := requireRole(ctx, server.RoleModerator); err != nil {
+		return nil, err
+	}
+
+	var pageToken *string
+	var limit int32 = 100
+	if args.Input != nil {
+		pageToken = args.Input.PageToken
+		if args.Input.Limit != nil && *args.Input.Limit > 0 && *args.Input.Limit < limit {
+			limit = *args.Input.Limit
+		}
+	}
+
+	afterPostID, err := DecodeAfterIDCursor(pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := r.server.ReportedPostQueue(afterPostID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextPageToken *string
+	if int32(len(groups)) > limit {
+		groups = groups[:limit]
+		nextPageToken = EncodeAfterIDCursor(groups[limit-1].PostID)
+	}
+
+	result := &ReportedPostsResult{
+		groups:        []*ReportedPostGroupResolver{},
+		nextPageToken: nextPageToken,
+	}
+	for i := range groups {
+		result.groups = append(result.groups, &ReportedPostGroupResolver{
+			server: r.server,
+			group:  &groups[i],
+		})
+	}
+
+	return result, nil
+}
+
+func (r *ReportedPostsResult) ReportedPosts() []*ReportedPostGroupResolver {
+	return r.groups
+}
+
+func (r *ReportedPostsResult) NextPageToken() *string {
+	return r.nextPageToken
+}
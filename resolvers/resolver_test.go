@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -16,6 +18,7 @@ import (
 	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/graph-gophers/graphql-go/errors"
 	"github.com/jackc/pgx"
+	"github.com/jinzhu/gorm"
 	"github.com/lambdacollective/cobbles-api/gqlschema"
 	"github.com/lambdacollective/cobbles-api/server"
 	snakecase "github.com/segmentio/go-snakecase"
@@ -24,12 +27,14 @@ import (
 )
 
 var connPool *pgx.ConnPool
+var db *gorm.DB
 
 func init() {
 	var connConfig pgx.ConnConfig
 	var err error
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
+		databaseURL = "postgres://postgres@localhost/test?sslmode=disable"
 		connConfig = pgx.ConnConfig{
 			User:     "postgres",
 			Database: "test",
@@ -48,29 +53,60 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	// the server still keeps some models in GORM
+	db, err = server.OpenDB(databaseURL)
+	if err != nil {
+		panic(err)
+	}
 }
 
 type Harness struct {
-	t      *testing.T
-	schema *graphql.Schema
-	mutex  *sync.Mutex
+	t        *testing.T
+	schema   *graphql.Schema
+	resolver *Resolver
+	sms      *server.FakeSMSSender
+	email    *server.FakeEmailSender
+	push     *server.FakePushSender
+	mutex    *sync.Mutex
 }
 
 func NewTestHarness(t *testing.T) *Harness {
-	resolver := NewResolver(&server.Server{
+	sms := &server.FakeSMSSender{}
+	email := &server.FakeEmailSender{}
+	push := &server.FakePushSender{InvalidTokens: map[string]bool{}}
+	srv := &server.Server{
+		SMS:                 sms,
+		Email:               email,
+		Push:                push,
+		EmailLoginURL:       "cobbles://login/email",
+		WebAuthnRPID:        "localhost",
+		WebAuthnRPName:      "Cobbles",
+		WebAuthnOrigins:     []string{"https://localhost"},
+		AutoHideThreshold:   server.DefaultAutoHideThreshold,
 		ConnPool:            connPool,
+		DB:                  db,
 		S3UserMediaBucket:   "llc-cobbles-dev-user-media",
 		S3ImageProxyBaseURL: "https://llc-cobbles-dev-user-images.imgix.net",
 		S3:                  s3.New(session.New()),
 		// ImgixProcessedMediaEndpoint: "https://processed-user-media.imgix.net",
 		ImgixProcessedMediaEndpoint: "https://llc-cobbles-dev-processed-user-images.imgix.net",
 		ImgixUserMediaMediaEndpoint: "https://llc-cobbles-dev-user-images.imgix.net",
-	})
+	}
+	srv.ContentFilters = []server.ContentFilter{
+		server.NewDefaultWordlistFilter(),
+		&server.DBRuleFilter{Server: srv},
+	}
+	resolver := NewResolver(srv)
 
 	return &Harness{
-		t:      t,
-		schema: gqlschema.MustParseSchema(resolver),
-		mutex:  &sync.Mutex{},
+		t:        t,
+		schema:   gqlschema.MustParseSchema(resolver),
+		resolver: resolver,
+		sms:      sms,
+		email:    email,
+		push:     push,
+		mutex:    &sync.Mutex{},
 	}
 }
 
@@ -146,12 +182,28 @@ WHERE table_schema = 'public'
 
 type ExecInput struct {
 	UserID    int64
+	SessionID string
+	ClientIP  string
 	Query     string
 	Variables map[string]interface{}
 }
 
 func (h *Harness) Exec(in ExecInput, to interface{}) []*errors.QueryError {
-	ctx := context.WithValue(context.Background(), "user_id", in.UserID)
+	// zero is logged out, like a request without an access token
+	ctx := context.Background()
+	if in.UserID != 0 {
+		// what authMiddleware would find in the user's access token
+		roles, err := h.resolver.server.UserRoles(in.UserID)
+		require.NoError(h.t, err)
+		ctx = context.WithValue(ctx, "user_id", in.UserID)
+		ctx = context.WithValue(ctx, "roles", roles)
+	}
+	if in.SessionID != "" {
+		ctx = context.WithValue(ctx, "session_id", in.SessionID)
+	}
+	if in.ClientIP != "" {
+		ctx = context.WithValue(ctx, "client_ip", in.ClientIP)
+	}
 	resp := h.schema.Exec(ctx, in.Query, "", in.Variables)
 
 	if len(resp.Errors) > 0 {
@@ -190,6 +242,90 @@ ON CONFLICT (id)
 	require.NoError(h.t, err)
 }
 
+// DrainOutbox does what cobbles-worker would with everything queued so far
+func (h *Harness) DrainOutbox() {
+	for {
+		n, err := h.resolver.server.DrainOutbox(100)
+		require.NoError(h.t, err)
+		if n == 0 {
+			return
+		}
+	}
+}
+
+// Subscribe starts a subscription as userID, like the graphql-ws handler
+// would, until cancel is called
+func (h *Harness) Subscribe(userID int64, query string, variables map[string]interface{}) (responses <-chan interface{}, cancel func()) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "user_id", userID))
+	responses, err := h.schema.Subscribe(ctx, query, "", variables)
+	require.NoError(h.t, err)
+	return responses, cancel
+}
+
+// NextResponse waits for a subscription's next response and unmarshals it
+// into to, returning its errors
+func (h *Harness) NextResponse(responses <-chan interface{}, to interface{}) []*errors.QueryError {
+	select {
+	case r, ok := <-responses:
+		require.True(h.t, ok, "subscription ended")
+
+		resp := r.(*graphql.Response)
+		if len(resp.Errors) > 0 {
+			return resp.Errors
+		}
+
+		err := json.Unmarshal(resp.Data, to)
+		require.NoError(h.t, err)
+		return nil
+	case <-time.After(5 * time.Second):
+		require.FailNow(h.t, "timed out waiting for subscription")
+		return nil
+	}
+}
+
+type LoginResult struct {
+	Token        string
+	RefreshToken string
+}
+
+// MustLogin goes through requestLoginCode and loginUser with the code that
+// was texted, creating the user on their first login
+func (h *Harness) MustLogin(phoneNumber string, deviceID string) LoginResult {
+	h.MustExec(ExecInput{
+		Variables: map[string]interface{}{
+			"phoneNumber": phoneNumber,
+		},
+		Query: `
+			mutation RequestLoginCode($phoneNumber: String!) {
+				requestLoginCode(input: {phoneNumber: $phoneNumber})
+			}
+		`}, nil)
+
+	sms, ok := h.sms.LastMessage(phoneNumber)
+	require.True(h.t, ok, "no login code texted to %s", phoneNumber)
+	loginCode := strings.TrimPrefix(sms.Message, "Your login code is ")
+
+	var res struct {
+		LoginUser LoginResult
+	}
+	h.MustExec(ExecInput{
+		Variables: map[string]interface{}{
+			"phoneNumber": phoneNumber,
+			"loginCode":   loginCode,
+			"deviceID":    deviceID,
+		},
+		Query: `
+			mutation Login($phoneNumber: String!, $loginCode: String!, $deviceID: String!) {
+				loginUser(input: {phoneNumber: $phoneNumber, loginCode: $loginCode, deviceID: $deviceID}) {
+					token
+					refreshToken
+				}
+			}
+		`}, &res)
+
+	return res.LoginUser
+}
+
 // TODO
 func (h *Harness) NewUser() string {
 	return "TODO: user ID"
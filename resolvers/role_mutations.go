@@ -0,0 +1,79 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+// SetUserRoles - admin only, replaces a user's roles. They take effect when
+// the user's access token is next refreshed.
+func (r *Resolver) SetUserRoles(ctx context.Context, args struct {
+	Input struct {
+		UserID graphql.ID
+		Roles  []string
+	}
+}) ([]string, error) {
+	adminID, err := requireRole(ctx, server.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.ParseInt(string(args.Input.UserID), 10, 64)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	var roles []server.Role
+	for _, role := range args.Input.Roles {
+		roles = append(roles, server.Role(strings.ToLower(role)))
+	}
+
+	tx, err := r.server.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.server.UserRolesForUpdate(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err = r.server.SetUserRoles(tx, userID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.audit(
+		tx,
+		adminID,
+		server.AuditActionSetUserRoles,
+		server.AuditTargetUser,
+		userID,
+		map[string][]server.Role{"roles": before},
+		map[string][]server.Role{"roles": roles},
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return gqlRoles(roles), nil
+}
+
+// gqlRoles converts roles to the GraphQL Role enum
+func gqlRoles(roles []server.Role) []string {
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, strings.ToUpper(string(role)))
+	}
+
+	return names
+}
@@ -0,0 +1,127 @@
+package resolvers
+
+import (
+	"testing"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/lambdacollective/cobbles-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoles(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		adminUserID     int64 = 1
+		moderatorUserID int64 = 2
+		userID          int64 = 3
+	)
+
+	harness.MustCreateUser(adminUserID)
+	harness.MustCreateUser(moderatorUserID)
+	harness.MustCreateUser(userID)
+
+	_, err := connPool.Exec(`update users set roles = '{admin}' where id = $1`, adminUserID)
+	require.NoError(t, err)
+
+	t.Run("only admins set roles", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: userID,
+				Query: `
+				mutation {
+					setUserRoles(input: {userID: 3, roles: [ADMIN]})
+				}`,
+			},
+			ExpectedErrors: []*errors.QueryError{
+				{
+					Message: "unauthorized",
+				},
+			},
+		})
+
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: adminUserID,
+				Query: `
+				mutation {
+					setUserRoles(input: {userID: 2, roles: [MODERATOR]})
+				}`,
+			},
+			ExpectedResult: map[string]interface{}{
+				"setUserRoles": []string{"MODERATOR"},
+			},
+		})
+	})
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Query: `
+			mutation {
+				createPost(input: {title: "reported", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}
+		`}, &post)
+
+	var report struct {
+		CreateReportedPost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: userID,
+		Variables: map[string]interface{}{
+			"postID": post.CreatePost.ID,
+		},
+		Query: `
+			mutation Report($postID: ID!) {
+				createReportedPost(input: {postID: $postID, reason: SPAM}) {
+					id
+				}
+			}
+		`}, &report)
+
+	updateReportedPost := func(userID int64) []*errors.QueryError {
+		return harness.Exec(ExecInput{
+			UserID: userID,
+			Variables: map[string]interface{}{
+				"id": report.CreateReportedPost.ID,
+			},
+			Query: `
+				mutation UpdateReportedPost($id: ID!) {
+					updateReportedPost(input: {id: $id, action: DISMISS}) {
+						actionTaken
+					}
+				}
+			`}, nil)
+	}
+
+	t.Run("moderators update reports", func(t *testing.T) {
+		errs := updateReportedPost(userID)
+		require.Len(t, errs, 1)
+		require.Equal(t, "unauthorized", errs[0].Message)
+
+		require.Empty(t, updateReportedPost(moderatorUserID))
+		require.Empty(t, updateReportedPost(adminUserID))
+	})
+
+	t.Run("roles are in the access token after a refresh", func(t *testing.T) {
+		login := harness.MustLogin("+16175550100", "phone")
+		_, err := connPool.Exec(`update users set roles = '{moderator}' where phone_number = '+16175550100'`)
+		require.NoError(t, err)
+
+		tokens, err := harness.resolver.server.RefreshAuthTokens(login.RefreshToken)
+		require.NoError(t, err)
+
+		token, err := harness.resolver.server.ValidateAuthJWT(tokens.Access.Token)
+		require.NoError(t, err)
+		require.Equal(t, []server.Role{server.RoleModerator}, token.Claims.(*server.AuthJWTClaims).Roles)
+	})
+}
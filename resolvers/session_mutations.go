@@ -0,0 +1,57 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Logout revokes the current session, or another of the current user's
+// sessions by ID, eg a lost phone
+func (r *Resolver) Logout(ctx context.Context, args struct {
+	Input *struct {
+		SessionID *graphql.ID
+	}
+}) (bool, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var sessionID string
+	if args.Input != nil && args.Input.SessionID != nil {
+		sessionID = string(*args.Input.SessionID)
+	} else {
+		sessionID, err = ctxSessionID(ctx)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := uuid.FromString(sessionID); err != nil {
+		return false, errors.New("session not found")
+	}
+
+	if err := r.server.RevokeSession(userID, sessionID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// LogoutAllDevices revokes every session of the current user, including this
+// one
+func (r *Resolver) LogoutAllDevices(ctx context.Context) (bool, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.server.RevokeAllSessions(userID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,32 @@
+package resolvers
+
+import (
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lambdacollective/cobbles-api/server"
+)
+
+type SessionResolver struct {
+	session *server.Session
+
+	currentSessionID string
+}
+
+func (r *SessionResolver) ID() graphql.ID {
+	return graphql.ID(r.session.ID)
+}
+
+func (r *SessionResolver) DeviceID() *string {
+	return r.session.DeviceID
+}
+
+func (r *SessionResolver) Current() bool {
+	return r.session.ID == r.currentSessionID
+}
+
+func (r *SessionResolver) CreatedAt() Timestamp {
+	return Timestamp{r.session.CreatedAt}
+}
+
+func (r *SessionResolver) LastSeenAt() Timestamp {
+	return Timestamp{r.session.LastSeenAt}
+}
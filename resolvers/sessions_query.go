@@ -0,0 +1,29 @@
+package resolvers
+
+import "context"
+
+// Sessions - the current user's logged in devices
+func (r *Resolver) Sessions(ctx context.Context) ([]*SessionResolver, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// only missing for tokens minted outside authMiddleware, eg in tests
+	currentSessionID, _ := ctxSessionID(ctx)
+
+	sessions, err := r.server.Sessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*SessionResolver{}
+	for _, session := range sessions {
+		resolvers = append(resolvers, &SessionResolver{
+			session:          session,
+			currentSessionID: currentSessionID,
+		})
+	}
+
+	return resolvers, nil
+}
@@ -0,0 +1,174 @@
+package resolvers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatSubscriptions(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		posterID   int64 = 1
+		neighborID int64 = 2
+		strangerID int64 = 3
+	)
+	harness.MustCreateUser(posterID)
+	harness.MustCreateUser(neighborID)
+	harness.MustCreateUser(strangerID)
+
+	var post struct {
+		CreatePost struct {
+			ID string
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: posterID,
+		Query: `
+			mutation {
+				createPost(input: {title: "free couch", kind: TEXT, poster: "default"}) {
+					id
+				}
+			}`,
+	}, &post)
+
+	conversationUpdated, cancel := harness.Subscribe(posterID, `
+		subscription {
+			conversationUpdated {
+				id
+				startedBy {
+					id
+				}
+			}
+		}`, nil)
+	defer cancel()
+
+	var convo struct {
+		GetOrCreateConversation struct {
+			Conversation struct {
+				ID string
+			}
+		}
+	}
+	harness.MustExec(ExecInput{
+		UserID: neighborID,
+		Query: fmt.Sprintf(`
+			mutation {
+				getOrCreateConversation(input: {postID: "%s"}) {
+					conversation {
+						id
+					}
+				}
+			}`, post.CreatePost.ID),
+	}, &convo)
+	convoID := convo.GetOrCreateConversation.Conversation.ID
+
+	type updatedConversation struct {
+		ConversationUpdated struct {
+			ID        string
+			StartedBy struct {
+				ID string
+			}
+		}
+	}
+
+	// the poster hears of the conversation when it's started
+	var started updatedConversation
+	require.Empty(t, harness.NextResponse(conversationUpdated, &started))
+	assert.Equal(t, convoID, started.ConversationUpdated.ID)
+	assert.Equal(t, "2", started.ConversationUpdated.StartedBy.ID)
+
+	messageQuery := `
+		subscription MessageAdded($conversationID: ID!) {
+			messageAdded(conversationID: $conversationID) {
+				body
+				from {
+					id
+				}
+			}
+		}`
+	messageAdded, cancel := harness.Subscribe(posterID, messageQuery, map[string]interface{}{
+		"conversationID": convoID,
+	})
+	defer cancel()
+
+	notificationAdded, cancel := harness.Subscribe(posterID, `
+		subscription {
+			notificationAdded {
+				kind
+				conversation {
+					id
+				}
+			}
+		}`, nil)
+	defer cancel()
+
+	sendMessage := func(userID int64, body string) {
+		harness.MustExec(ExecInput{
+			UserID: userID,
+			Variables: map[string]interface{}{
+				"input": map[string]interface{}{
+					"conversationID": convoID,
+					"body":           body,
+				},
+			},
+			Query: `mutation SendMessage($input: SendMessageInput!) {
+				sendMessage(input: $input) {
+					message {
+						id
+					}
+				}
+			}`,
+		}, nil)
+	}
+
+	sendMessage(neighborID, "is it still available?")
+
+	var added struct {
+		MessageAdded struct {
+			Body string
+			From struct {
+				ID string
+			}
+		}
+	}
+	require.Empty(t, harness.NextResponse(messageAdded, &added))
+	assert.Equal(t, "is it still available?", added.MessageAdded.Body)
+	assert.Equal(t, "2", added.MessageAdded.From.ID)
+
+	var updated updatedConversation
+	require.Empty(t, harness.NextResponse(conversationUpdated, &updated))
+	assert.Equal(t, convoID, updated.ConversationUpdated.ID)
+
+	var notified struct {
+		NotificationAdded struct {
+			Kind         string
+			Conversation struct {
+				ID string
+			}
+		}
+	}
+	require.Empty(t, harness.NextResponse(notificationAdded, &notified))
+	assert.Equal(t, "MESSAGE", notified.NotificationAdded.Kind)
+	assert.Equal(t, convoID, notified.NotificationAdded.Conversation.ID)
+
+	// the sender sees their own messages too
+	sendMessage(posterID, "yes!")
+	require.Empty(t, harness.NextResponse(messageAdded, &added))
+	assert.Equal(t, "yes!", added.MessageAdded.Body)
+	assert.Equal(t, "1", added.MessageAdded.From.ID)
+
+	// only participants may subscribe to a conversation's messages
+	strangerMessages, cancel := harness.Subscribe(strangerID, messageQuery, map[string]interface{}{
+		"conversationID": convoID,
+	})
+	defer cancel()
+
+	errs := harness.NextResponse(strangerMessages, &added)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "unauthorized", errs[0].Message)
+}
@@ -2,15 +2,17 @@ package resolvers
 
 import (
 	"context"
+	"strings"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/lambdacollective/cobbles-api/server"
 )
 
 type UserAssignDeviceTokenInput struct {
 	DeviceToken string
 }
 
+// UserAssignDeviceToken - deprecated, registers an FCM token, use
+// registerPushDevice
 func (r *Resolver) UserAssignDeviceToken(ctx context.Context, args struct {
 	Input UserAssignDeviceTokenInput
 }) (*bool, error) {
@@ -19,23 +21,47 @@ func (r *Resolver) UserAssignDeviceToken(ctx context.Context, args struct {
 		return nil, err
 	}
 
-	inputDeviceToken := args.Input.DeviceToken
-
-	output, err := r.server.SNS.CreatePlatformEndpoint(&sns.CreatePlatformEndpointInput{
-		Token:                  aws.String(inputDeviceToken),
-		PlatformApplicationArn: aws.String(r.server.SNSPlatformApplicationArn),
-	})
+	_, err = r.server.RegisterPushDevice(currentUserID, server.PushPlatformFCM, args.Input.DeviceToken, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = r.server.ConnPool.Exec(`
-		insert into user_device_tokens (user_id, device_token, endpoint_arn, enabled, created_at, updated_at)
-		values ($1, $2, $3, true, now(), now())
-	`, currentUserID, inputDeviceToken, output.EndpointArn)
+	return nil, nil
+}
+
+// RegisterPushDevice sends the current user's pushes to a device too
+func (r *Resolver) RegisterPushDevice(ctx context.Context, args struct {
+	Input struct {
+		Platform string
+		Token    string
+		DeviceID *string
+	}
+}) (bool, error) {
+	userID, err := ctxUserID(ctx)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	return nil, nil
+	platform := server.PushPlatform(strings.ToLower(args.Input.Platform))
+	if _, err := r.server.RegisterPushDevice(userID, platform, args.Input.Token, args.Input.DeviceID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UnregisterPushDevice stops the current user's pushes to a device
+func (r *Resolver) UnregisterPushDevice(ctx context.Context, args struct {
+	Token string
+}) (bool, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.server.UnregisterPushDevice(userID, args.Token); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
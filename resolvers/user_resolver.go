@@ -2,11 +2,13 @@ package resolvers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"strconv"
 
+	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/pgtype"
 
 	graphql "github.com/graph-gophers/graphql-go"
@@ -52,6 +54,8 @@ func (r *Resolver) UpdateUser(ctx context.Context, args struct {
 		PhotoURL *string
 		ZIPCode  *string
 		Bio      *string
+
+		NeighborhoodSlug *string
 	}
 }) (*UserResolver, error) {
 	currentUserID, err := ctxUserID(ctx)
@@ -64,6 +68,31 @@ func (r *Resolver) UpdateUser(ctx context.Context, args struct {
 	inputZIPCode := args.Input.ZIPCode
 	inputBio := args.Input.Bio
 
+	var neighborhoodID *int64
+	if args.Input.NeighborhoodSlug != nil {
+		neighborhood, err := r.server.NeighborhoodBySlug(*args.Input.NeighborhoodSlug)
+		switch {
+		case err == pgx.ErrNoRows:
+			return nil, errors.New("neighborhood not found")
+		case err != nil:
+			return nil, err
+		case neighborhood.RetiredAt != nil:
+			return nil, errors.New("neighborhood has been retired")
+		}
+
+		neighborhoodID = &neighborhood.ID
+	} else if inputZIPCode != nil {
+		// place the user by ZIP code unless they picked a neighborhood
+		neighborhood, err := r.server.NeighborhoodForZIPCode(*inputZIPCode)
+		if err != nil {
+			return nil, err
+		}
+
+		if neighborhood != nil {
+			neighborhoodID = &neighborhood.ID
+		}
+	}
+
 	var u server.User
 	var result struct {
 		createdAt pgtype.Timestamptz
@@ -75,24 +104,29 @@ func (r *Resolver) UpdateUser(ctx context.Context, args struct {
 			photo_url = coalesce($3, photo_url),
 			zip_code = coalesce($4, zip_code),
 			bio = coalesce($5, bio),
+			neighborhood_id = coalesce($6, neighborhood_id),
 			updated_at = now()
 		where id = $1
 		returning
 			id,
 			name,
 			phone_number,
+			email,
 			zip_code,
 			bio,
 			photo_url,
+			neighborhood_id,
 			created_at,
 			updated_at
-	`, currentUserID, inputName, inputPhotoURL, inputZIPCode, inputBio).Scan(
+	`, currentUserID, inputName, inputPhotoURL, inputZIPCode, inputBio, neighborhoodID).Scan(
 		&u.ID,
 		&u.Name,
 		&u.PhoneNumber,
+		&u.Email,
 		&u.ZIPCode,
 		&u.Bio,
 		&u.PhotoURL,
+		&u.NeighborhoodID,
 		&result.createdAt,
 		&result.updatedAt,
 	); err != nil {
@@ -108,55 +142,32 @@ func (r *Resolver) UpdateUser(ctx context.Context, args struct {
 	}, nil
 }
 
+// UpdateFCMToken registers the current user's FCM device, see
+// RegisterPushDevice
 func (r *Resolver) UpdateFCMToken(ctx context.Context, args struct {
 	Input struct {
-		UserID   string
+		// Deprecated: ignored, it's always the current user's token
+		UserID   *string
 		FCMToken string
 	}
 }) (*UserResolver, error) {
-
-	inputUserID := args.Input.UserID
-	inputFCMToken := args.Input.FCMToken
-
-	var u server.User
-	var result struct {
-		createdAt pgtype.Timestamptz
-		updatedAt pgtype.Timestamptz
+	currentUserID, err := ctxUserID(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if err := r.server.ConnPool.QueryRow(`
-		update users
-		set fcm_token = $2
-		where id = $1
-		returning
-			id,
-			name,
-			phone_number,
-			zip_code,
-			bio,
-			photo_url,
-			fcm_token,
-			created_at,
-			updated_at
-	`, inputUserID, inputFCMToken).Scan(
-		&u.ID,
-		&u.Name,
-		&u.PhoneNumber,
-		&u.ZIPCode,
-		&u.Bio,
-		&u.PhotoURL,
-		&u.FCMToken,
-		&result.createdAt,
-		&result.updatedAt,
-	); err != nil {
+
+	if _, err := r.server.RegisterPushDevice(currentUserID, server.PushPlatformFCM, args.Input.FCMToken, nil); err != nil {
 		return nil, err
 	}
 
-	u.CreatedAt = result.createdAt.Time
-	u.UpdatedAt = result.updatedAt.Time
+	user, err := r.server.UserByID(currentUserID)
+	if err != nil {
+		return nil, err
+	}
 
 	return &UserResolver{
 		server: r.server,
-		user:   &u,
+		user:   user,
 	}, nil
 }
 
@@ -171,6 +182,13 @@ type UsersResolver struct {
 	users  []server.User
 }
 
+// isCurrentUser reports whether the request is from this user, for fields
+// only they may see
+func (r *UserResolver) isCurrentUser(ctx context.Context) bool {
+	userID, err := ctxUserID(ctx)
+	return err == nil && userID == r.user.ID
+}
+
 func (r *UserResolver) ID() graphql.ID {
 	return graphql.ID(strconv.FormatInt(r.user.ID, 10))
 }
@@ -179,10 +197,53 @@ func (r *UserResolver) Name() *string {
 	return r.user.Name
 }
 
-func (r *UserResolver) PhoneNumber() *string {
+// PhoneNumber is only shown to its owner
+func (r *UserResolver) PhoneNumber(ctx context.Context) *string {
+	if !r.isCurrentUser(ctx) {
+		return nil
+	}
+
 	return r.user.PhoneNumber
 }
 
+// Email is only shown to its owner
+func (r *UserResolver) Email(ctx context.Context) *string {
+	if !r.isCurrentUser(ctx) {
+		return nil
+	}
+
+	return r.user.Email
+}
+
+// Roles are only shown to the user and admins
+func (r *UserResolver) Roles(ctx context.Context) (*[]string, error) {
+	if !r.isCurrentUser(ctx) && !server.HasRole(ctxRoles(ctx), server.RoleAdmin) {
+		return nil, nil
+	}
+
+	roles, err := r.server.UserRoles(r.user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := gqlRoles(roles)
+	return &names, nil
+}
+
+// AccountStatus is only shown to the user and admins
+func (r *UserResolver) AccountStatus(ctx context.Context) (*AccountStatusResolver, error) {
+	if !r.isCurrentUser(ctx) && !server.HasRole(ctxRoles(ctx), server.RoleAdmin) {
+		return nil, nil
+	}
+
+	status, err := r.server.UserAccountStatus(r.user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountStatusResolver{server: r.server, status: status}, nil
+}
+
 func (r *UserResolver) PhotoURL() *string {
 	if r.user.PhotoURL != nil {
 		u, err := url.Parse(*r.user.PhotoURL)
@@ -206,6 +267,22 @@ func (r *UserResolver) Bio() *string {
 	return r.user.Bio
 }
 
+func (r *UserResolver) Neighborhood() (*NeighborhoodResolver, error) {
+	if r.user.NeighborhoodID == nil {
+		return nil, nil
+	}
+
+	neighborhood, err := r.server.NeighborhoodByID(*r.user.NeighborhoodID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NeighborhoodResolver{
+		server:       r.server,
+		neighborhood: neighborhood,
+	}, nil
+}
+
 func (r *UserResolver) Followers() *int32 {
 	return r.user.Followers
 }
@@ -170,3 +170,138 @@ func TestUserPosts(t *testing.T) {
 		require.Nil(t, pageToken3)
 	})
 }
+
+func TestIdentityEnforcement(t *testing.T) {
+	harness := NewTestHarness(t)
+	harness.ResetDB()
+
+	var (
+		user1ID int64 = 1
+		user2ID int64 = 2
+	)
+
+	harness.MustCreateUser(user1ID)
+	harness.MustCreateUser(user2ID)
+
+	_, err := connPool.Exec(`
+		update users
+		set phone_number = '+1617555010' || id
+	`)
+	require.NoError(t, err)
+
+	t.Run("updateFCMToken ignores userID", func(t *testing.T) {
+		harness.MustExec(ExecInput{
+			UserID: user2ID,
+			Query: `
+				mutation {
+					updateFCMToken(input: {userID: "1", fcmToken: "hijacked"}) {
+						id
+					}
+				}
+			`}, nil)
+
+		var userID int64
+		err := connPool.QueryRow(`select user_id from user_device_tokens where device_token = 'hijacked'`).Scan(&userID)
+		require.NoError(t, err)
+		require.Equal(t, user2ID, userID)
+	})
+
+	t.Run("phoneNumber is only shown to its owner", func(t *testing.T) {
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: user2ID,
+				Query: `
+				{
+					otherUser(id: 1) {
+						phoneNumber
+					}
+					currentUser() {
+						phoneNumber
+					}
+				}`,
+			},
+			ExpectedResult: map[string]interface{}{
+				"otherUser": map[string]interface{}{
+					"phoneNumber": nil,
+				},
+				"currentUser": map[string]interface{}{
+					"phoneNumber": "+16175550102",
+				},
+			},
+		})
+	})
+
+	t.Run("reports are from the current user", func(t *testing.T) {
+		var res map[string]interface{}
+		harness.MustExec(ExecInput{
+			UserID: user1ID,
+			Query: `
+				mutation {
+					createPost(input: {title: "reported", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}
+			`}, &res)
+		postID := res["createPost"].(map[string]interface{})["id"].(string)
+
+		harness.GQLAssert("", GQLAssertInput{
+			ExecInput: ExecInput{
+				UserID: user2ID,
+				Query: fmt.Sprintf(`
+				mutation {
+					createReportedPost(input: {postID: "%s", personReportingID: "1", ReportingReasonText: "spam"}) {
+						personReportingID
+					}
+				}`, postID),
+			},
+			ExpectedResult: map[string]interface{}{
+				"createReportedPost": map[string]interface{}{
+					"personReportingID": 2,
+				},
+			},
+		})
+	})
+
+	t.Run("comments are removed by their author or the post's", func(t *testing.T) {
+		var res map[string]interface{}
+		harness.MustExec(ExecInput{
+			UserID: user1ID,
+			Query: `
+				mutation {
+					createPost(input: {title: "commented", kind: TEXT, poster: "default"}) {
+						id
+					}
+				}
+			`}, &res)
+		postID := res["createPost"].(map[string]interface{})["id"].(string)
+
+		harness.MustExec(ExecInput{
+			UserID: user1ID,
+			Query: fmt.Sprintf(`
+				mutation {
+					createPostComment(input: {postID: %s, comment: "first"})
+				}`, postID),
+		}, nil)
+
+		var commentID int64
+		err := connPool.QueryRow(`select id from post_comments where post_id = $1`, postID).Scan(&commentID)
+		require.NoError(t, err)
+
+		removeComment := fmt.Sprintf(`
+			mutation {
+				removePostComment(input: {commentID: %d, postID: %s})
+			}`, commentID, postID)
+
+		errs := harness.Exec(ExecInput{
+			UserID: user2ID,
+			Query:  removeComment,
+		}, nil)
+		require.Len(t, errs, 1)
+		require.Equal(t, "comment not found", errs[0].Message)
+
+		harness.MustExec(ExecInput{
+			UserID: user1ID,
+			Query:  removeComment,
+		}, nil)
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 
+	"github.com/jackc/pgx"
+	"github.com/lambdacollective/cobbles-api/server"
 	"github.com/ttacon/libphonenumber"
 	sq "gopkg.in/Masterminds/squirrel.v1"
 )
@@ -44,3 +46,53 @@ func ctxUserID(ctx context.Context) (int64, error) {
 	// }
 
 }
+
+func ctxSessionID(ctx context.Context) (string, error) {
+	if sessionID, ok := ctx.Value("session_id").(string); ok {
+		return sessionID, nil
+	}
+
+	return "", errors.New("request context has no sessionID")
+}
+
+// ctxClientIP is the requesting IP, empty when unknown
+func ctxClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value("client_ip").(string)
+	return ip
+}
+
+// ctxRoles are the roles in the request's access token
+func ctxRoles(ctx context.Context) []server.Role {
+	roles, _ := ctx.Value("roles").([]server.Role)
+	return roles
+}
+
+// requireRole returns the current user's ID, or an error unless their access
+// token grants role
+func requireRole(ctx context.Context, role server.Role) (int64, error) {
+	userID, err := ctxUserID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if !server.HasRole(ctxRoles(ctx), role) {
+		return 0, errors.New("unauthorized")
+	}
+
+	return userID, nil
+}
+
+// audit records an admin or moderator mutation in the audit log. It runs in
+// the mutation's tx, so the change and its record commit or roll back
+// together.
+func (r *Resolver) audit(
+	tx *pgx.Tx,
+	actorID int64,
+	action server.AuditAction,
+	targetType server.AuditTargetType,
+	targetID int64,
+	before interface{},
+	after interface{},
+) error {
+	return r.server.RecordAuditEvent(tx, &actorID, action, targetType, targetID, before, after)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// AuditAction is what an admin or moderator did
+type AuditAction string
+
+const (
+	AuditActionSetUserRoles       AuditAction = "set_user_roles"
+	AuditActionCreateNeighborhood AuditAction = "create_neighborhood"
+	AuditActionUpdateNeighborhood AuditAction = "update_neighborhood"
+	AuditActionMergeNeighborhoods AuditAction = "merge_neighborhoods"
+	AuditActionSuspendUser        AuditAction = "suspend_user"
+	AuditActionBanUser            AuditAction = "ban_user"
+	AuditActionReinstateUser      AuditAction = "reinstate_user"
+	AuditActionCreateContentRule  AuditAction = "create_content_rule"
+	AuditActionUpdateContentRule  AuditAction = "update_content_rule"
+	AuditActionDeleteContentRule  AuditAction = "delete_content_rule"
+	// AuditActionAutoHidePost is the server hiding a post after reports
+	AuditActionAutoHidePost AuditAction = "auto_hide_post"
+)
+
+// AuditActionForModeration is the audit action of a moderator's action on a
+// post
+func AuditActionForModeration(action ModerationAction) AuditAction {
+	return AuditAction(action)
+}
+
+// AuditTargetType is the kind of thing an audit event's target ID refers to
+type AuditTargetType string
+
+const (
+	AuditTargetUser         AuditTargetType = "user"
+	AuditTargetPost         AuditTargetType = "post"
+	AuditTargetNeighborhood AuditTargetType = "neighborhood"
+	AuditTargetContentRule  AuditTargetType = "content_rule"
+)
+
+// AuditEvent is an admin or moderator action with its target before and
+// after it
+type AuditEvent struct {
+	ID int64
+	// ActorID is nil for actions the server took itself
+	ActorID    *int64
+	Action     AuditAction
+	TargetType AuditTargetType
+	TargetID   int64
+	Before     json.RawMessage
+	After      json.RawMessage
+	CreatedAt  time.Time
+}
+
+// RecordAuditEvent appends an event to the audit log in tx, the same one as
+// the change it records. before and after are stored as JSON and nil is null.
+func (s *Server) RecordAuditEvent(
+	tx *pgx.Tx,
+	actorID *int64,
+	action AuditAction,
+	targetType AuditTargetType,
+	targetID int64,
+	before interface{},
+	after interface{},
+) error {
+	return recordAuditEvent(tx, actorID, action, targetType, targetID, before, after)
+}
+
+func recordAuditEvent(
+	db querier,
+	actorID *int64,
+	action AuditAction,
+	targetType AuditTargetType,
+	targetID int64,
+	before interface{},
+	after interface{},
+) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		insert into audit_events (actor_id, action, target_type, target_id, before, after)
+		values ($1, $2, $3, $4, $5::jsonb, $6::jsonb)
+	`, actorID, string(action), string(targetType), targetID, beforeJSON, afterJSON)
+	return err
+}
+
+func auditJSON(v interface{}) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(b)
+	return &s, nil
+}
+
+// AuditEventFilter narrows the audit log, nil fields match everything
+type AuditEventFilter struct {
+	ActorID    *int64
+	Action     *AuditAction
+	TargetType *AuditTargetType
+	TargetID   *int64
+	Since      *time.Time
+}
+
+// AuditEvents is the audit log, newest first, before the event beforeID
+func (s *Server) AuditEvents(filter AuditEventFilter, beforeID int64, limit int32) ([]AuditEvent, error) {
+	var action, targetType *string
+	if filter.Action != nil {
+		a := string(*filter.Action)
+		action = &a
+	}
+	if filter.TargetType != nil {
+		t := string(*filter.TargetType)
+		targetType = &t
+	}
+
+	rows, err := s.ConnPool.Query(`
+		select id, actor_id, action, target_type, target_id, before::text, after::text, created_at
+		from audit_events
+		where (id < $1 or $1 <= 0)
+			and ($2::bigint is null or actor_id = $2)
+			and ($3::text is null or action = $3)
+			and ($4::text is null or target_type = $4)
+			and ($5::bigint is null or target_id = $5)
+			and ($6::timestamptz is null or created_at >= $6)
+		order by id desc
+		limit $7
+	`, beforeID, filter.ActorID, action, targetType, filter.TargetID, filter.Since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var action, targetType string
+		var before, after *string
+		if err := rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&action,
+			&targetType,
+			&e.TargetID,
+			&before,
+			&after,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		e.Action = AuditAction(action)
+		e.TargetType = AuditTargetType(targetType)
+		if before != nil {
+			e.Before = json.RawMessage(*before)
+		}
+		if after != nil {
+			e.After = json.RawMessage(*after)
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -1,29 +1,61 @@
 package server
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/jackc/pgx"
+	uuid "github.com/satori/go.uuid"
 )
 
+const (
+	// AccessTokenTTL is how long an auth JWT is valid for, clients use their
+	// refresh token to get a new one
+	AccessTokenTTL = 15 * time.Minute
+
+	// RefreshTokenTTL is how long a refresh token is valid for. Every refresh
+	// rotates the token, so a device that opens the app within this window
+	// stays logged in.
+	RefreshTokenTTL = 60 * 24 * time.Hour
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
 type AuthToken struct {
 	Token     string
 	ExpiresAt time.Time
 }
 
+// AuthTokens is what a login or refresh hands to the client
+type AuthTokens struct {
+	Access  *AuthToken
+	Refresh *AuthToken
+}
+
 type AuthJWTClaims struct {
 	UserID int64
+	Roles  []Role `json:"roles,omitempty"`
 	jwt.StandardClaims
 }
 
-func (s *Server) GenerateAuthJWT(userID int64) (*AuthToken, error) {
-	expiresAt := time.Now().Add(time.Hour * 24 * 365).UTC()
+// GenerateAuthJWT signs an access token for a session, the session ID is the
+// token's jti
+func (s *Server) GenerateAuthJWT(userID int64, sessionID string, roles []Role) (*AuthToken, error) {
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL).UTC()
 
 	claims := &AuthJWTClaims{
 		UserID: userID,
+		Roles:  roles,
 		StandardClaims: jwt.StandardClaims{
-			NotBefore: time.Now().Unix(),
-			IssuedAt:  time.Now().Unix(),
+			Id:        sessionID,
+			NotBefore: now.Unix(),
+			IssuedAt:  now.Unix(),
 			ExpiresAt: expiresAt.Unix(),
 		},
 	}
@@ -43,6 +75,194 @@ func (s *Server) GenerateAuthJWT(userID int64) (*AuthToken, error) {
 
 func (s *Server) ValidateAuthJWT(tokenStr string) (*jwt.Token, error) {
 	return jwt.ParseWithClaims(tokenStr, &AuthJWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
 		return []byte(s.ServerSecret), nil
 	})
 }
+
+// IssueAuthTokens starts a new session for a user that just logged in. A
+// deviceID revokes that device's previous sessions.
+func (s *Server) IssueAuthTokens(userID int64, deviceID *string) (*AuthTokens, error) {
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if deviceID != nil {
+		if _, err := tx.Exec(`
+			update sessions set revoked_at = now()
+			where user_id = $1
+				and device_id = $2
+				and revoked_at is null
+		`, userID, *deviceID); err != nil {
+			return nil, err
+		}
+	}
+
+	sessionID := uuid.Must(uuid.NewV4()).String()
+	if _, err := tx.Exec(`
+		insert into sessions (id, user_id, device_id, created_at, last_seen_at)
+		values ($1, $2, $3, now(), now())
+	`, sessionID, userID, deviceID); err != nil {
+		return nil, err
+	}
+
+	refresh, _, err := insertRefreshToken(tx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := userRoles(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	access, err := s.GenerateAuthJWT(userID, sessionID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		Access:  access,
+		Refresh: refresh,
+	}, nil
+}
+
+// RefreshAuthTokens trades a refresh token for a new access token and a new
+// refresh token in the same session. Each refresh token works once.
+// Presenting one that was already used means it leaked, so its session is
+// revoked.
+func (s *Server) RefreshAuthTokens(refreshToken string) (*AuthTokens, error) {
+	hash := hashToken(refreshToken)
+
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current struct {
+		id               int64
+		userID           int64
+		sessionID        string
+		revokedAt        *time.Time
+		expiresAt        time.Time
+		sessionRevokedAt *time.Time
+	}
+	err = tx.QueryRow(`
+		select r.id, r.user_id, r.session_id, r.revoked_at, r.expires_at, s.revoked_at
+		from refresh_tokens r
+		join sessions s on s.id = r.session_id
+		where r.token_hash = $1
+		for update
+	`, hash).Scan(
+		&current.id,
+		&current.userID,
+		&current.sessionID,
+		&current.revokedAt,
+		&current.expiresAt,
+		&current.sessionRevokedAt,
+	)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, err
+	case current.sessionRevokedAt != nil:
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if current.revokedAt != nil {
+		if err := revokeSessions(tx, current.userID, &current.sessionID); err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if !current.expiresAt.After(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	refresh, nextID, err := insertRefreshToken(tx, current.userID, current.sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		update refresh_tokens
+		set revoked_at = now(), last_used_at = now(), replaced_by_id = $2
+		where id = $1
+	`, current.id, nextID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		update sessions set last_seen_at = now()
+		where id = $1
+	`, current.sessionID); err != nil {
+		return nil, err
+	}
+
+	// roles changed since the last token take effect here
+	roles, err := userRoles(tx, current.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	access, err := s.GenerateAuthJWT(current.userID, current.sessionID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		Access:  access,
+		Refresh: refresh,
+	}, nil
+}
+
+func insertRefreshToken(tx *pgx.Tx, userID int64, sessionID string) (*AuthToken, int64, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, 0, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(RefreshTokenTTL).UTC()
+
+	var id int64
+	err := tx.QueryRow(`
+		insert into refresh_tokens (user_id, session_id, token_hash, expires_at, created_at)
+		values ($1, $2, $3, $4, now())
+		returning id
+	`, userID, sessionID, hashToken(token), expiresAt).Scan(&id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &AuthToken{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, id, nil
+}
+
+// hashToken is how bearer tokens we hand out are stored
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx"
+)
+
+// DefaultAutoHideThreshold is the report weight that hides a post when
+// AUTO_HIDE_THRESHOLD isn't set, about three established neighbors
+const DefaultAutoHideThreshold = 3.0
+
+// reportWeightSQL selects how much each reporter of the post postID's open
+// reports counts toward Server.AutoHideThreshold, once each however often
+// they reported it. Moderators hide a post on their own. New accounts count
+// half, and reporters whose reports are mostly dismissed count a quarter,
+// while ones whose reports are mostly acted on count one and a half.
+func reportWeightSQL(postID, threshold string) string {
+	return fmt.Sprintf(`
+		select
+			case
+				when u.roles && '{admin,moderator}' then %[2]s::float8
+				else
+					case
+						when u.created_at > now() - interval '7 days' then 0.5
+						else 1.0
+					end
+					* case
+						when h.dismissed >= 3 and h.dismissed > h.actioned then 0.25
+						when h.actioned >= 3 and h.actioned > h.dismissed then 1.5
+						else 1.0
+					end
+			end as weight
+		from (
+			select distinct person_reporting_id
+			from reported_posts
+			where post_id = %[1]s
+				and action_taken is null
+				and deleted_at is null
+		) reporters
+		join users u on u.id = reporters.person_reporting_id
+		cross join lateral (
+			select
+				count(*) filter (where action_taken = 'dismiss') as dismissed,
+				count(*) filter (where action_taken <> 'dismiss') as actioned
+			from reported_posts
+			where person_reporting_id = reporters.person_reporting_id
+				and action_taken is not null
+				and deleted_at is null
+		) h
+	`, postID, threshold)
+}
+
+// ReportWeight is the weight of a post's open reports
+func (s *Server) ReportWeight(postID int64) (float64, error) {
+	var weight float64
+	err := s.ConnPool.QueryRow(`
+		select coalesce(sum(weight), 0)::float8 from (`+reportWeightSQL("$1", "$2")+`) w
+	`, postID, s.AutoHideThreshold).Scan(&weight)
+	return weight, err
+}
+
+// autoHideReportedPost hides a post once its open reports weigh
+// AutoHideThreshold, until a moderator acts on it, and lets the author know.
+// A zero threshold never hides.
+func (s *Server) autoHideReportedPost(postID int64) error {
+	if s.AutoHideThreshold <= 0 {
+		return nil
+	}
+
+	weight, err := s.ReportWeight(postID)
+	if err != nil {
+		return err
+	}
+
+	if weight < s.AutoHideThreshold {
+		return nil
+	}
+
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var authorID int64
+	var title string
+	err = tx.QueryRow(`
+		update posts
+		set hidden = true, auto_hidden_at = now(), updated_at = now()
+		where id = $1
+			and hidden is false
+		returning user_id, title
+	`, postID).Scan(&authorID, &title)
+	switch {
+	case err == pgx.ErrNoRows:
+		// already hidden
+		return nil
+	case err != nil:
+		return err
+	}
+
+	after, err := postModeration(tx, postID, forUpdate)
+	if err != nil {
+		return err
+	}
+	before := *after
+	before.Hidden = false
+	before.AutoHiddenAt = nil
+	if err := recordAuditEvent(tx, nil, AuditActionAutoHidePost, AuditTargetPost, postID, before, after); err != nil {
+		return err
+	}
+
+	err = enqueuePush(tx, authorID, PushMessage{
+		Body: fmt.Sprintf("Your post \"%s\" was hidden after several reports, a moderator will review it", title),
+		Link: PostDeepLink(postID),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx"
+)
+
+// BlockKind is how much of another user someone doesn't want to see
+type BlockKind string
+
+const (
+	// BlockKindBlock hides the user's posts, comments and conversations, and
+	// stops them messaging the blocker
+	BlockKindBlock BlockKind = "block"
+	// BlockKindMute only hides the user's posts and comments
+	BlockKindMute BlockKind = "mute"
+)
+
+// ErrBlocked is returned when either user has blocked the other
+var ErrBlocked = errors.New("you can't message this user")
+
+// UnblockedSQL is a squirrel condition that's true when the viewer, its one
+// argument, hasn't blocked the user in column, or muted them too when mutes
+// is set
+func UnblockedSQL(column string, mutes bool) string {
+	kinds := `'block'`
+	if mutes {
+		kinds = `'block', 'mute'`
+	}
+
+	return `not exists (
+		select 1 from user_blocks b
+		where b.user_id = ?
+			and b.blocked_user_id = ` + column + `
+			and b.kind in (` + kinds + `)
+	)`
+}
+
+// BlockUser blocks or mutes otherUserID for userID, replacing whichever
+// they had before
+func (s *Server) BlockUser(userID, otherUserID int64, kind BlockKind) error {
+	if userID == otherUserID {
+		return errors.New("you can't block yourself")
+	}
+
+	tag, err := s.ConnPool.Exec(`
+		insert into user_blocks (user_id, blocked_user_id, kind)
+		select $1, id, $3
+		from users
+		where id = $2
+		on conflict (user_id, blocked_user_id) do update
+		set kind = excluded.kind, created_at = now()
+	`, userID, otherUserID, string(kind))
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// UnblockUser lifts a block or mute of the given kind, it's not an error if
+// there wasn't one
+func (s *Server) UnblockUser(userID, otherUserID int64, kind BlockKind) error {
+	_, err := s.ConnPool.Exec(`
+		delete from user_blocks
+		where user_id = $1
+			and blocked_user_id = $2
+			and kind = $3
+	`, userID, otherUserID, string(kind))
+	return err
+}
+
+// BlockedUserIDs is who userID has blocked or muted, most recent first
+func (s *Server) BlockedUserIDs(userID int64, kind BlockKind) ([]int64, error) {
+	rows, err := s.ConnPool.Query(`
+		select blocked_user_id
+		from user_blocks
+		where user_id = $1
+			and kind = $2
+		order by created_at desc
+	`, userID, string(kind))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// CheckNotBlocked returns ErrBlocked when either user has blocked the other,
+// mutes don't count
+func (s *Server) CheckNotBlocked(userID, otherUserID int64) error {
+	var blocked bool
+	err := s.ConnPool.QueryRow(`
+		select true
+		from user_blocks
+		where kind = 'block'
+			and ((user_id = $1 and blocked_user_id = $2)
+				or (user_id = $2 and blocked_user_id = $1))
+		limit 1
+	`, userID, otherUserID).Scan(&blocked)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	return ErrBlocked
+}
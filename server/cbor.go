@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Just enough CBOR (RFC 7049) to read WebAuthn attestation objects and COSE
+// keys: definite lengths only, no tags or floats. Integers decode to int64,
+// byte strings to []byte, text to string, arrays to []interface{} and maps
+// to map[interface{}]interface{}.
+
+var errCBOR = errors.New("malformed cbor")
+
+// cborMaxDepth keeps hostile input from recursing forever
+const cborMaxDepth = 16
+
+// decodeCBOR reads one item from b and returns it with the bytes after it
+func decodeCBOR(b []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(b, 0)
+}
+
+func decodeCBORItem(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth || len(b) == 0 {
+		return nil, nil, errCBOR
+	}
+
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+	b = b[1:]
+
+	// simple values, only false/true/null show up in WebAuthn
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		default:
+			return nil, nil, errCBOR
+		}
+	}
+
+	arg, b, err := cborArgument(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(arg), b, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(arg), b, nil
+	case 2, 3:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		data := b[:arg]
+		if major == 3 {
+			return string(data), b[arg:], nil
+		}
+		return append([]byte(nil), data...), b[arg:], nil
+	case 4:
+		// every item is at least a byte, so a longer claim is a lie
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			item, b, err = decodeCBORItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, b, nil
+	case 5:
+		if arg > uint64(len(b))/2 {
+			return nil, nil, errCBOR
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			key, b, err = decodeCBORItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			value, b, err = decodeCBORItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, b, nil
+	default:
+		return nil, nil, errCBOR
+	}
+}
+
+// cborArgument reads the length or value that follows an initial byte
+func cborArgument(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	default:
+		return 0, nil, errCBOR
+	}
+}
@@ -0,0 +1,448 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ContentKind is what a piece of user written text is
+type ContentKind string
+
+const (
+	ContentKindPost    ContentKind = "post"
+	ContentKindComment ContentKind = "comment"
+	ContentKindMessage ContentKind = "message"
+)
+
+// FilterVerdict is what a ContentFilter wants done with text, from least to
+// most severe
+type FilterVerdict string
+
+const (
+	FilterAllow FilterVerdict = "allow"
+	// FilterFlag stores the content and records it for moderators
+	FilterFlag FilterVerdict = "flag"
+	// FilterShadowHide stores the content but only shows it to its author
+	FilterShadowHide FilterVerdict = "shadow_hide"
+	// FilterReject refuses the content
+	FilterReject FilterVerdict = "reject"
+)
+
+func (v FilterVerdict) severity() int {
+	switch v {
+	case FilterFlag:
+		return 1
+	case FilterShadowHide:
+		return 2
+	case FilterReject:
+		return 3
+	}
+
+	return 0
+}
+
+func (v FilterVerdict) valid() bool {
+	return v == FilterFlag || v == FilterShadowHide || v == FilterReject
+}
+
+// FilterResult is a ContentFilter's verdict on some text and why
+type FilterResult struct {
+	Verdict FilterVerdict
+	Reason  string
+}
+
+// Hidden reports whether the content should only be shown to its author
+func (r FilterResult) Hidden() bool {
+	return r.Verdict == FilterShadowHide
+}
+
+// ContentRejectedError is returned for text a ContentFilter rejected
+type ContentRejectedError struct {
+	Reason string
+}
+
+func (e *ContentRejectedError) Error() string {
+	if e.Reason == "" {
+		return "this goes against our community guidelines"
+	}
+
+	return fmt.Sprintf("this goes against our community guidelines: %s", e.Reason)
+}
+
+// ContentFilter checks user written text before it's stored
+type ContentFilter interface {
+	FilterText(kind ContentKind, text string) (FilterResult, error)
+}
+
+// FilterContent runs text through the server's ContentFilters, the most
+// severe verdict wins. Rejected text is a *ContentRejectedError.
+func (s *Server) FilterContent(kind ContentKind, text string) (FilterResult, error) {
+	result := FilterResult{Verdict: FilterAllow}
+	for _, filter := range s.ContentFilters {
+		r, err := filter.FilterText(kind, text)
+		if err != nil {
+			return result, err
+		}
+
+		if r.Verdict.severity() > result.Verdict.severity() {
+			result = r
+		}
+
+		if result.Verdict == FilterReject {
+			return result, &ContentRejectedError{Reason: result.Reason}
+		}
+	}
+
+	return result, nil
+}
+
+// RecordContentFlag keeps flagged and shadow hidden content for moderators,
+// allowed content isn't recorded
+func (s *Server) RecordContentFlag(kind ContentKind, contentID, userID int64, text string, result FilterResult) error {
+	if result.Verdict == FilterAllow {
+		return nil
+	}
+
+	_, err := s.ConnPool.Exec(`
+		insert into content_flags (kind, content_id, user_id, text, verdict, reason)
+		values ($1, $2, $3, $4, $5, $6)
+	`, string(kind), contentID, userID, text, string(result.Verdict), result.Reason)
+	return err
+}
+
+// WordlistFilter matches whole words, after undoing common letter
+// substitutions like 4 for a
+type WordlistFilter struct {
+	Words map[string]FilterResult
+}
+
+// DefaultProfanity is flagged and DefaultSlurs rejected by the built-in
+// wordlist
+var (
+	DefaultProfanity = []string{
+		"asshole", "bastard", "bitch", "bullshit", "cunt", "dick", "fuck",
+		"fucker", "fucking", "motherfucker", "piss", "prick", "shit",
+		"shitty", "twat", "wanker",
+	}
+	DefaultSlurs = []string{
+		"chink", "coon", "dyke", "fag", "faggot", "gook", "kike", "nigga",
+		"nigger", "paki", "retard", "spic", "tranny", "wetback",
+	}
+)
+
+// NewDefaultWordlistFilter is the built-in profanity and slur wordlist
+func NewDefaultWordlistFilter() *WordlistFilter {
+	f := &WordlistFilter{Words: map[string]FilterResult{}}
+	for _, word := range DefaultProfanity {
+		f.Words[word] = FilterResult{Verdict: FilterFlag, Reason: "profanity"}
+	}
+	for _, word := range DefaultSlurs {
+		f.Words[word] = FilterResult{Verdict: FilterReject, Reason: "slur"}
+	}
+
+	return f
+}
+
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"@", "a",
+	"$", "s",
+	"!", "i",
+)
+
+func isLeet(r rune) bool {
+	return strings.ContainsRune("013457@$!", r)
+}
+
+func isNotLetter(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+// FilterText checks each word as written and with substitutions undone, so
+// punctuation after a word like "!" isn't read as a letter
+func (f *WordlistFilter) FilterText(kind ContentKind, text string) (FilterResult, error) {
+	result := FilterResult{Verdict: FilterAllow}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !isLeet(r)
+	})
+	for _, word := range words {
+		for _, w := range []string{
+			strings.TrimFunc(word, isNotLetter),
+			strings.TrimFunc(leetReplacer.Replace(word), isNotLetter),
+		} {
+			r, ok := f.Words[w]
+			if ok && r.Verdict.severity() > result.Verdict.severity() {
+				result = r
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ContentRule is a regular expression with the verdict for text it matches,
+// from a rule file or added by admins
+type ContentRule struct {
+	ID      int64         `json:"-"`
+	Pattern string        `json:"pattern"`
+	Verdict FilterVerdict `json:"verdict"`
+	// Kinds limits the rule to some kinds of content, empty is all of them
+	Kinds  []ContentKind `json:"kinds"`
+	Reason string        `json:"reason"`
+
+	Enabled   bool      `json:"-"`
+	CreatedAt time.Time `json:"-"`
+}
+
+func (r *ContentRule) appliesTo(kind ContentKind) bool {
+	if len(r.Kinds) == 0 {
+		return true
+	}
+
+	for _, k := range r.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileContentRule checks a rule, its pattern matches case insensitively
+func compileContentRule(rule ContentRule) (*regexp.Regexp, error) {
+	if !rule.Verdict.valid() {
+		return nil, fmt.Errorf("unknown verdict %q", rule.Verdict)
+	}
+
+	for _, kind := range rule.Kinds {
+		if kind != ContentKindPost && kind != ContentKindComment && kind != ContentKindMessage {
+			return nil, fmt.Errorf("unknown content kind %q", kind)
+		}
+	}
+
+	re, err := regexp.Compile("(?i)" + rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %s", err)
+	}
+
+	return re, nil
+}
+
+// RuleFilter matches text against ContentRules
+type RuleFilter struct {
+	rules    []ContentRule
+	patterns []*regexp.Regexp
+}
+
+// NewRuleFilter compiles rules, failing on the first invalid one
+func NewRuleFilter(rules []ContentRule) (*RuleFilter, error) {
+	f := &RuleFilter{}
+	for _, rule := range rules {
+		re, err := compileContentRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %s", rule.Pattern, err)
+		}
+
+		f.rules = append(f.rules, rule)
+		f.patterns = append(f.patterns, re)
+	}
+
+	return f, nil
+}
+
+func (f *RuleFilter) FilterText(kind ContentKind, text string) (FilterResult, error) {
+	result := FilterResult{Verdict: FilterAllow}
+	for i, rule := range f.rules {
+		if !rule.appliesTo(kind) || !f.patterns[i].MatchString(text) {
+			continue
+		}
+
+		if rule.Verdict.severity() > result.Verdict.severity() {
+			result = FilterResult{Verdict: rule.Verdict, Reason: rule.Reason}
+		}
+	}
+
+	return result, nil
+}
+
+// ContentRuleFile is a local rule file, JSON like
+//
+//	{
+//		"words": {"heck": "flag"},
+//		"rules": [{"pattern": "buy now", "verdict": "shadow_hide", "kinds": ["post"], "reason": "spam"}]
+//	}
+type ContentRuleFile struct {
+	// Words are added to the built-in wordlist, overriding it
+	Words map[string]FilterVerdict `json:"words"`
+	Rules []ContentRule            `json:"rules"`
+}
+
+// LoadContentRuleFile reads the rule file at path into filters that run the
+// built-in wordlist with the file's words, then the file's rules
+func LoadContentRuleFile(path string) ([]ContentFilter, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ContentRuleFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	wordlist := NewDefaultWordlistFilter()
+	for word, verdict := range file.Words {
+		if verdict != FilterAllow && !verdict.valid() {
+			return nil, fmt.Errorf("%s: word %q: unknown verdict %q", path, word, verdict)
+		}
+		wordlist.Words[strings.ToLower(word)] = FilterResult{Verdict: verdict, Reason: "wordlist"}
+	}
+
+	rules, err := NewRuleFilter(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return []ContentFilter{wordlist, rules}, nil
+}
+
+// contentRulesMaxAge is how long DBRuleFilter's compiled rules are used
+// before they're loaded again, so changes made through another API instance
+// apply here too
+const contentRulesMaxAge = time.Minute
+
+// DBRuleFilter runs the enabled rules admins manage with CreateContentRule.
+// They're compiled once and cached on Server until InvalidateContentRules is
+// called or contentRulesMaxAge passes.
+type DBRuleFilter struct {
+	Server *Server
+}
+
+func (f *DBRuleFilter) FilterText(kind ContentKind, text string) (FilterResult, error) {
+	ruleFilter, err := f.Server.contentRuleFilter()
+	if err != nil {
+		return FilterResult{}, err
+	}
+
+	return ruleFilter.FilterText(kind, text)
+}
+
+// contentRuleFilter is the enabled rules compiled, loading them if the cache
+// is empty or stale. Concurrent loads wait for the first.
+func (s *Server) contentRuleFilter() (*RuleFilter, error) {
+	s.contentRulesMu.Lock()
+	defer s.contentRulesMu.Unlock()
+
+	if s.contentRules != nil && time.Since(s.contentRulesLoadedAt) < contentRulesMaxAge {
+		return s.contentRules, nil
+	}
+
+	rules, err := s.ContentRules(true)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleFilter, err := NewRuleFilter(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	s.contentRules = ruleFilter
+	s.contentRulesLoadedAt = time.Now()
+	return ruleFilter, nil
+}
+
+// InvalidateContentRules makes DBRuleFilter load the rules again, call it
+// once a change to them has committed
+func (s *Server) InvalidateContentRules() {
+	s.contentRulesMu.Lock()
+	defer s.contentRulesMu.Unlock()
+
+	s.contentRules = nil
+}
+
+// ClassifierFilter asks an external classifier about text. It POSTs
+// {"kind": ..., "text": ...} to URL and expects {"verdict": ..., "reason": ...}
+// back. Text is allowed when the classifier can't be reached, so an outage
+// doesn't stop people posting.
+type ClassifierFilter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (f *ClassifierFilter) FilterText(kind ContentKind, text string) (FilterResult, error) {
+	allow := FilterResult{Verdict: FilterAllow}
+
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 3 * time.Second}
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"kind": string(kind),
+		"text": text,
+	})
+	if err != nil {
+		return allow, err
+	}
+
+	resp, err := client.Post(f.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Println("content classifier:", err)
+		return allow, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Println("content classifier: status", resp.StatusCode)
+		return allow, nil
+	}
+
+	var result FilterResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Println("content classifier:", err)
+		return allow, nil
+	}
+
+	if !result.Verdict.valid() {
+		return allow, nil
+	}
+
+	return result, nil
+}
+
+// newContentFilters is the built-in wordlist, or CONTENT_RULES_PATH's rule
+// file, then admins' rules, then CONTENT_CLASSIFIER_URL when it's set
+func newContentFilters(s *Server) ([]ContentFilter, error) {
+	filters := []ContentFilter{NewDefaultWordlistFilter()}
+	if path := os.Getenv("CONTENT_RULES_PATH"); path != "" {
+		fileFilters, err := LoadContentRuleFile(path)
+		if err != nil {
+			return nil, err
+		}
+		filters = fileFilters
+	}
+
+	filters = append(filters, &DBRuleFilter{Server: s})
+
+	if url := os.Getenv("CONTENT_CLASSIFIER_URL"); url != "" {
+		filters = append(filters, &ClassifierFilter{URL: url})
+	}
+
+	return filters, nil
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWordlistFilter(t *testing.T) {
+	f := NewDefaultWordlistFilter()
+
+	cases := []struct {
+		text    string
+		verdict FilterVerdict
+	}{
+		{"hello neighbors", FilterAllow},
+		{"you retard!", FilterReject},
+		{"you retard$", FilterReject},
+		{"you retard1", FilterReject},
+		{"this is shit!", FilterFlag},
+		{"this is shit$", FilterFlag},
+		{"this is shit1", FilterFlag},
+		{"you n1gg3r", FilterReject},
+		{"$h!t happens", FilterFlag},
+		{"fuck/shit", FilterFlag},
+		{"thanks 4 the help!", FilterAllow},
+	}
+
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			result, err := f.FilterText(ContentKindPost, c.text)
+			require.NoError(t, err)
+			assert.Equal(t, c.verdict, result.Verdict)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// ContentFlag is content a ContentFilter flagged or shadow hid
+type ContentFlag struct {
+	ID        int64
+	Kind      ContentKind
+	ContentID int64
+	UserID    int64
+	Text      string
+	Verdict   FilterVerdict
+	Reason    *string
+	CreatedAt time.Time
+}
+
+func scanContentRule(row scannable) (*ContentRule, error) {
+	var rule ContentRule
+	var verdict string
+	var kinds []string
+	var reason *string
+	if err := row.Scan(&rule.ID, &rule.Pattern, &verdict, &kinds, &reason, &rule.Enabled, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	rule.Verdict = FilterVerdict(verdict)
+	for _, kind := range kinds {
+		rule.Kinds = append(rule.Kinds, ContentKind(kind))
+	}
+	if reason != nil {
+		rule.Reason = *reason
+	}
+
+	return &rule, nil
+}
+
+const contentRuleColumns = `id, pattern, verdict, kinds, reason, enabled, created_at`
+
+// ContentRules is the rules admins added, oldest first
+func (s *Server) ContentRules(enabledOnly bool) ([]ContentRule, error) {
+	rows, err := s.ConnPool.Query(`
+		select `+contentRuleColumns+`
+		from content_filter_rules
+		where enabled is true or not $1
+		order by id
+	`, enabledOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []ContentRule
+	for rows.Next() {
+		rule, err := scanContentRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// ContentRuleByID ...
+func (s *Server) ContentRuleByID(id int64) (*ContentRule, error) {
+	return contentRuleByID(s.ConnPool, id, "")
+}
+
+// ContentRuleForUpdate is ContentRuleByID read in tx, locking the rule until
+// it ends
+func (s *Server) ContentRuleForUpdate(tx *pgx.Tx, id int64) (*ContentRule, error) {
+	return contentRuleByID(tx, id, forUpdate)
+}
+
+func contentRuleByID(db querier, id int64, lock string) (*ContentRule, error) {
+	rule, err := scanContentRule(db.QueryRow(`
+		select `+contentRuleColumns+`
+		from content_filter_rules
+		where id = $1
+		`+lock+`
+	`, id))
+	if err == pgx.ErrNoRows {
+		return nil, errors.New("content rule not found")
+	}
+
+	return rule, err
+}
+
+// CreateContentRule adds a rule after checking it compiles
+func (s *Server) CreateContentRule(tx *pgx.Tx, createdByID int64, rule ContentRule) (*ContentRule, error) {
+	if _, err := compileContentRule(rule); err != nil {
+		return nil, err
+	}
+
+	kinds := []string{}
+	for _, kind := range rule.Kinds {
+		kinds = append(kinds, string(kind))
+	}
+
+	var reason *string
+	if rule.Reason != "" {
+		reason = &rule.Reason
+	}
+
+	return scanContentRule(tx.QueryRow(`
+		insert into content_filter_rules (pattern, verdict, kinds, reason, created_by_id)
+		values ($1, $2, $3, $4, $5)
+		returning `+contentRuleColumns+`
+	`, rule.Pattern, string(rule.Verdict), kinds, reason, createdByID))
+}
+
+// SetContentRuleEnabled turns a rule on or off
+func (s *Server) SetContentRuleEnabled(tx *pgx.Tx, id int64, enabled bool) (*ContentRule, error) {
+	rule, err := scanContentRule(tx.QueryRow(`
+		update content_filter_rules
+		set enabled = $2, updated_at = now()
+		where id = $1
+		returning `+contentRuleColumns+`
+	`, id, enabled))
+	if err == pgx.ErrNoRows {
+		return nil, errors.New("content rule not found")
+	}
+
+	return rule, err
+}
+
+// DeleteContentRule removes a rule, it's not an error if it's already gone
+func (s *Server) DeleteContentRule(tx *pgx.Tx, id int64) error {
+	_, err := tx.Exec(`delete from content_filter_rules where id = $1`, id)
+	return err
+}
+
+// ContentFlags is flagged and shadow hidden content, newest first, before the
+// flag beforeID
+func (s *Server) ContentFlags(beforeID int64, limit int32) ([]ContentFlag, error) {
+	rows, err := s.ConnPool.Query(`
+		select id, kind, content_id, user_id, text, verdict, reason, created_at
+		from content_flags
+		where id < $1 or $1 <= 0
+		order by id desc
+		limit $2
+	`, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []ContentFlag
+	for rows.Next() {
+		var f ContentFlag
+		var kind, verdict string
+		if err := rows.Scan(&f.ID, &kind, &f.ContentID, &f.UserID, &f.Text, &verdict, &f.Reason, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		f.Kind = ContentKind(kind)
+		f.Verdict = FilterVerdict(verdict)
+		flags = append(flags, f)
+	}
+
+	return flags, rows.Err()
+}
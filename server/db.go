@@ -0,0 +1,24 @@
+package server
+
+import "github.com/jackc/pgx"
+
+type scannable interface {
+	Scan(...interface{}) error
+}
+
+// querier runs SQL on the pool or in a transaction
+type querier interface {
+	Exec(sql string, args ...interface{}) (pgx.CommandTag, error)
+	Query(sql string, args ...interface{}) (*pgx.Rows, error)
+	QueryRow(sql string, args ...interface{}) *pgx.Row
+}
+
+// forUpdate locks the rows a select in a transaction reads until it ends,
+// so what was read can't change before it commits
+const forUpdate = "for update"
+
+// isUniqueViolation reports whether err is postgres refusing a duplicate key
+func isUniqueViolation(err error) bool {
+	pgErr, ok := err.(pgx.PgError)
+	return ok && pgErr.Code == "23505"
+}
@@ -0,0 +1,35 @@
+package server
+
+import "fmt"
+
+// DeepLinkScheme is the URL scheme the apps open. Push payloads and the
+// notifications inbox carry the same links, so tapping either opens the same
+// screen.
+//
+//	cobbles://posts/{postID}
+//	cobbles://posts/{postID}/comments/{commentID}
+//	cobbles://conversations/{conversationID}
+//	cobbles://users/{userID}
+//	cobbles://notifications
+const DeepLinkScheme = "cobbles"
+
+func PostDeepLink(postID int64) string {
+	return fmt.Sprintf("%s://posts/%d", DeepLinkScheme, postID)
+}
+
+func CommentDeepLink(postID, commentID int64) string {
+	return fmt.Sprintf("%s://posts/%d/comments/%d", DeepLinkScheme, postID, commentID)
+}
+
+func ConversationDeepLink(conversationID int64) string {
+	return fmt.Sprintf("%s://conversations/%d", DeepLinkScheme, conversationID)
+}
+
+func UserDeepLink(userID int64) string {
+	return fmt.Sprintf("%s://users/%d", DeepLinkScheme, userID)
+}
+
+// NotificationsDeepLink opens the inbox
+func NotificationsDeepLink() string {
+	return fmt.Sprintf("%s://notifications", DeepLinkScheme)
+}
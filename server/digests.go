@@ -0,0 +1,194 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// DigestHour is the hour of the day, in their time zone, users get their
+	// neighborhood digest
+	DigestHour = 8
+	// DigestCheckInterval is how often the worker looks for digests due
+	DigestCheckInterval = 10 * time.Minute
+
+	digestPostLimit = 5
+)
+
+// ProcessDigests sends neighborhood digests as they come due, for as long as
+// the process runs. Errors are logged and it tries again after backing off.
+func (s *Server) ProcessDigests() {
+	var failures int
+	for {
+		if _, err := s.SendNeighborhoodDigests(time.Now()); err != nil {
+			failures++
+			log.Println("digests:", err)
+			time.Sleep(workerBackoff(time.Minute, failures))
+			continue
+		}
+		failures = 0
+
+		time.Sleep(DigestCheckInterval)
+	}
+}
+
+// SendNeighborhoodDigests sends the day's digest to users with
+// neighborhood posts on whose clock reads DigestHour at, and returns how
+// many got one. Each user gets at most one a day.
+func (s *Server) SendNeighborhoodDigests(at time.Time) (int, error) {
+	rows, err := s.ConnPool.Query(`
+		select ns.user_id, ns.time_zone
+		from notification_settings ns
+		where ns.neighborhood_posts
+			and (ns.last_digest_at is null or ns.last_digest_at < $1::timestamptz - interval '20 hours')
+			and `+ActiveUserSQL("ns.user_id")+`
+	`, at)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		var timeZone string
+		if err := rows.Scan(&userID, &timeZone); err != nil {
+			return 0, err
+		}
+
+		loc, err := time.LoadLocation(timeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		if at.In(loc).Hour() == DigestHour {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	var sent int
+	for _, userID := range userIDs {
+		ok, err := s.sendNeighborhoodDigest(userID, at)
+		if err != nil {
+			// one user's digest shouldn't hold up everyone else's
+			log.Printf("digest: user %d: %s", userID, err)
+			continue
+		}
+
+		if ok {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// sendNeighborhoodDigest rounds up the day's top posts in a user's home
+// neighborhood, reporting whether there were any
+func (s *Server) sendNeighborhoodDigest(userID int64, at time.Time) (bool, error) {
+	neighborhood, err := s.HomeNeighborhood(userID)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// claimed even when there's nothing to send, so the day is done. Another
+	// worker may have claimed it since it was listed, then it's theirs.
+	tag, err := tx.Exec(`
+		update notification_settings
+		set last_digest_at = $2, updated_at = now()
+		where user_id = $1
+			and (last_digest_at is null or last_digest_at < $2::timestamptz - interval '20 hours')
+	`, userID, at)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	rows, err := tx.Query(`
+		select p.id, p.title
+		from posts p
+		where p.neighborhood_id = $1
+			and p.created_at > $2::timestamptz - interval '1 day'
+			and p.created_at <= $2
+			and p.hidden is false
+			and p.removed is false
+			and p.processing is false
+			and p.user_id <> $3
+			and not exists (
+				select 1 from user_blocks b
+				where b.user_id = $3
+					and b.blocked_user_id = p.user_id
+					and b.kind in ('block', 'mute')
+			)
+			and `+ActiveUserSQL("p.user_id")+`
+		order by coalesce(p.likes, 0) + 2 * coalesce(p.comment_count, 0) desc, p.id desc
+		limit $4
+	`, neighborhood.ID, at, userID, digestPostLimit)
+	if err != nil {
+		return false, err
+	}
+
+	var postIDs []int64
+	var titles []string
+	for rows.Next() {
+		var id int64
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return false, err
+		}
+
+		postIDs = append(postIDs, id)
+		titles = append(titles, title)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if len(postIDs) == 0 {
+		return false, tx.Commit()
+	}
+
+	_, err = createNotification(tx, Notification{
+		UserID:  userID,
+		Kind:    NotificationDigest,
+		Content: digestContent(titles),
+		PostID:  &postIDs[0],
+	}, PushMessage{
+		Title: fmt.Sprintf("Today in %s", neighborhood.Name),
+		Body:  digestContent(titles),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// digestContent leads with the top post's title
+func digestContent(titles []string) string {
+	switch len(titles) {
+	case 1:
+		return fmt.Sprintf("\"%s\" is popular in your neighborhood", titles[0])
+	case 2:
+		return fmt.Sprintf("\"%s\" and 1 more post are popular in your neighborhood", titles[0])
+	default:
+		return fmt.Sprintf("\"%s\" and %d more posts are popular in your neighborhood", titles[0], len(titles)-1)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmailSender delivers emails, eg login links
+type EmailSender interface {
+	SendEmail(to string, subject string, body string) error
+}
+
+// SMTPEmailSender sends plain text emails through an SMTP server
+type SMTPEmailSender struct {
+	// Addr is host:port
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPEmailSender) SendEmail(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + s.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(s.Addr, auth, s.From, []string{to}, []byte(msg))
+}
+
+// Email is an email the FakeEmailSender pretended to send
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+	SentAt  time.Time
+}
+
+// FakeEmailSender keeps emails in memory instead of sending them
+type FakeEmailSender struct {
+	mutex  sync.Mutex
+	emails []Email
+}
+
+func (s *FakeEmailSender) SendEmail(to string, subject string, body string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.emails = append(s.emails, Email{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+		SentAt:  time.Now(),
+	})
+
+	return nil
+}
+
+// LastEmail is the most recent email sent to an address
+func (s *FakeEmailSender) LastEmail(to string) (Email, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := len(s.emails) - 1; i >= 0; i-- {
+		if s.emails[i].To == to {
+			return s.emails[i], true
+		}
+	}
+
+	return Email{}, false
+}
+
+// newEmailSender picks the email provider from EMAIL_PROVIDER. Email login
+// is turned off when it's unset.
+func newEmailSender() (EmailSender, error) {
+	switch provider := os.Getenv("EMAIL_PROVIDER"); provider {
+	case "":
+		return nil, nil
+	case "smtp":
+		sender := &SMTPEmailSender{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("EMAIL_FROM"),
+		}
+		if sender.Addr == "" || sender.From == "" {
+			return nil, fmt.Errorf("set SMTP_ADDR and EMAIL_FROM")
+		}
+		return sender, nil
+	case "fake":
+		return &FakeEmailSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_PROVIDER %q", provider)
+	}
+}
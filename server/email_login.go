@@ -0,0 +1,182 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// EmailLoginTTL is how long an emailed login link works
+const EmailLoginTTL = 15 * time.Minute
+
+var (
+	ErrEmailLoginDisabled = errors.New("email login is not available")
+	ErrInvalidEmailLogin  = errors.New("invalid or expired login link")
+	ErrEmailTaken         = errors.New("email is already used by another account")
+	ErrInvalidEmailLink   = errors.New("invalid or expired email confirmation link")
+)
+
+// NormalizeEmail validates an email address and lowercases it, the form
+// stored in users.email
+func NormalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", errors.New("invalid email")
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
+
+// RequestEmailLogin emails a single use login link. When userID is set the
+// link instead confirms the email for that user, see ConfirmEmail, and can't
+// be used to log in.
+func (s *Server) RequestEmailLogin(email string, userID *int64) error {
+	if s.Email == nil {
+		return ErrEmailLoginDisabled
+	}
+
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	var recent bool
+	err = s.ConnPool.QueryRow(`
+		select exists (
+			select 1 from email_login_tokens
+			where email = $1
+				and created_at >= now() - interval '15' second
+		)
+	`, email).Scan(&recent)
+	if err != nil {
+		return err
+	}
+
+	if recent {
+		return errors.New("you're doing that too quick! please try again later")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if _, err := s.ConnPool.Exec(`
+		insert into email_login_tokens (email, user_id, token_hash, expires_at, created_at)
+		values ($1, $2, $3, now() + $4::interval, now())
+	`, email, userID, hashToken(token), fmt.Sprintf("%d seconds", int(EmailLoginTTL.Seconds()))); err != nil {
+		return err
+	}
+
+	link := s.EmailLoginURL + "?token=" + url.QueryEscape(token)
+	if userID != nil {
+		link += "&confirm=email"
+		body := fmt.Sprintf("Tap to add this email to your Cobbles account:\n\n%s\n\nOpen it in the app you're logged in to. The link works once and expires in 15 minutes. If you didn't ask for it you can ignore this email.", link)
+		return s.Email.SendEmail(email, "Confirm your email for Cobbles", body)
+	}
+
+	body := fmt.Sprintf("Tap to log in to Cobbles:\n\n%s\n\nThe link works once and expires in 15 minutes. If you didn't ask for it you can ignore this email.", link)
+	return s.Email.SendEmail(email, "Your Cobbles login link", body)
+}
+
+// LoginWithEmailToken uses up a login link's token and returns its user,
+// creating them on their first login. Links confirming an email for a user
+// never log in, or anyone they're sent to could be logged in as the user
+// who asked for them.
+func (s *Server) LoginWithEmailToken(token string, fcmToken *string) (int64, error) {
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`
+		update email_login_tokens
+		set used_at = now()
+		where token_hash = $1
+			and used_at is null
+			and expires_at >= now()
+			and user_id is null
+		returning email
+	`, hashToken(token)).Scan(&email)
+	switch {
+	case err == pgx.ErrNoRows:
+		return 0, ErrInvalidEmailLogin
+	case err != nil:
+		return 0, err
+	}
+
+	var userID int64
+	err = tx.QueryRow(`
+		insert into users (email, created_at, updated_at)
+		values ($1, now(), now())
+		on conflict (email) do update
+		set updated_at = now()
+		returning id
+	`, email).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if fcmToken != nil && *fcmToken != "" {
+		if _, err := s.RegisterPushDevice(userID, PushPlatformFCM, *fcmToken, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// ConfirmEmail uses up a link RequestEmailLogin sent to confirm an email for
+// userID, who must be the user who asked for it, and sets it as their email
+func (s *Server) ConfirmEmail(userID int64, token string) error {
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`
+		update email_login_tokens
+		set used_at = now()
+		where token_hash = $1
+			and used_at is null
+			and expires_at >= now()
+			and user_id = $2
+		returning email
+	`, hashToken(token), userID).Scan(&email)
+	switch {
+	case err == pgx.ErrNoRows:
+		return ErrInvalidEmailLink
+	case err != nil:
+		return err
+	}
+
+	_, err = tx.Exec(`
+		update users
+		set email = $2, updated_at = now()
+		where id = $1
+	`, userID, email)
+	switch {
+	case isUniqueViolation(err):
+		return ErrEmailTaken
+	case err != nil:
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -11,16 +11,33 @@ type Like struct {
 	PostID int32 `gorm:"index"`
 }
 
-// Like a post
+// Like a post, its author is notified in the same transaction
 func (s *Server) Like(userID, postID int32) (*Like, error) {
-	db := s.DB
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	like := &Like{UserID: userID, PostID: postID}
+	err = tx.QueryRow(`
+		insert into likes (user_id, post_id, created_at, updated_at)
+		values ($1, $2, now(), now())
+		returning id, created_at, updated_at
+	`, userID, postID).Scan(&like.ID, &like.CreatedAt, &like.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := db.Create(&like).Error; err != nil {
+	if err := s.notifyLike(tx, int64(userID), int64(postID)); err != nil {
 		return nil, err
 	}
 
-	_, err := s.RecalculateLikeCount(postID)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	_, err = s.RecalculateLikeCount(postID)
 	if err != nil {
 		return nil, err
 	}
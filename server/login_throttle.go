@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+const (
+	// MaxLoginCodeAttempts is how many wrong guesses disable a login code
+	MaxLoginCodeAttempts = 5
+
+	// phones and IPs are locked out once they pass these many failures in
+	// loginFailureWindow, IPs get more since many phones can share one
+	phoneLoginFailuresBeforeLockout = 5
+	ipLoginFailuresBeforeLockout    = 20
+	loginFailureWindow              = 24 * time.Hour
+
+	// lockouts start at loginLockoutBase and double with each failure after
+	loginLockoutBase = time.Minute
+	loginLockoutMax  = 24 * time.Hour
+)
+
+const (
+	loginFailurePhone = "phone"
+	loginFailureIP    = "ip"
+)
+
+// LoginLockedError is returned while a phone number or IP is locked out
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	wait := time.Until(e.Until).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	return fmt.Sprintf("too many failed login attempts, try again in %s", wait)
+}
+
+// CheckLoginAllowed returns a *LoginLockedError if the phone number or the
+// client IP is locked out. An empty ip is skipped.
+func (s *Server) CheckLoginAllowed(phoneNumber string, ip string) error {
+	var lockedUntil *time.Time
+	err := s.ConnPool.QueryRow(`
+		select max(locked_until)
+		from login_failures
+		where ((kind = $1 and key = $2) or (kind = $3 and key = $4))
+			and locked_until > now()
+	`, loginFailurePhone, phoneNumber, loginFailureIP, ip).Scan(&lockedUntil)
+	if err != nil {
+		return err
+	}
+
+	if lockedUntil != nil {
+		return &LoginLockedError{Until: *lockedUntil}
+	}
+
+	return nil
+}
+
+// LoginCodeClaim is an active login code an attempt was counted against
+type LoginCodeClaim struct {
+	ID   int64
+	Code string
+}
+
+// ClaimLoginAttempt counts an attempt at the phone number's active login
+// codes before they're compared, in one statement, so concurrent guesses
+// each take one of their MaxLoginCodeAttempts. It returns the codes to
+// compare against, leaving out those whose attempts are used up, which
+// disables them. Logging in with a code resets its count.
+func (s *Server) ClaimLoginAttempt(phoneNumber string) ([]LoginCodeClaim, error) {
+	rows, err := s.ConnPool.Query(`
+		update login_codes
+		set failed_attempts = failed_attempts + 1,
+			enabled = failed_attempts + 1 <= $2,
+			updated_at = now()
+		where phone_number = $1
+			and enabled is true
+			and expires_at >= now()
+		returning id, login_code, failed_attempts
+	`, phoneNumber, MaxLoginCodeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claims []LoginCodeClaim
+	for rows.Next() {
+		var claim LoginCodeClaim
+		var attempts int
+		if err := rows.Scan(&claim.ID, &claim.Code, &attempts); err != nil {
+			return nil, err
+		}
+
+		if attempts <= MaxLoginCodeAttempts {
+			claims = append(claims, claim)
+		}
+	}
+
+	return claims, rows.Err()
+}
+
+// RecordLoginFailure counts a wrong login code against the phone number and
+// the client IP, locking them out when they pass their limit
+func (s *Server) RecordLoginFailure(phoneNumber string, ip string) error {
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordLoginFailure(tx, loginFailurePhone, phoneNumber, phoneLoginFailuresBeforeLockout); err != nil {
+		return err
+	}
+
+	if ip != "" {
+		if err := recordLoginFailure(tx, loginFailureIP, ip, ipLoginFailuresBeforeLockout); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ResetLoginFailures clears a phone number's failures after it logs in. The
+// IP's are kept, otherwise logging into your own number would reset them.
+func (s *Server) ResetLoginFailures(phoneNumber string) error {
+	_, err := s.ConnPool.Exec(`
+		delete from login_failures
+		where kind = $1 and key = $2
+	`, loginFailurePhone, phoneNumber)
+	return err
+}
+
+func recordLoginFailure(tx *pgx.Tx, kind string, key string, failuresBeforeLockout int) error {
+	var failures int
+	err := tx.QueryRow(`
+		insert into login_failures (kind, key, failures, last_failed_at)
+		values ($1, $2, 1, now())
+		on conflict (kind, key) do update
+		set failures = case
+				when login_failures.last_failed_at < now() - $3::interval then 1
+				else login_failures.failures + 1
+			end,
+			last_failed_at = now()
+		returning failures
+	`, kind, key, fmt.Sprintf("%d seconds", int(loginFailureWindow.Seconds()))).Scan(&failures)
+	if err != nil {
+		return err
+	}
+
+	if failures < failuresBeforeLockout {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		update login_failures set locked_until = now() + $3::interval
+		where kind = $1 and key = $2
+	`, kind, key, fmt.Sprintf("%d seconds", int(loginLockout(failures-failuresBeforeLockout).Seconds())))
+	return err
+}
+
+// loginLockout doubles from loginLockoutBase for each failure past the limit
+func loginLockout(extraFailures int) time.Duration {
+	lockout := float64(loginLockoutBase) * math.Pow(2, float64(extraFailures))
+	if lockout > float64(loginLockoutMax) {
+		return loginLockoutMax
+	}
+
+	return time.Duration(lockout)
+}
@@ -1,37 +1,440 @@
 package server
 
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+)
+
+// DefaultNeighborhoodSlug is the neighborhood used for users who haven't
+// picked a home neighborhood yet.
+const DefaultNeighborhoodSlug = "southie"
+
 type Neighborhood struct {
-	ID   int64
-	Slug string
-	Name string
+	ID   int64  `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+
+	ZIPCodes []string   `json:"zipCodes"`
+	Center   *Location  `json:"center"`
+	Boundary []Location `json:"boundary"`
+
+	// RetiredAt is set once a neighborhood is retired or merged into
+	// another, after which nobody can be placed in it
+	RetiredAt    *time.Time `json:"retiredAt"`
+	MergedIntoID *int64     `json:"mergedIntoID"`
 }
 
-func (s *Server) NeighborhoodBySlug(slug string) (*Neighborhood, error) {
+const neighborhoodColumns = `id, slug, name, zip_codes, center_lat, center_lng, boundary, retired_at, merged_into_id`
+
+var neighborhoodSlugRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var (
+	ErrNeighborhoodNotFound  = errors.New("neighborhood not found")
+	ErrNeighborhoodSlugTaken = errors.New("neighborhood slug is already taken")
+)
+
+func scanNeighborhood(row scannable) (*Neighborhood, error) {
 	var n Neighborhood
-	err := s.ConnPool.QueryRow(`
-		select id, slug, name from neighborhoods
-		where lower(slug) = lower($1)
-	`, slug).Scan(&n.ID, &n.Slug, &n.Name)
-	switch {
-	// case err == pgx.ErrNoRows:
-	case err != nil:
+	var result struct {
+		centerLat *float64
+		centerLng *float64
+		boundary  pgtype.Polygon
+	}
+	err := row.Scan(
+		&n.ID,
+		&n.Slug,
+		&n.Name,
+		&n.ZIPCodes,
+		&result.centerLat,
+		&result.centerLng,
+		&result.boundary,
+		&n.RetiredAt,
+		&n.MergedIntoID,
+	)
+	if err != nil {
 		return nil, err
 	}
 
+	n.SetGeography(result.centerLat, result.centerLng, result.boundary)
 	return &n, nil
 }
 
+// SetGeography fills in the center and boundary from their nullable columns
+func (n *Neighborhood) SetGeography(centerLat, centerLng *float64, boundary pgtype.Polygon) {
+	n.Center = nil
+	if centerLat != nil && centerLng != nil {
+		n.Center = &Location{Lat: *centerLat, Lng: *centerLng}
+	}
+
+	n.Boundary = locationsFromPolygon(boundary)
+}
+
+func (s *Server) NeighborhoodBySlug(slug string) (*Neighborhood, error) {
+	row := s.ConnPool.QueryRow(`
+		select `+neighborhoodColumns+` from neighborhoods
+		where lower(slug) = lower($1)
+	`, slug)
+
+	return scanNeighborhood(row)
+}
+
 func (s *Server) NeighborhoodByID(id int64) (*Neighborhood, error) {
-	var n Neighborhood
-	err := s.ConnPool.QueryRow(`
-		select id, slug, name from neighborhoods
+	row := s.ConnPool.QueryRow(`
+		select `+neighborhoodColumns+` from neighborhoods
 		where id = $1
-	`, id).Scan(&n.ID, &n.Slug, &n.Name)
+	`, id)
+
+	return scanNeighborhood(row)
+}
+
+// NeighborhoodForUpdate is NeighborhoodByID read in tx, locking the
+// neighborhood until it ends
+func (s *Server) NeighborhoodForUpdate(tx *pgx.Tx, id int64) (*Neighborhood, error) {
+	row := tx.QueryRow(`
+		select `+neighborhoodColumns+` from neighborhoods
+		where id = $1
+		for update
+	`, id)
+
+	n, err := scanNeighborhood(row)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNeighborhoodNotFound
+	}
+
+	return n, err
+}
+
+// HomeNeighborhood returns the neighborhood a user picked as their home,
+// falling back to the default neighborhood if they haven't picked one.
+func (s *Server) HomeNeighborhood(userID int64) (*Neighborhood, error) {
+	row := s.ConnPool.QueryRow(`
+		select `+neighborhoodColumns+` from neighborhoods
+		where id = (select neighborhood_id from users where id = $1)
+			and retired_at is null
+	`, userID)
+
+	n, err := scanNeighborhood(row)
 	switch {
-	// case err == pgx.ErrNoRows:
+	case err == pgx.ErrNoRows:
+		return s.NeighborhoodBySlug(DefaultNeighborhoodSlug)
 	case err != nil:
 		return nil, err
 	}
 
-	return &n, nil
+	return n, nil
+}
+
+// NeighborhoodForLocation returns the neighborhood whose boundary contains the
+// location, or nil if it isn't in any neighborhood we know about.
+func (s *Server) NeighborhoodForLocation(loc Location) (*Neighborhood, error) {
+	row := s.ConnPool.QueryRow(`
+		select `+neighborhoodColumns+` from neighborhoods
+		where boundary @> point($1, $2)
+			and retired_at is null
+		order by id asc
+		limit 1
+	`, loc.Lng, loc.Lat)
+
+	n, err := scanNeighborhood(row)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// NeighborhoodForZIPCode returns the neighborhood covering a ZIP code, or nil
+// if none does. ZIP+4 codes are matched on their first five digits.
+func (s *Server) NeighborhoodForZIPCode(zipCode string) (*Neighborhood, error) {
+	zipCode = strings.TrimSpace(zipCode)
+	if len(zipCode) > 5 {
+		zipCode = zipCode[:5]
+	}
+
+	// @> rather than any() so neighborhoods_zip_codes_index is used
+	row := s.ConnPool.QueryRow(`
+		select `+neighborhoodColumns+` from neighborhoods
+		where zip_codes @> array[$1::text]
+			and retired_at is null
+		order by id asc
+		limit 1
+	`, zipCode)
+
+	n, err := scanNeighborhood(row)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Neighborhoods lists neighborhoods by name
+func (s *Server) Neighborhoods(includeRetired bool) ([]*Neighborhood, error) {
+	rows, err := s.ConnPool.Query(`
+		select `+neighborhoodColumns+` from neighborhoods
+		where retired_at is null or $1
+		order by name asc
+	`, includeRetired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var neighborhoods []*Neighborhood
+	for rows.Next() {
+		n, err := scanNeighborhood(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		neighborhoods = append(neighborhoods, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return neighborhoods, nil
+}
+
+// NeighborhoodInput holds the editable fields of a neighborhood. Nil fields
+// are left unchanged on update.
+type NeighborhoodInput struct {
+	Slug     *string
+	Name     *string
+	ZIPCodes *[]string
+	Center   *Location
+	Boundary *[]Location
+}
+
+func (in NeighborhoodInput) validate() error {
+	if in.Slug != nil && !neighborhoodSlugRegex.MatchString(*in.Slug) {
+		return errors.New("slug must be lowercase letters, numbers and dashes")
+	}
+
+	if in.Name != nil && strings.TrimSpace(*in.Name) == "" {
+		return errors.New("name must not be blank")
+	}
+
+	if in.Center != nil && !in.Center.Valid() {
+		return errors.New("invalid center")
+	}
+
+	if in.Boundary != nil {
+		if len(*in.Boundary) < 3 {
+			return errors.New("boundary needs at least 3 points")
+		}
+
+		for _, loc := range *in.Boundary {
+			if !loc.Valid() {
+				return errors.New("invalid boundary")
+			}
+		}
+	}
+
+	return nil
+}
+
+// CreateNeighborhood adds a neighborhood, Slug and Name are required
+func (s *Server) CreateNeighborhood(tx *pgx.Tx, in NeighborhoodInput) (*Neighborhood, error) {
+	if in.Slug == nil || in.Name == nil {
+		return nil, errors.New("slug and name are required")
+	}
+
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+
+	zipCodes := []string{}
+	if in.ZIPCodes != nil {
+		zipCodes = *in.ZIPCodes
+	}
+
+	centerLat, centerLng := centerColumns(in.Center)
+	row := tx.QueryRow(`
+		insert into neighborhoods (slug, name, zip_codes, center_lat, center_lng, boundary, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, now(), now())
+		returning `+neighborhoodColumns,
+		*in.Slug, strings.TrimSpace(*in.Name), zipCodes, centerLat, centerLng, polygonFromLocations(in.Boundary))
+
+	n, err := scanNeighborhood(row)
+	if isUniqueViolation(err) {
+		return nil, ErrNeighborhoodSlugTaken
+	}
+
+	return n, err
+}
+
+// UpdateNeighborhood changes the non-nil fields of a neighborhood
+func (s *Server) UpdateNeighborhood(tx *pgx.Tx, id int64, in NeighborhoodInput) (*Neighborhood, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+
+	var name *string
+	if in.Name != nil {
+		trimmed := strings.TrimSpace(*in.Name)
+		name = &trimmed
+	}
+
+	centerLat, centerLng := centerColumns(in.Center)
+	row := tx.QueryRow(`
+		update neighborhoods
+		set slug = coalesce($2, slug),
+			name = coalesce($3, name),
+			zip_codes = coalesce($4, zip_codes),
+			center_lat = coalesce($5, center_lat),
+			center_lng = coalesce($6, center_lng),
+			boundary = coalesce($7, boundary),
+			updated_at = now()
+		where id = $1
+		returning `+neighborhoodColumns,
+		id, in.Slug, name, in.ZIPCodes, centerLat, centerLng, polygonFromLocations(in.Boundary))
+
+	n, err := scanNeighborhood(row)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, ErrNeighborhoodNotFound
+	case isUniqueViolation(err):
+		return nil, ErrNeighborhoodSlugTaken
+	}
+
+	return n, err
+}
+
+// SetNeighborhoodRetired retires a neighborhood so nobody new can be placed
+// in it, or brings it back. Existing posts and homes are left alone.
+func (s *Server) SetNeighborhoodRetired(tx *pgx.Tx, id int64, retired bool) (*Neighborhood, error) {
+	row := tx.QueryRow(`
+		update neighborhoods
+		set retired_at = case when $2 then coalesce(retired_at, now()) end,
+			merged_into_id = case when $2 then merged_into_id end,
+			updated_at = now()
+		where id = $1
+			and lower(slug) <> lower($3)
+		returning `+neighborhoodColumns,
+		id, retired, DefaultNeighborhoodSlug)
+
+	n, err := scanNeighborhood(row)
+	if err == pgx.ErrNoRows {
+		return nil, errors.New("neighborhood not found or is the default neighborhood")
+	}
+
+	return n, err
+}
+
+// MergeNeighborhoods moves every post and user from one neighborhood into
+// another in tx, takes over its ZIP codes and retires it.
+func (s *Server) MergeNeighborhoods(tx *pgx.Tx, fromID, intoID int64) (*Neighborhood, error) {
+	if fromID == intoID {
+		return nil, errors.New("can't merge a neighborhood into itself")
+	}
+
+	// lock both so a concurrent merge can't go the other way
+	var result struct {
+		count        int
+		retiredCount int
+	}
+	err := tx.QueryRow(`
+		select count(*), count(*) filter (where retired_at is not null) from (
+			select retired_at from neighborhoods
+			where id in ($1, $2)
+			order by id
+			for update
+		) locked
+	`, fromID, intoID).Scan(&result.count, &result.retiredCount)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case result.count != 2:
+		return nil, ErrNeighborhoodNotFound
+	case result.retiredCount > 0:
+		return nil, errors.New("can't merge retired neighborhoods")
+	}
+
+	if _, err := tx.Exec(`
+		update posts set neighborhood_id = $2, updated_at = now()
+		where neighborhood_id = $1
+	`, fromID, intoID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		update users set neighborhood_id = $2, updated_at = now()
+		where neighborhood_id = $1
+	`, fromID, intoID); err != nil {
+		return nil, err
+	}
+
+	row := tx.QueryRow(`
+		update neighborhoods
+		set zip_codes = array(
+				select distinct unnest(zip_codes || (select zip_codes from neighborhoods where id = $1))
+				order by 1
+			),
+			updated_at = now()
+		where id = $2
+		returning `+neighborhoodColumns,
+		fromID, intoID)
+	into, err := scanNeighborhood(row)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		update neighborhoods
+		set retired_at = now(), merged_into_id = $2, zip_codes = '{}', updated_at = now()
+		where id = $1
+	`, fromID, intoID); err != nil {
+		return nil, err
+	}
+
+	return into, nil
+}
+
+func centerColumns(center *Location) (*float64, *float64) {
+	if center == nil {
+		return nil, nil
+	}
+
+	return &center.Lat, &center.Lng
+}
+
+func polygonFromLocations(locations *[]Location) *pgtype.Polygon {
+	if locations == nil {
+		return nil
+	}
+
+	p := pgtype.Polygon{Status: pgtype.Present}
+	for _, loc := range *locations {
+		p.P = append(p.P, pgtype.Vec2{X: loc.Lng, Y: loc.Lat})
+	}
+
+	return &p
+}
+
+func locationsFromPolygon(p pgtype.Polygon) []Location {
+	if p.Status != pgtype.Present {
+		return nil
+	}
+
+	locations := make([]Location, 0, len(p.P))
+	for _, v := range p.P {
+		locations = append(locations, Location{Lat: v.Y, Lng: v.X})
+	}
+
+	return locations
 }
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// NotificationSettings are what a user wants to hear about, and when not to
+// be pushed
+type NotificationSettings struct {
+	UserID int64
+
+	Messages bool
+	// Comments covers comments on the user's posts and replies to their
+	// comments
+	Comments          bool
+	Likes             bool
+	Follows           bool
+	NeighborhoodPosts bool
+
+	// QuietHoursStart and QuietHoursEnd are minutes after midnight in
+	// TimeZone, both nil without quiet hours. An end before the start is on
+	// the next day.
+	QuietHoursStart *int32
+	QuietHoursEnd   *int32
+	// TimeZone is an IANA time zone name
+	TimeZone string
+}
+
+// DefaultNotificationSettings are the settings of a user who hasn't changed
+// any
+func DefaultNotificationSettings(userID int64) *NotificationSettings {
+	return &NotificationSettings{
+		UserID:   userID,
+		Messages: true,
+		Comments: true,
+		Likes:    true,
+		Follows:  true,
+		TimeZone: "UTC",
+	}
+}
+
+// Allows reports whether the user wants notifications of kind
+func (n *NotificationSettings) Allows(kind NotificationKind) bool {
+	switch kind {
+	case NotificationMessage:
+		return n.Messages
+	case NotificationComment, NotificationReply:
+		return n.Comments
+	case NotificationLike:
+		return n.Likes
+	case NotificationFollow:
+		return n.Follows
+	case NotificationDigest:
+		return n.NeighborhoodPosts
+	}
+
+	return true
+}
+
+// Quiet reports whether at is in the user's quiet hours
+func (n *NotificationSettings) Quiet(at time.Time) bool {
+	if n.QuietHoursStart == nil || n.QuietHoursEnd == nil {
+		return false
+	}
+
+	loc, err := time.LoadLocation(n.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := at.In(loc)
+	minute := int32(local.Hour()*60 + local.Minute())
+
+	start, end := *n.QuietHoursStart, *n.QuietHoursEnd
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// Validate checks the quiet hours and time zone
+func (n *NotificationSettings) Validate() error {
+	if (n.QuietHoursStart == nil) != (n.QuietHoursEnd == nil) {
+		return errors.New("quiet hours need a start and an end")
+	}
+
+	if n.QuietHoursStart != nil {
+		for _, minute := range []int32{*n.QuietHoursStart, *n.QuietHoursEnd} {
+			if minute < 0 || minute >= 24*60 {
+				return errors.New("quiet hours must be between 00:00 and 23:59")
+			}
+		}
+
+		if *n.QuietHoursStart == *n.QuietHoursEnd {
+			return errors.New("quiet hours can't start and end at the same time")
+		}
+	}
+
+	if _, err := time.LoadLocation(n.TimeZone); err != nil || n.TimeZone == "" {
+		return errors.New("unknown time zone")
+	}
+
+	return nil
+}
+
+const notificationSettingsColumns = `user_id, messages, comments, likes, follows, neighborhood_posts, quiet_hours_start, quiet_hours_end, time_zone`
+
+// NotificationSettings are a user's settings, the defaults if they haven't
+// changed any
+func (s *Server) NotificationSettings(userID int64) (*NotificationSettings, error) {
+	return notificationSettings(s.ConnPool, userID)
+}
+
+func notificationSettings(db querier, userID int64) (*NotificationSettings, error) {
+	settings, err := scanNotificationSettings(db.QueryRow(`
+		select `+notificationSettingsColumns+`
+		from notification_settings
+		where user_id = $1
+	`, userID))
+	if err == pgx.ErrNoRows {
+		return DefaultNotificationSettings(userID), nil
+	}
+
+	return settings, err
+}
+
+// UpdateNotificationSettings replaces settings.UserID's settings
+func (s *Server) UpdateNotificationSettings(settings NotificationSettings) (*NotificationSettings, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	return scanNotificationSettings(s.ConnPool.QueryRow(`
+		insert into notification_settings (`+notificationSettingsColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		on conflict (user_id) do update
+		set messages = excluded.messages,
+			comments = excluded.comments,
+			likes = excluded.likes,
+			follows = excluded.follows,
+			neighborhood_posts = excluded.neighborhood_posts,
+			quiet_hours_start = excluded.quiet_hours_start,
+			quiet_hours_end = excluded.quiet_hours_end,
+			time_zone = excluded.time_zone,
+			updated_at = now()
+		returning `+notificationSettingsColumns+`
+	`,
+		settings.UserID,
+		settings.Messages,
+		settings.Comments,
+		settings.Likes,
+		settings.Follows,
+		settings.NeighborhoodPosts,
+		settings.QuietHoursStart,
+		settings.QuietHoursEnd,
+		settings.TimeZone,
+	))
+}
+
+func scanNotificationSettings(row scannable) (*NotificationSettings, error) {
+	var n NotificationSettings
+	err := row.Scan(
+		&n.UserID,
+		&n.Messages,
+		&n.Comments,
+		&n.Likes,
+		&n.Follows,
+		&n.NeighborhoodPosts,
+		&n.QuietHoursStart,
+		&n.QuietHoursEnd,
+		&n.TimeZone,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
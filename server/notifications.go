@@ -1,52 +1,491 @@
 package server
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/jackc/pgx"
 )
 
-func (s *Server) PublishNotificationToUser(userID int64, message string) error {
-	rows, err := s.ConnPool.Query(`
-		select id, endpoint_arn from user_device_tokens
+// NotificationKind is the activity a notification is about
+type NotificationKind string
+
+const (
+	// NotificationLike targets the liked post
+	NotificationLike NotificationKind = "like"
+	// NotificationComment targets the comment on the user's post
+	NotificationComment NotificationKind = "comment"
+	// NotificationReply targets the reply to the user's comment
+	NotificationReply NotificationKind = "reply"
+	// NotificationFollow has no target besides its actor
+	NotificationFollow NotificationKind = "follow"
+	// NotificationMessage targets the conversation
+	NotificationMessage NotificationKind = "message"
+	// NotificationDigest targets the top post of the user's neighborhood
+	// that day
+	NotificationDigest NotificationKind = "digest"
+)
+
+// NotificationCoalesceWindow is how long an unread like or comment
+// notification on a post takes in more of its kind
+const NotificationCoalesceWindow = 15 * time.Minute
+
+// coalescedKinds are the kinds that coalesce, and what their actors did
+var coalescedKinds = map[NotificationKind]string{
+	NotificationLike:    "liked your post",
+	NotificationComment: "commented on your post",
+}
+
+// coalescedContent is "Alex liked your post", or "Alex and 12 others liked
+// your post" once others have too
+func coalescedContent(name string, actorCount int32, did string) string {
+	switch actorCount {
+	case 1:
+		return fmt.Sprintf("%s %s", name, did)
+	case 2:
+		return fmt.Sprintf("%s and 1 other %s", name, did)
+	default:
+		return fmt.Sprintf("%s and %d others %s", name, actorCount-1, did)
+	}
+}
+
+// Notification is an entry in a user's inbox
+type Notification struct {
+	ID      int64
+	UserID  int64
+	Kind    NotificationKind
+	Content string
+
+	// ActorID is who caused the notification, nil once they're deleted.
+	// It's the latest of ActorCount users when notifications coalesced.
+	ActorID    *int64
+	ActorCount int32
+	// the notification's target, which are set depends on its Kind
+	PostID         *int64
+	CommentID      *int64
+	ConversationID *int64
+
+	Read      bool
+	CreatedAt time.Time
+}
+
+// DeepLink opens the notification's target in the app
+func (n *Notification) DeepLink() string {
+	switch {
+	case n.PostID != nil && n.CommentID != nil:
+		return CommentDeepLink(*n.PostID, *n.CommentID)
+	case n.PostID != nil:
+		return PostDeepLink(*n.PostID)
+	case n.ConversationID != nil:
+		return ConversationDeepLink(*n.ConversationID)
+	case n.ActorID != nil:
+		return UserDeepLink(*n.ActorID)
+	}
+
+	return NotificationsDeepLink()
+}
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+const notificationColumns = `id, user_id, kind, content, actor_id, actor_count, post_id, comment_id, conversation_id, read, created_at`
+
+func scanNotification(row scannable) (*Notification, error) {
+	var n Notification
+	var kind string
+	err := row.Scan(
+		&n.ID,
+		&n.UserID,
+		&kind,
+		&n.Content,
+		&n.ActorID,
+		&n.ActorCount,
+		&n.PostID,
+		&n.CommentID,
+		&n.ConversationID,
+		&n.Read,
+		&n.CreatedAt,
+	)
+	n.Kind = NotificationKind(kind)
+	return &n, err
+}
+
+// CreateNotification adds a notification to n.UserID's inbox and queues a
+// push of it. Nothing is added, and the notification is nil, for the user's
+// own activity, activity by someone they blocked or muted, or kinds they
+// turned off in their NotificationSettings. Likes and comments coalesce into
+// a recent unread notification on the same post, whose push is held until
+// its window closes.
+func (s *Server) CreateNotification(n Notification) (*Notification, error) {
+	return createNotification(s.ConnPool, n, PushMessage{Body: n.Content})
+}
+
+// createNotification is CreateNotification in db, usually the transaction of
+// the activity it's about, with push sent for it
+func createNotification(db querier, n Notification, push PushMessage) (*Notification, error) {
+	settings, err := notificationSettings(db, n.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !settings.Allows(n.Kind) {
+		return nil, nil
+	}
+
+	if n.ActorID != nil {
+		var ignored bool
+		err := db.QueryRow(`
+			select $1::bigint = $2::bigint or exists (
+				select 1 from user_blocks b
+				where b.user_id = $1
+					and b.blocked_user_id = $2
+			)
+		`, n.UserID, *n.ActorID).Scan(&ignored)
+		if err != nil {
+			return nil, err
+		}
+
+		if ignored {
+			return nil, nil
+		}
+	}
+
+	if did, ok := coalescedKinds[n.Kind]; ok && n.ActorID != nil && n.PostID != nil {
+		coalesced, err := coalesceNotification(db, n, did)
+		if err != nil {
+			return nil, err
+		}
+
+		if coalesced != nil {
+			if push.Body == n.Content {
+				push.Body = coalesced.Content
+			}
+
+			if err := publishNotificationAdded(db, coalesced); err != nil {
+				return nil, err
+			}
+
+			closes := coalesced.CreatedAt.Add(NotificationCoalesceWindow)
+			return coalesced, queueNotificationPush(db, coalesced, push, time.Until(closes))
+		}
+	}
+
+	created, err := scanNotification(db.QueryRow(`
+		insert into notifications (user_id, kind, content, actor_id, actor_ids, post_id, comment_id, conversation_id)
+		values ($1, $2, $3, $4, array_remove(array[$4::bigint], null), $5, $6, $7)
+		returning `+notificationColumns+`
+	`, n.UserID, string(n.Kind), n.Content, n.ActorID, n.PostID, n.CommentID, n.ConversationID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := publishNotificationAdded(db, created); err != nil {
+		return nil, err
+	}
+
+	return created, queueNotificationPush(db, created, push, 0)
+}
+
+// publishNotificationAdded tells n.UserID's subscriptions about n
+func publishNotificationAdded(db querier, n *Notification) error {
+	return publishRealtime(db, RealtimeEvent{
+		Kind:           RealtimeNotificationAdded,
+		UserIDs:        []int64{n.UserID},
+		NotificationID: n.ID,
+	})
+}
+
+// coalesceNotification folds n into the user's latest unread notification of
+// its kind on the same post, if that's inside NotificationCoalesceWindow
+func coalesceNotification(db querier, n Notification, did string) (*Notification, error) {
+	var result struct {
+		id         int64
+		actorCount int32
+		seen       bool
+	}
+	err := db.QueryRow(`
+		select id, actor_count, actor_ids @> array[$5::bigint]
+		from notifications
 		where user_id = $1
-	`, userID)
+			and kind = $2
+			and post_id = $3
+			and read is false
+			and created_at > now() - $4 * interval '1 second'
+		order by id desc
+		limit 1
+		for update
+	`, n.UserID, string(n.Kind), *n.PostID, NotificationCoalesceWindow.Seconds(), *n.ActorID).Scan(
+		&result.id,
+		&result.actorCount,
+		&result.seen,
+	)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	// a user already folded in, like someone liking again after unliking,
+	// isn't another actor
+	actorCount := result.actorCount
+	if !result.seen {
+		actorCount++
+	}
+
+	name, err := actorName(db, *n.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanNotification(db.QueryRow(`
+		update notifications
+		set actor_id = $2,
+			actor_ids = case when actor_ids @> array[$2::bigint] then actor_ids else array_append(actor_ids, $2) end,
+			actor_count = $3,
+			comment_id = coalesce($4, comment_id),
+			content = $5,
+			updated_at = now()
+		where id = $1
+		returning `+notificationColumns+`
+	`, result.id, *n.ActorID, actorCount, n.CommentID, coalescedContent(name, actorCount, did)))
+}
+
+// queueNotificationPush queues push for n, opening it when tapped. Pushes
+// for the same notification replace each other, in the outbox and on the
+// device.
+func queueNotificationPush(db querier, n *Notification, push PushMessage, delay time.Duration) error {
+	push.Link = n.DeepLink()
+	data := map[string]string{
+		"kind":           string(n.Kind),
+		"notificationID": strconv.FormatInt(n.ID, 10),
+	}
+	for k, v := range push.Data {
+		data[k] = v
+	}
+	push.Data = data
+
+	key := fmt.Sprintf("notification-%d", n.ID)
+	push.CollapseKey = key
+
+	return enqueueOutbox(db, OutboxPush, outboxPush{
+		UserID:  n.UserID,
+		Message: push,
+	}, key, delay)
+}
+
+// actorName is how notifications refer to the user who caused them
+func actorName(db querier, userID int64) (string, error) {
+	var name *string
+	err := db.QueryRow(`
+		select name from users where id = $1
+	`, userID).Scan(&name)
+	if err != nil && err != pgx.ErrNoRows {
+		return "", err
+	}
+
+	if name == nil || *name == "" {
+		return "Somebody", nil
+	}
+
+	return *name, nil
+}
+
+// notifyLike tells a post's author someone liked it, in the like's
+// transaction
+func (s *Server) notifyLike(tx *pgx.Tx, actorID, postID int64) error {
+	var authorID int64
+	err := tx.QueryRow(`
+		select user_id from posts where id = $1
+	`, postID).Scan(&authorID)
+	if err != nil {
+		return err
+	}
+
+	name, err := actorName(tx, actorID)
+	if err != nil {
+		return err
+	}
+
+	content := coalescedContent(name, 1, coalescedKinds[NotificationLike])
+	_, err = createNotification(tx, Notification{
+		UserID:  authorID,
+		Kind:    NotificationLike,
+		Content: content,
+		ActorID: &actorID,
+		PostID:  &postID,
+	}, PushMessage{Body: content})
+	return err
+}
+
+// NotifyComment tells the author of the comment replied to, or else the
+// post's author, about a new comment
+func (s *Server) NotifyComment(comment *PostComment) error {
+	actorID := int64(comment.UserID)
+	name, err := actorName(s.ConnPool, actorID)
+	if err != nil {
+		return err
+	}
+
+	postID := int64(comment.PostID)
+	n := Notification{
+		Kind:      NotificationComment,
+		Content:   coalescedContent(name, 1, coalescedKinds[NotificationComment]),
+		ActorID:   &actorID,
+		PostID:    &postID,
+		CommentID: &comment.ID,
+	}
+
+	if comment.ParentCommentID > 0 {
+		n.Kind = NotificationReply
+		n.Content = fmt.Sprintf("%s replied to your comment", name)
+		err = s.ConnPool.QueryRow(`
+			select user_id from post_comments where id = $1
+		`, comment.ParentCommentID).Scan(&n.UserID)
+	} else {
+		err = s.ConnPool.QueryRow(`
+			select user_id from posts where id = $1
+		`, comment.PostID).Scan(&n.UserID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.CreateNotification(n)
+	return err
+}
+
+// NotifyFollow tells a user someone followed them
+func (s *Server) NotifyFollow(actorID, userID int64) error {
+	name, err := actorName(s.ConnPool, actorID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.CreateNotification(Notification{
+		UserID:  userID,
+		Kind:    NotificationFollow,
+		Content: fmt.Sprintf("%s started following you", name),
+		ActorID: &actorID,
+	})
+	return err
+}
+
+// NotifyMessage tells a user they've been sent a message, in the
+// transaction that stored it. The push shows the message itself.
+func (s *Server) NotifyMessage(tx *pgx.Tx, actorID, userID, conversationID, messageID int64, body string) error {
+	name, err := actorName(s.ConnPool, actorID)
 	if err != nil {
 		return err
 	}
+
+	_, err = createNotification(tx, Notification{
+		UserID:         userID,
+		Kind:           NotificationMessage,
+		Content:        fmt.Sprintf("%s sent you a message", name),
+		ActorID:        &actorID,
+		ConversationID: &conversationID,
+	}, PushMessage{
+		Title: name,
+		Body:  body,
+		Data: map[string]string{
+			"type":      "messaging",
+			"messageID": strconv.FormatInt(messageID, 10),
+			"senderID":  strconv.FormatInt(actorID, 10),
+			"convID":    strconv.FormatInt(conversationID, 10),
+		},
+	})
+	return err
+}
+
+// Notifications is a user's inbox, newest first, before the notification
+// beforeID
+func (s *Server) Notifications(userID int64, unreadOnly bool, beforeID int64, limit int32) ([]Notification, error) {
+	rows, err := s.ConnPool.Query(`
+		select `+notificationColumns+`
+		from notifications
+		where user_id = $1
+			and (read is false or not $2)
+			and (id < $3 or $3 <= 0)
+		order by id desc
+		limit $4
+	`, userID, unreadOnly, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var endpointArns []string
+	var notifications []Notification
 	for rows.Next() {
-		var result struct {
-			id          int64
-			endpointArn string
-		}
-		if err := rows.Scan(&result.id, &result.endpointArn); err != nil {
-			return err
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
 		}
 
-		endpointArns = append(endpointArns, result.endpointArn)
+		notifications = append(notifications, *n)
 	}
 
-	if err := rows.Err(); err != nil {
+	return notifications, rows.Err()
+}
+
+// Notification is one of a user's notifications, ErrNotificationNotFound if
+// it isn't theirs or is gone
+func (s *Server) Notification(userID, notificationID int64) (*Notification, error) {
+	n, err := scanNotification(s.ConnPool.QueryRow(`
+		select `+notificationColumns+`
+		from notifications
+		where user_id = $1
+			and id = $2
+	`, userID, notificationID))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotificationNotFound
+	}
+
+	return n, err
+}
+
+// UnreadNotificationCount ...
+func (s *Server) UnreadNotificationCount(userID int64) (int32, error) {
+	var count int32
+	err := s.ConnPool.QueryRow(`
+		select count(*) from notifications where user_id = $1 and read is false
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks one of a user's notifications read, it's not an
+// error if it already was
+func (s *Server) MarkNotificationRead(userID, notificationID int64) error {
+	tag, err := s.ConnPool.Exec(`
+		update notifications
+		set read = true, updated_at = now()
+		where user_id = $1
+			and id = $2
+	`, userID, notificationID)
+	if err != nil {
 		return err
 	}
-	log.Println("##########")
-	for _, endpointArn := range endpointArns {
-		_, err := s.SNS.Publish(&sns.PublishInput{
-			Message:   aws.String(message),
-			TargetArn: aws.String(endpointArn),
-		})
-		if err != nil {
-			// some errors may be from disabled tokens, watch and deal with later
-			log.Println("@@@@@@@error", err)
-			// return err
-			continue
-		}
 
+	if tag.RowsAffected() == 0 {
+		return ErrNotificationNotFound
 	}
 
 	return nil
 }
+
+// MarkAllNotificationsRead marks a user's whole inbox read and returns how
+// many notifications were unread
+func (s *Server) MarkAllNotificationsRead(userID int64) (int32, error) {
+	tag, err := s.ConnPool.Exec(`
+		update notifications
+		set read = true, updated_at = now()
+		where user_id = $1
+			and read is false
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(tag.RowsAffected()), nil
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// OutboxKind is the side effect an outbox event carries out
+type OutboxKind string
+
+const (
+	// OutboxPush pushes a PushMessage to a user's devices
+	OutboxPush OutboxKind = "push"
+)
+
+// OutboxStatus is where an outbox event is in its life
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxDone    OutboxStatus = "done"
+	// OutboxDead events failed OutboxMaxAttempts times and are left for
+	// someone to look at
+	OutboxDead OutboxStatus = "dead"
+)
+
+const (
+	OutboxMaxAttempts = 8
+	// OutboxPollInterval is how long an idle worker waits to look again
+	OutboxPollInterval = 2 * time.Second
+
+	// outboxLease is how long a claimed event is left alone, a worker that
+	// dies mid event has it retried after
+	outboxLease = 5 * time.Minute
+
+	// workerMaxBackoff is the longest a worker loop waits after failing
+	// again and again, like while the database is down
+	workerMaxBackoff = 5 * time.Minute
+)
+
+// OutboxEvent is a side effect waiting in the outbox
+type OutboxEvent struct {
+	ID       int64
+	Kind     OutboxKind
+	Payload  []byte
+	Attempts int32
+	// Version changes whenever a pending event with its dedupe key is
+	// queued again
+	Version int32
+}
+
+type outboxPush struct {
+	UserID  int64       `json:"userID"`
+	Message PushMessage `json:"message"`
+	// Settled are the devices an earlier attempt reached or gave up on,
+	// retries only push to the rest
+	Settled []int64 `json:"settled,omitempty"`
+}
+
+// enqueueOutbox adds an event to the outbox in db, so it's only carried out
+// once the transaction it's part of commits, and not before delay. A
+// pending event with the same dedupeKey is replaced instead, keeping the
+// sooner of their due times.
+func enqueueOutbox(db querier, kind OutboxKind, payload interface{}, dedupeKey string, delay time.Duration) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		insert into outbox_events (kind, payload, dedupe_key, next_attempt_at)
+		values ($1, $2, nullif($3, ''), now() + $4 * interval '1 second')
+		on conflict (dedupe_key) where status = 'pending' do update
+		set payload = excluded.payload,
+			next_attempt_at = least(outbox_events.next_attempt_at, excluded.next_attempt_at),
+			version = outbox_events.version + 1,
+			updated_at = now()
+	`, string(kind), encoded, dedupeKey, delay.Seconds())
+	return err
+}
+
+// enqueuePush queues msg for a user's devices
+func enqueuePush(db querier, userID int64, msg PushMessage) error {
+	return enqueueOutbox(db, OutboxPush, outboxPush{
+		UserID:  userID,
+		Message: msg,
+	}, "", 0)
+}
+
+// ProcessOutbox drains the outbox for as long as the process runs, checking
+// every OutboxPollInterval once it's empty. Errors are logged and it tries
+// again after backing off.
+func (s *Server) ProcessOutbox() {
+	var failures int
+	for {
+		n, err := s.DrainOutbox(100)
+		if err != nil {
+			failures++
+			log.Println("outbox:", err)
+			time.Sleep(workerBackoff(OutboxPollInterval, failures))
+			continue
+		}
+		failures = 0
+
+		if n == 0 {
+			time.Sleep(OutboxPollInterval)
+		}
+	}
+}
+
+// DrainOutbox carries out up to limit due events and returns how many it
+// claimed. Failed events are retried with backoff, then left dead.
+func (s *Server) DrainOutbox(limit int) (int, error) {
+	// claiming leases the events rather than holding row locks, so nothing
+	// stays locked while pushes are sent
+	rows, err := s.ConnPool.Query(`
+		update outbox_events
+		set attempts = attempts + 1,
+			next_attempt_at = now() + $2 * interval '1 second',
+			updated_at = now()
+		where id in (
+			select id from outbox_events
+			where status = 'pending'
+				and next_attempt_at <= now()
+			order by next_attempt_at, id
+			limit $1
+			for update skip locked
+		)
+		returning id, kind, payload, attempts, version
+	`, limit, outboxLease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var kind string
+		if err := rows.Scan(&e.ID, &kind, &e.Payload, &e.Attempts, &e.Version); err != nil {
+			return 0, err
+		}
+
+		e.Kind = OutboxKind(kind)
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, e := range events {
+		payload, handleErr := s.handleOutboxEvent(e)
+		if err := s.finishOutboxEvent(e, payload, handleErr); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// handleOutboxEvent carries out e. When it fails, payload is what a retry
+// should carry out instead, nil to retry e as it is.
+func (s *Server) handleOutboxEvent(e OutboxEvent) (payload []byte, err error) {
+	switch e.Kind {
+	case OutboxPush:
+		var p outboxPush
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return nil, err
+		}
+
+		settled := map[int64]bool{}
+		for _, id := range p.Settled {
+			settled[id] = true
+		}
+
+		p.Settled, err = s.PushToUser(p.UserID, p.Message, settled)
+		if err == nil || len(p.Settled) == 0 {
+			return nil, err
+		}
+
+		payload, marshalErr := json.Marshal(p)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		return payload, err
+	default:
+		return nil, fmt.Errorf("outbox: unknown kind %q", e.Kind)
+	}
+}
+
+// finishOutboxEvent records how handling e went. A failed event's payload is
+// replaced by payload, unless it was queued again meanwhile.
+func (s *Server) finishOutboxEvent(e OutboxEvent, payload []byte, handleErr error) error {
+	if handleErr == nil {
+		// an event queued again while it was being sent stays pending, to
+		// send what changed
+		_, err := s.ConnPool.Exec(`
+			update outbox_events
+			set status = case when version = $2 then 'done' else status end,
+				attempts = case when version = $2 then attempts else 0 end,
+				processed_at = now(),
+				last_error = null,
+				updated_at = now()
+			where id = $1
+		`, e.ID, e.Version)
+		return err
+	}
+
+	status := OutboxPending
+	if e.Attempts >= OutboxMaxAttempts {
+		status = OutboxDead
+		log.Printf("outbox: event %d is dead after %d attempts: %s", e.ID, e.Attempts, handleErr)
+	}
+
+	_, err := s.ConnPool.Exec(`
+		update outbox_events
+		set status = $2,
+			last_error = $3,
+			next_attempt_at = now() + $4 * interval '1 second',
+			payload = case when version = $5 and $6::jsonb is not null then $6::jsonb else payload end,
+			updated_at = now()
+		where id = $1
+	`, e.ID, string(status), handleErr.Error(), outboxBackoff(e.Attempts).Seconds(), e.Version, payload)
+	return err
+}
+
+// outboxBackoff is the wait before retrying an event that's failed attempts
+// times, doubling from 30 seconds up to an hour
+func outboxBackoff(attempts int32) time.Duration {
+	backoff := 30 * time.Second
+	for i := int32(1); i < attempts && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+
+	if backoff > time.Hour {
+		return time.Hour
+	}
+	return backoff
+}
+
+// workerBackoff is the wait before a worker loop tries again after failing
+// failures times in a row, doubling from interval up to workerMaxBackoff
+func workerBackoff(interval time.Duration, failures int) time.Duration {
+	backoff := interval
+	for i := 1; i < failures && backoff < workerMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > workerMaxBackoff {
+		return workerMaxBackoff
+	}
+	return backoff
+}
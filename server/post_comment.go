@@ -1,7 +1,11 @@
 package server
 
 import (
+	"errors"
 	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/jinzhu/gorm"
 )
 
 // PostComment ...
@@ -13,6 +17,10 @@ type PostComment struct {
 	UserID          int32 `gorm:"index"`
 	PostID          int32 `gorm:"index"`
 
+	// Hidden comments were shadow hidden by a ContentFilter, only their
+	// author sees them
+	Hidden bool
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt *time.Time
@@ -23,13 +31,15 @@ func (s *Server) CreatePostComment(
 	parentCommentID,
 	userID,
 	postID int32,
-	comment string) (*PostComment, error) {
+	comment string,
+	hidden bool) (*PostComment, error) {
 	db := s.DB
 	pc := &PostComment{
 		Comment:         comment,
 		UserID:          userID,
 		PostID:          postID,
 		ParentCommentID: parentCommentID,
+		Hidden:          hidden,
 	}
 
 	if err := db.Create(&pc).Error; err != nil {
@@ -44,14 +54,44 @@ func (s *Server) CreatePostComment(
 	return pc, nil
 }
 
-// RemovePostComment ...
-func (s *Server) RemovePostComment(commentID, postID int32) error {
+// PostCommentByID is a comment, nil once it's deleted
+func (s *Server) PostCommentByID(id int64) (*PostComment, error) {
+	var pc PostComment
+	if err := s.DB.First(&pc, id).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &pc, nil
+}
+
+// RemovePostComment deletes a comment on a post, only its author or the
+// post's author may
+func (s *Server) RemovePostComment(userID int64, commentID, postID int32) error {
 	db := s.DB
 
+	var postUserID int64
+	err := s.ConnPool.QueryRow(`
+		select p.user_id
+		from post_comments c
+		join posts p on p.id = c.post_id
+		where c.id = $1
+			and c.post_id = $2
+			and (c.user_id = $3 or p.user_id = $3)
+	`, commentID, postID, userID).Scan(&postUserID)
+	switch {
+	case err == pgx.ErrNoRows:
+		return errors.New("comment not found")
+	case err != nil:
+		return err
+	}
+
 	if err := db.Unscoped().Where("id = ?", commentID).Delete(&PostComment{}).Error; err != nil {
 		return err
 	}
-	_, err := s.RecalculatePostCommentCount(postID)
+	_, err = s.RecalculatePostCommentCount(postID)
 	if err != nil {
 		return err
 	}
@@ -33,10 +33,21 @@ type Post struct {
 
 	Processing bool
 
-	ViewTimes	 *int64 `gorm:"default:0"`
+	// Location is where the post was made, if the author shared it
+	Location *Location `gorm:"-"`
+
+	ViewTimes    *int64 `gorm:"default:0"`
 	Likes        *int32 `gorm:"default:0"`
 	CommentCount *int32 `gorm:"default:0"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// SetLocation fills in Location from the nullable lat/lng columns
+func (p *Post) SetLocation(lat, lng *float64) {
+	p.Location = nil
+	if lat != nil && lng != nil {
+		p.Location = &Location{Lat: *lat, Lng: *lng}
+	}
+}
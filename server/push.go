@@ -0,0 +1,365 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// PushPlatform is the push service a device token belongs to
+type PushPlatform string
+
+const (
+	PushPlatformAPNs PushPlatform = "apns"
+	PushPlatformFCM  PushPlatform = "fcm"
+)
+
+func (p PushPlatform) valid() bool {
+	return p == PushPlatformAPNs || p == PushPlatformFCM
+}
+
+var (
+	ErrUnknownPushPlatform = errors.New("unknown push platform")
+	// ErrPushTokenInvalid is returned by PushSenders for tokens the platform
+	// won't deliver to anymore, eg the app was uninstalled
+	ErrPushTokenInvalid = errors.New("push token is no longer valid")
+	// ErrPushPlatformNotConfigured is returned for devices on a platform
+	// this server has no credentials for
+	ErrPushPlatformNotConfigured = errors.New("push: platform isn't configured")
+)
+
+// PushDevice is a device a user gets pushes on
+type PushDevice struct {
+	ID       int64
+	UserID   int64
+	Platform PushPlatform
+	Token    string
+	// DeviceID is the ID the device logs in with, when it gave one
+	DeviceID *string
+}
+
+// PushMessage is a push notification, senders shape it into their
+// platform's payload
+type PushMessage struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body"`
+	// Link is the deep link tapping the push opens
+	Link string `json:"link,omitempty"`
+	// Data is delivered to the app along with the link
+	Data map[string]string `json:"data,omitempty"`
+	// CollapseKey replaces an undelivered push with the same key
+	CollapseKey string `json:"collapseKey,omitempty"`
+}
+
+// PushSender delivers a push to one device
+type PushSender interface {
+	SendPush(device PushDevice, msg PushMessage) error
+}
+
+// PlatformPushSender hands each push to the sender for its device's
+// platform
+type PlatformPushSender struct {
+	APNs PushSender
+	FCM  PushSender
+}
+
+func (s *PlatformPushSender) SendPush(device PushDevice, msg PushMessage) error {
+	var sender PushSender
+	switch device.Platform {
+	case PushPlatformAPNs:
+		sender = s.APNs
+	case PushPlatformFCM:
+		sender = s.FCM
+	default:
+		return ErrUnknownPushPlatform
+	}
+
+	if sender == nil {
+		return ErrPushPlatformNotConfigured
+	}
+
+	return sender.SendPush(device, msg)
+}
+
+// Push is a push a FakePushSender was asked to send
+type Push struct {
+	Device  PushDevice
+	Message PushMessage
+	SentAt  time.Time
+}
+
+// FakePushSender records pushes instead of sending them
+type FakePushSender struct {
+	// InvalidTokens are answered with ErrPushTokenInvalid
+	InvalidTokens map[string]bool
+	// Err fails every other push, like an outage would
+	Err error
+	// TokenErrs fail pushes to just these tokens
+	TokenErrs map[string]error
+
+	mutex  sync.Mutex
+	pushes []Push
+}
+
+func (s *FakePushSender) SendPush(device PushDevice, msg PushMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.InvalidTokens[device.Token] {
+		return ErrPushTokenInvalid
+	}
+
+	if err := s.TokenErrs[device.Token]; err != nil {
+		return err
+	}
+
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.pushes = append(s.pushes, Push{
+		Device:  device,
+		Message: msg,
+		SentAt:  time.Now(),
+	})
+	return nil
+}
+
+// Pushes is everything sent so far, oldest first
+func (s *FakePushSender) Pushes() []Push {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Push(nil), s.pushes...)
+}
+
+// PushesTo is everything sent to a user's devices, oldest first
+func (s *FakePushSender) PushesTo(userID int64) []Push {
+	var pushes []Push
+	for _, push := range s.Pushes() {
+		if push.Device.UserID == userID {
+			pushes = append(pushes, push)
+		}
+	}
+
+	return pushes
+}
+
+// RegisterPushDevice adds a device for userID's pushes. A token moves to
+// whoever registers it last, and a device that logs in with a deviceID only
+// keeps its newest token.
+func (s *Server) RegisterPushDevice(userID int64, platform PushPlatform, token string, deviceID *string) (*PushDevice, error) {
+	if !platform.valid() {
+		return nil, ErrUnknownPushPlatform
+	}
+
+	if token == "" {
+		return nil, errors.New("push token is required")
+	}
+
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if deviceID != nil {
+		_, err = tx.Exec(`
+			delete from user_device_tokens
+			where user_id = $1
+				and device_id = $2
+				and device_token <> $3
+		`, userID, *deviceID, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	device := PushDevice{
+		UserID:   userID,
+		Platform: platform,
+		Token:    token,
+		DeviceID: deviceID,
+	}
+	err = tx.QueryRow(`
+		insert into user_device_tokens (user_id, platform, device_token, device_id)
+		values ($1, $2, $3, $4)
+		on conflict (device_token) do update
+		set user_id = excluded.user_id,
+			platform = excluded.platform,
+			device_id = coalesce(excluded.device_id, user_device_tokens.device_id),
+			enabled = true,
+			disabled_reason = null,
+			updated_at = now()
+		returning id
+	`, userID, string(platform), token, deviceID).Scan(&device.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, tx.Commit()
+}
+
+// UnregisterPushDevice stops pushes to a token, eg when its user logs out.
+// It's not an error if the token isn't registered to them.
+func (s *Server) UnregisterPushDevice(userID int64, token string) error {
+	_, err := s.ConnPool.Exec(`
+		delete from user_device_tokens
+		where user_id = $1
+			and device_token = $2
+	`, userID, token)
+	return err
+}
+
+// PushDevices is the devices a user gets pushes on
+func (s *Server) PushDevices(userID int64) ([]PushDevice, error) {
+	rows, err := s.ConnPool.Query(`
+		select id, user_id, platform, device_token, device_id
+		from user_device_tokens
+		where user_id = $1
+			and enabled
+		order by id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []PushDevice
+	for rows.Next() {
+		var d PushDevice
+		var platform string
+		if err := rows.Scan(&d.ID, &d.UserID, &platform, &d.Token, &d.DeviceID); err != nil {
+			return nil, err
+		}
+
+		d.Platform = PushPlatform(platform)
+		devices = append(devices, d)
+	}
+
+	return devices, rows.Err()
+}
+
+// PushToUser sends msg to a user's devices, unless it's their quiet hours,
+// skipping those in settled. It returns the devices that are settled now:
+// the ones it reached, and the ones it never will, whose token is gone
+// (those are disabled) or whose platform isn't configured. Other failures
+// don't stop the remaining devices, the first is returned and those devices
+// are left for a retry.
+func (s *Server) PushToUser(userID int64, msg PushMessage, settled map[int64]bool) ([]int64, error) {
+	settings, err := s.NotificationSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.PushDevices(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []int64
+	for id := range settled {
+		done = append(done, id)
+	}
+
+	// dropped rather than held, anything worth seeing is in the inbox
+	if settings.Quiet(time.Now()) {
+		return done, nil
+	}
+
+	var firstErr error
+	for _, device := range devices {
+		if settled[device.ID] {
+			continue
+		}
+
+		err := s.Push.SendPush(device, msg)
+		switch {
+		case err == nil:
+			done = append(done, device.ID)
+		case err == ErrPushTokenInvalid:
+			if err := s.disablePushDevice(device.ID, err.Error()); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			done = append(done, device.ID)
+		case err == ErrPushPlatformNotConfigured || err == ErrUnknownPushPlatform:
+			log.Printf("push: device %d: %s", device.ID, err)
+			done = append(done, device.ID)
+		default:
+			log.Printf("push: device %d: %s", device.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return done, firstErr
+}
+
+func (s *Server) disablePushDevice(id int64, reason string) error {
+	_, err := s.ConnPool.Exec(`
+		update user_device_tokens
+		set enabled = false, disabled_reason = $2, updated_at = now()
+		where id = $1
+	`, id, reason)
+	return err
+}
+
+// newPushSender picks the push provider from PUSH_PROVIDER. By default APNs
+// is set up when APNS_KEY_PATH is set and FCM when FCM_CREDENTIALS_PATH is.
+func newPushSender() (PushSender, error) {
+	switch provider := os.Getenv("PUSH_PROVIDER"); provider {
+	case "":
+	case "fake":
+		return &FakePushSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown PUSH_PROVIDER %q", provider)
+	}
+
+	sender := &PlatformPushSender{}
+
+	if path := os.Getenv("APNS_KEY_PATH"); path != "" {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		apns, err := NewAPNsSender(
+			key,
+			os.Getenv("APNS_KEY_ID"),
+			os.Getenv("APNS_TEAM_ID"),
+			os.Getenv("APNS_TOPIC"),
+			os.Getenv("APNS_SANDBOX") == "true",
+		)
+		if err != nil {
+			return nil, err
+		}
+		sender.APNs = apns
+	}
+
+	if path := os.Getenv("FCM_CREDENTIALS_PATH"); path != "" {
+		credentials, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		fcm, err := NewFCMSender(credentials)
+		if err != nil {
+			return nil, err
+		}
+		sender.FCM = fcm
+	}
+
+	if sender.APNs == nil && sender.FCM == nil {
+		log.Println("push: neither APNS_KEY_PATH nor FCM_CREDENTIALS_PATH is set, pushes will fail")
+	}
+
+	return sender, nil
+}
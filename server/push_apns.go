@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+
+	// Apple rejects provider tokens older than an hour and throttles ones
+	// refreshed more often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsSender pushes to iOS devices over the APNs HTTP/2 API, authenticating
+// with a .p8 signing key
+type APNsSender struct {
+	KeyID  string
+	TeamID string
+	// Topic is the app's bundle ID
+	Topic string
+	Key   *ecdsa.PrivateKey
+
+	BaseURL string
+	Client  *http.Client
+
+	mutex         sync.Mutex
+	token         string
+	tokenIssuedAt time.Time
+}
+
+// NewAPNsSender sends with the PEM encoded .p8 key, to the sandbox
+// environment for development builds of the app
+func NewAPNsSender(keyPEM []byte, keyID, teamID, topic string, sandbox bool) (*APNsSender, error) {
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, errors.New("apns: set APNS_KEY_ID, APNS_TEAM_ID and APNS_TOPIC")
+	}
+
+	key, err := jwt.ParseECPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := apnsProductionURL
+	if sandbox {
+		baseURL = apnsSandboxURL
+	}
+
+	return &APNsSender{
+		KeyID:   keyID,
+		TeamID:  teamID,
+		Topic:   topic,
+		Key:     key,
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *APNsSender) SendPush(device PushDevice, msg PushMessage) error {
+	token, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(apnsPayload(msg))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.BaseURL+"/3/device/"+device.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+token)
+	req.Header.Set("apns-topic", s.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	if msg.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", msg.CollapseKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var result struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		result.Reason == "BadDeviceToken",
+		result.Reason == "DeviceTokenNotForTopic":
+		return ErrPushTokenInvalid
+	default:
+		return fmt.Errorf("apns: %d %s", resp.StatusCode, result.Reason)
+	}
+}
+
+// providerToken is the JWT APNs authenticates the key with, reused until
+// it's close to expiring
+func (s *APNsSender) providerToken() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != "" && time.Since(s.tokenIssuedAt) < apnsTokenLifetime {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.StandardClaims{
+		Issuer:   s.TeamID,
+		IssuedAt: now.Unix(),
+	})
+	token.Header["kid"] = s.KeyID
+
+	signed, err := token.SignedString(s.Key)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = signed
+	s.tokenIssuedAt = now
+	return signed, nil
+}
+
+// apnsPayload is msg as an alert, the link and data are top level keys next
+// to aps
+func apnsPayload(msg PushMessage) map[string]interface{} {
+	alert := map[string]string{"body": msg.Body}
+	if msg.Title != "" {
+		alert["title"] = msg.Title
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": alert,
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	if msg.Link != "" {
+		payload["link"] = msg.Link
+	}
+
+	return payload
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	fcmBaseURL = "https://fcm.googleapis.com"
+	fcmScope   = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// FCMSender pushes to Android devices, and iOS devices registered through
+// Firebase, over the FCM HTTP v1 API with a service account
+type FCMSender struct {
+	ProjectID   string
+	ClientEmail string
+	Key         *rsa.PrivateKey
+	// TokenURL is where the service account's signed assertion is traded
+	// for an access token
+	TokenURL string
+
+	BaseURL string
+	Client  *http.Client
+
+	mutex          sync.Mutex
+	accessToken    string
+	tokenExpiresAt time.Time
+}
+
+// NewFCMSender sends as the service account in a Firebase credentials JSON
+// file
+func NewFCMSender(credentials []byte) (*FCMSender, error) {
+	var account struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(credentials, &account); err != nil {
+		return nil, err
+	}
+
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, errors.New("fcm: credentials need project_id, client_email and private_key")
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURL := account.TokenURI
+	if tokenURL == "" {
+		tokenURL = "https://oauth2.googleapis.com/token"
+	}
+
+	return &FCMSender{
+		ProjectID:   account.ProjectID,
+		ClientEmail: account.ClientEmail,
+		Key:         key,
+		TokenURL:    tokenURL,
+		BaseURL:     fcmBaseURL,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *FCMSender) SendPush(device PushDevice, msg PushMessage) error {
+	token, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"message": fcmMessage(device.Token, msg)})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", s.BaseURL, s.ProjectID)
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var result struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrPushTokenInvalid
+	}
+	for _, detail := range result.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return ErrPushTokenInvalid
+		}
+	}
+	if result.Error.Status == "INVALID_ARGUMENT" && strings.Contains(result.Error.Message, "registration token") {
+		return ErrPushTokenInvalid
+	}
+
+	return fmt.Errorf("fcm: %d %s", resp.StatusCode, result.Error.Message)
+}
+
+// token is an OAuth access token for the service account, reused until
+// it's close to expiring
+func (s *FCMSender) token() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.tokenExpiresAt) {
+		return s.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.ClientEmail,
+		"scope": fcmScope,
+		"aud":   s.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(s.Key)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.Client.PostForm(s.TokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm: token exchange failed with %d", resp.StatusCode)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	s.accessToken = result.AccessToken
+	// refresh a minute early so a token doesn't expire mid request
+	s.tokenExpiresAt = now.Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return s.accessToken, nil
+}
+
+// fcmMessage is msg as a notification message, with the link in its data so
+// the app can route to it when tapped
+func fcmMessage(token string, msg PushMessage) map[string]interface{} {
+	data := map[string]string{}
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	if msg.Link != "" {
+		data["link"] = msg.Link
+	}
+
+	android := map[string]interface{}{"priority": "high"}
+	if msg.CollapseKey != "" {
+		android["collapse_key"] = msg.CollapseKey
+	}
+
+	return map[string]interface{}{
+		"token": token,
+		"notification": map[string]string{
+			"title": msg.Title,
+			"body":  msg.Body,
+		},
+		"data":    data,
+		"android": android,
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPNsSenderErrors(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name    string
+		status  int
+		reason  string
+		invalid bool
+	}{
+		{"delivered", http.StatusOK, "", false},
+		{"unregistered", http.StatusGone, "Unregistered", true},
+		{"bad device token", http.StatusBadRequest, "BadDeviceToken", true},
+		{"token for another app", http.StatusBadRequest, "DeviceTokenNotForTopic", true},
+		{"outage", http.StatusServiceUnavailable, "ServiceUnavailable", false},
+		{"internal error", http.StatusInternalServerError, "InternalServerError", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/3/device/phone", r.URL.Path)
+				assert.Equal(t, "com.example.cobbles", r.Header.Get("apns-topic"))
+
+				w.WriteHeader(c.status)
+				if c.reason != "" {
+					json.NewEncoder(w).Encode(map[string]string{"reason": c.reason})
+				}
+			}))
+			defer srv.Close()
+
+			sender := &APNsSender{
+				KeyID:   "key",
+				TeamID:  "team",
+				Topic:   "com.example.cobbles",
+				Key:     key,
+				BaseURL: srv.URL,
+				Client:  srv.Client(),
+			}
+			err := sender.SendPush(PushDevice{Platform: PushPlatformAPNs, Token: "phone"}, PushMessage{Body: "hi"})
+
+			switch {
+			case c.status == http.StatusOK:
+				assert.NoError(t, err)
+			case c.invalid:
+				assert.Equal(t, ErrPushTokenInvalid, err)
+			default:
+				require.Error(t, err)
+				assert.NotEqual(t, ErrPushTokenInvalid, err)
+			}
+		})
+	}
+}
+
+func TestFCMSenderErrors(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		invalid bool
+	}{
+		{"delivered", http.StatusOK, `{"name": "projects/cobbles/messages/1"}`, false},
+		{
+			"unregistered",
+			http.StatusNotFound,
+			`{"error": {"status": "NOT_FOUND", "message": "Requested entity was not found.", "details": [{"errorCode": "UNREGISTERED"}]}}`,
+			true,
+		},
+		{
+			"unregistered without a 404",
+			http.StatusBadRequest,
+			`{"error": {"status": "INVALID_ARGUMENT", "message": "gone", "details": [{"errorCode": "UNREGISTERED"}]}}`,
+			true,
+		},
+		{
+			"malformed token",
+			http.StatusBadRequest,
+			`{"error": {"status": "INVALID_ARGUMENT", "message": "The registration token is not a valid FCM registration token"}}`,
+			true,
+		},
+		{
+			"outage",
+			http.StatusServiceUnavailable,
+			`{"error": {"status": "UNAVAILABLE", "message": "The service is currently unavailable.", "details": [{"errorCode": "UNAVAILABLE"}]}}`,
+			false,
+		},
+		{
+			"internal error",
+			http.StatusInternalServerError,
+			`{"error": {"status": "INTERNAL", "message": "Internal error encountered."}}`,
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"access_token": "access",
+					"expires_in":   3600,
+				})
+			})
+			mux.HandleFunc("/v1/projects/cobbles/messages:send", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.body))
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			sender := &FCMSender{
+				ProjectID:   "cobbles",
+				ClientEmail: "push@cobbles.iam.gserviceaccount.com",
+				Key:         key,
+				TokenURL:    srv.URL + "/token",
+				BaseURL:     srv.URL,
+				Client:      srv.Client(),
+			}
+			err := sender.SendPush(PushDevice{Platform: PushPlatformFCM, Token: "phone"}, PushMessage{Body: "hi"})
+
+			switch {
+			case c.status == http.StatusOK:
+				assert.NoError(t, err)
+			case c.invalid:
+				assert.Equal(t, ErrPushTokenInvalid, err)
+			default:
+				require.Error(t, err)
+				assert.NotEqual(t, ErrPushTokenInvalid, err)
+			}
+		})
+	}
+}
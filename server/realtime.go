@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// RealtimeChannel is the postgres channel realtime events are NOTIFYed on,
+// every API instance LISTENs to it and hands them to its own subscribers
+const RealtimeChannel = "cobbles_events"
+
+const (
+	// realtimeBuffer is how many events a slow subscriber may fall behind
+	// before further ones are dropped for it
+	realtimeBuffer = 16
+
+	// realtimeRetryInterval is the wait before listening again after the
+	// listening connection fails
+	realtimeRetryInterval = 2 * time.Second
+)
+
+// RealtimeKind is what happened in a RealtimeEvent
+type RealtimeKind string
+
+const (
+	// RealtimeMessageAdded is a message sent in a conversation
+	RealtimeMessageAdded RealtimeKind = "message_added"
+	// RealtimeConversationAdded is a conversation started with the user
+	RealtimeConversationAdded RealtimeKind = "conversation_added"
+	// RealtimeNotificationAdded is a notification added to the user's inbox,
+	// or one a like or comment coalesced into
+	RealtimeNotificationAdded RealtimeKind = "notification_added"
+)
+
+// RealtimeEvent tells subscribed UserIDs something changed. It only carries
+// IDs, subscribers load what they show so it's never stale or unfiltered.
+type RealtimeEvent struct {
+	Kind    RealtimeKind `json:"kind"`
+	UserIDs []int64      `json:"userIDs"`
+
+	ConversationID int64 `json:"conversationID,omitempty"`
+	MessageID      int64 `json:"messageID,omitempty"`
+	NotificationID int64 `json:"notificationID,omitempty"`
+}
+
+// PublishRealtime sends e to its users' subscriptions on every API instance
+// once tx commits, and never if it rolls back
+func (s *Server) PublishRealtime(tx *pgx.Tx, e RealtimeEvent) error {
+	return publishRealtime(tx, e)
+}
+
+func publishRealtime(db querier, e RealtimeEvent) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`select pg_notify($1, $2)`, RealtimeChannel, string(payload))
+	return err
+}
+
+type realtimeHub struct {
+	// listening is closed once the hub first LISTENs
+	listening     chan struct{}
+	listeningOnce sync.Once
+
+	mu          sync.Mutex
+	subscribers map[int64]map[chan RealtimeEvent]struct{}
+}
+
+// SubscribeRealtime is the events published to userID from now on, until
+// unsubscribe is called. Events a subscriber is too slow for are dropped.
+func (s *Server) SubscribeRealtime(userID int64) (events <-chan RealtimeEvent, unsubscribe func()) {
+	hub := s.realtimeHub()
+	c := make(chan RealtimeEvent, realtimeBuffer)
+
+	// the first subscribers wait for the hub to start listening, so they
+	// don't miss what's published right after they subscribe
+	select {
+	case <-hub.listening:
+	case <-time.After(realtimeRetryInterval):
+	}
+
+	hub.mu.Lock()
+	if hub.subscribers[userID] == nil {
+		hub.subscribers[userID] = map[chan RealtimeEvent]struct{}{}
+	}
+	hub.subscribers[userID][c] = struct{}{}
+	hub.mu.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			hub.mu.Lock()
+			defer hub.mu.Unlock()
+
+			delete(hub.subscribers[userID], c)
+			if len(hub.subscribers[userID]) == 0 {
+				delete(hub.subscribers, userID)
+			}
+			close(c)
+		})
+	}
+}
+
+// realtimeHub starts listening for events the first time anyone subscribes
+func (s *Server) realtimeHub() *realtimeHub {
+	s.realtimeOnce.Do(func() {
+		s.realtime = &realtimeHub{
+			listening:   make(chan struct{}),
+			subscribers: map[int64]map[chan RealtimeEvent]struct{}{},
+		}
+		go s.listenRealtime(s.realtime)
+	})
+
+	return s.realtime
+}
+
+// listenRealtime relays events to hub for as long as the process runs.
+// Events published while it's reconnecting are missed, clients catch up by
+// querying when they resubscribe.
+func (s *Server) listenRealtime(hub *realtimeHub) {
+	for {
+		if err := s.listenRealtimeConn(hub); err != nil {
+			log.Println("realtime:", err)
+		}
+
+		time.Sleep(realtimeRetryInterval)
+	}
+}
+
+func (s *Server) listenRealtimeConn(hub *realtimeHub) error {
+	conn, err := s.ConnPool.Acquire()
+	if err != nil {
+		return err
+	}
+	defer s.ConnPool.Release(conn)
+
+	if err := conn.Listen(RealtimeChannel); err != nil {
+		return err
+	}
+	hub.listeningOnce.Do(func() { close(hub.listening) })
+
+	for {
+		notification, err := conn.WaitForNotification(context.Background())
+		if err != nil {
+			return err
+		}
+
+		var e RealtimeEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &e); err != nil {
+			log.Println("realtime:", err)
+			continue
+		}
+
+		hub.dispatch(e)
+	}
+}
+
+func (hub *realtimeHub) dispatch(e RealtimeEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for _, userID := range e.UserIDs {
+		for c := range hub.subscribers[userID] {
+			select {
+			case c <- e:
+			default:
+			}
+		}
+	}
+}
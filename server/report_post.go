@@ -1,32 +1,122 @@
 package server
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx"
 	"github.com/jinzhu/gorm"
 )
 
+// ReportReason is the category a reporter picked for a post
+type ReportReason string
+
+const (
+	ReportReasonSpam           ReportReason = "spam"
+	ReportReasonHarassment     ReportReason = "harassment"
+	ReportReasonHateSpeech     ReportReason = "hate_speech"
+	ReportReasonViolence       ReportReason = "violence"
+	ReportReasonSexual         ReportReason = "sexual"
+	ReportReasonMisinformation ReportReason = "misinformation"
+	ReportReasonOther          ReportReason = "other"
+)
+
+func (r ReportReason) valid() bool {
+	switch r {
+	case ReportReasonSpam,
+		ReportReasonHarassment,
+		ReportReasonHateSpeech,
+		ReportReasonViolence,
+		ReportReasonSexual,
+		ReportReasonMisinformation,
+		ReportReasonOther:
+		return true
+	}
+
+	return false
+}
+
+// ModerationAction is what a moderator did about a reported post
+type ModerationAction string
+
+const (
+	// ModerationActionDismiss leaves the post as it is
+	ModerationActionDismiss ModerationAction = "dismiss"
+	// ModerationActionHidePost keeps the post out of everyone's feeds but
+	// its author's
+	ModerationActionHidePost ModerationAction = "hide_post"
+	// ModerationActionRemovePost removes the post like its author would
+	ModerationActionRemovePost ModerationAction = "remove_post"
+	// ModerationActionSuspendAuthor removes the post and stops its author
+	// posting for a while
+	ModerationActionSuspendAuthor ModerationAction = "suspend_author"
+)
+
+func (a ModerationAction) valid() bool {
+	switch a {
+	case ModerationActionDismiss,
+		ModerationActionHidePost,
+		ModerationActionRemovePost,
+		ModerationActionSuspendAuthor:
+		return true
+	}
+
+	return false
+}
+
+// DefaultSuspension is how long ModerationActionSuspendAuthor suspends for
+// unless the moderator says otherwise
+const DefaultSuspension = 7 * 24 * time.Hour
+
+var (
+	ErrUnknownReportReason     = errors.New("unknown report reason")
+	ErrUnknownModerationAction = errors.New("unknown moderation action")
+)
+
 // ReportedPost Model
 type ReportedPost struct {
 	gorm.Model
 
 	PostID              int32
 	PersonReportingID   int32
+	Reason              ReportReason
 	ReportingReasonText string
-	ActionTaken         sql.NullInt64
+
+	// ActionTaken is nil until a moderator handles the report
+	ActionTaken     *ModerationAction
+	ActionTakenByID *int64
+	ActionTakenAt   *time.Time
+}
+
+// ReportedPostGroup is the open reports of a post, the unit moderators work
+// through
+type ReportedPostGroup struct {
+	PostID          int64
+	Reports         int32
+	Weight          float64
+	AutoHiddenAt    *time.Time
+	Reasons         []ReportReason
+	FirstReportedAt time.Time
+	LastReportedAt  time.Time
 }
 
 // CreateReportedPost - to create reporting post
 func (s *Server) CreateReportedPost(
 	postID int32,
 	personReportingID int32,
+	reason ReportReason,
 	reportingReasonText string,
 ) (*ReportedPost, error) {
+	if !reason.valid() {
+		return nil, ErrUnknownReportReason
+	}
 
 	db := s.DB
 	reportedPost := &ReportedPost{
 		PostID:              postID,
 		PersonReportingID:   personReportingID,
+		Reason:              reason,
 		ReportingReasonText: reportingReasonText,
 	}
 
@@ -34,27 +124,279 @@ func (s *Server) CreateReportedPost(
 		return nil, err
 	}
 
+	if err := s.autoHideReportedPost(int64(postID)); err != nil {
+		return nil, err
+	}
+
 	return reportedPost, nil
 }
 
-// UpdateReportedPost - to update action taken
-func (s *Server) UpdateReportedPost(
-	id int64,
-	actionTaken sql.NullInt64,
-) (*ReportedPost, error) {
-	if &id == nil {
-		return nil, nil
+// ReportedPostByID ...
+func (s *Server) ReportedPostByID(id int64) (*ReportedPost, error) {
+	reportedPost := ReportedPost{}
+	if err := s.DB.First(&reportedPost, id).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("reported post not found")
+		}
+		return nil, err
 	}
 
-	db := s.DB
-	reportedPost := ReportedPost{}
-	if err := db.First(&reportedPost, id).Error; err != nil {
+	return &reportedPost, nil
+}
+
+// ReportsForPost is a post's reports, newest first
+func (s *Server) ReportsForPost(postID int64, open bool) ([]ReportedPost, error) {
+	db := s.DB.Where("post_id = ?", postID)
+	if open {
+		db = db.Where("action_taken is null")
+	}
+
+	var reportedPosts []ReportedPost
+	if err := db.Order("id desc").Find(&reportedPosts).Error; err != nil {
 		return nil, err
 	}
 
-	reportedPost.ActionTaken = actionTaken
-	db.Save(&reportedPost)
+	return reportedPosts, nil
+}
 
-	return &reportedPost, nil
+// ReportedPostQueue is the posts with open reports, oldest post first, after
+// the post afterPostID
+func (s *Server) ReportedPostQueue(afterPostID int64, limit int32) ([]ReportedPostGroup, error) {
+	rows, err := s.ConnPool.Query(`
+		select
+			r.post_id,
+			count(*),
+			(select coalesce(sum(weight), 0)::float8 from (`+reportWeightSQL("r.post_id", "$1")+`) w),
+			p.auto_hidden_at,
+			array_agg(distinct r.reason),
+			min(r.created_at),
+			max(r.created_at)
+		from reported_posts r
+		join posts p on p.id = r.post_id
+		where r.action_taken is null
+			and r.deleted_at is null
+			and r.post_id > $2
+		group by r.post_id, p.auto_hidden_at
+		order by r.post_id
+		limit $3
+	`, s.AutoHideThreshold, afterPostID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []ReportedPostGroup
+	for rows.Next() {
+		var g ReportedPostGroup
+		var reasons []string
+		if err := rows.Scan(
+			&g.PostID,
+			&g.Reports,
+			&g.Weight,
+			&g.AutoHiddenAt,
+			&reasons,
+			&g.FirstReportedAt,
+			&g.LastReportedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		for _, reason := range reasons {
+			g.Reasons = append(g.Reasons, ReportReason(reason))
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// PostModerationState is the parts of a post moderation changes
+type PostModerationState struct {
+	UserID            int64      `json:"userID"`
+	Hidden            bool       `json:"hidden"`
+	Removed           bool       `json:"removed"`
+	AutoHiddenAt      *time.Time `json:"autoHiddenAt"`
+	ModeratorHiddenAt *time.Time `json:"moderatorHiddenAt"`
+	OpenReports       int32      `json:"openReports"`
+}
+
+// PostModeration is a post's PostModerationState
+func (s *Server) PostModeration(postID int64) (*PostModerationState, error) {
+	return postModeration(s.ConnPool, postID, "")
+}
+
+// PostModerationForUpdate is PostModeration read in tx, locking the post
+// until it ends
+func (s *Server) PostModerationForUpdate(tx *pgx.Tx, postID int64) (*PostModerationState, error) {
+	return postModeration(tx, postID, forUpdate)
+}
+
+func postModeration(db querier, postID int64, lock string) (*PostModerationState, error) {
+	var state PostModerationState
+	err := db.QueryRow(`
+		select
+			p.user_id,
+			p.hidden,
+			p.removed,
+			p.auto_hidden_at,
+			p.moderator_hidden_at,
+			(
+				select count(*)
+				from reported_posts r
+				where r.post_id = p.id
+					and r.action_taken is null
+					and r.deleted_at is null
+			)
+		from posts p
+		where p.id = $1
+		`+lock+`
+	`, postID).Scan(
+		&state.UserID,
+		&state.Hidden,
+		&state.Removed,
+		&state.AutoHiddenAt,
+		&state.ModeratorHiddenAt,
+		&state.OpenReports,
+	)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, errors.New("post not found")
+	case err != nil:
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// ModeratePost takes action on a reported post in tx and closes its open
+// reports. suspension is only used by ModerationActionSuspendAuthor, zero
+// means DefaultSuspension.
+func (s *Server) ModeratePost(tx *pgx.Tx, moderatorID, postID int64, action ModerationAction, suspension time.Duration) error {
+	if !action.valid() {
+		return ErrUnknownModerationAction
+	}
+
+	var authorID int64
+	err := tx.QueryRow(`
+		select user_id from posts where id = $1 for update
+	`, postID).Scan(&authorID)
+	switch {
+	case err == pgx.ErrNoRows:
+		return errors.New("post not found")
+	case err != nil:
+		return err
+	}
+
+	// dismissing shows a post hidden by reports or a moderator again, one
+	// the content filter shadow hid stays hidden
+	switch action {
+	case ModerationActionDismiss:
+		_, err = tx.Exec(`
+			update posts
+			set hidden = false,
+				auto_hidden_at = null,
+				moderator_hidden_at = null,
+				updated_at = now()
+			where id = $1
+				and (auto_hidden_at is not null or moderator_hidden_at is not null)
+		`, postID)
+	case ModerationActionHidePost:
+		_, err = tx.Exec(`
+			update posts
+			set hidden = true,
+				auto_hidden_at = null,
+				moderator_hidden_at = now(),
+				updated_at = now()
+			where id = $1
+		`, postID)
+	case ModerationActionRemovePost, ModerationActionSuspendAuthor:
+		_, err = tx.Exec(`
+			update posts
+			set removed = true, auto_hidden_at = null, updated_at = now()
+			where id = $1
+		`, postID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if action == ModerationActionSuspendAuthor {
+		if suspension <= 0 {
+			suspension = DefaultSuspension
+		}
+
+		// an existing longer suspension is kept, banned authors stay banned
+		_, err = tx.Exec(`
+			update users
+			set status = 'suspended',
+				suspended_until = greatest(
+					case when status = 'suspended' then suspended_until end,
+					now() + $2::float8 * interval '1 second'
+				),
+				status_reason = $3,
+				status_changed_by_id = $4,
+				status_changed_at = now(),
+				updated_at = now()
+			where id = $1
+				and status != 'banned'
+		`, authorID, suspension.Seconds(), fmt.Sprintf("reported post %d", postID), moderatorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		update reported_posts
+		set action_taken = $2,
+			action_taken_by_id = $3,
+			action_taken_at = now(),
+			updated_at = now()
+		where post_id = $1
+			and action_taken is null
+	`, postID, string(action), moderatorID)
+	if err != nil {
+		return err
+	}
+
+	if action == ModerationActionRemovePost || action == ModerationActionSuspendAuthor {
+		_, err = tx.Exec(`
+			update users
+			set post_count = (
+				select count(*) from posts where user_id = $1 and removed = false
+			)
+			where id = $1
+		`, authorID)
+	}
+
+	return err
+}
+
+// UpdateReportedPost takes action on a report's post in tx, which closes the
+// post's other open reports too
+func (s *Server) UpdateReportedPost(
+	tx *pgx.Tx,
+	moderatorID int64,
+	id int64,
+	action ModerationAction,
+) error {
+	reportedPost, err := s.ReportedPostByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ModeratePost(tx, moderatorID, int64(reportedPost.PostID), action, 0); err != nil {
+		return err
+	}
 
+	// an already handled report gets the new action too
+	_, err = tx.Exec(`
+		update reported_posts
+		set action_taken = $2,
+			action_taken_by_id = $3,
+			action_taken_at = now(),
+			updated_at = now()
+		where id = $1
+	`, id, string(action), moderatorID)
+	return err
 }
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx"
+)
+
+// Role grants access to admin and moderation operations
+type Role string
+
+const (
+	// RoleAdmin can do everything a moderator can, plus manage neighborhoods
+	// and roles
+	RoleAdmin Role = "admin"
+	// RoleModerator handles reported content
+	RoleModerator Role = "moderator"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+func (r Role) valid() bool {
+	return r == RoleAdmin || r == RoleModerator
+}
+
+// HasRole reports whether roles grant role, admins have every role
+func HasRole(roles []Role, role Role) bool {
+	for _, r := range roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserRoles is the roles a user has, none for unknown users
+func (s *Server) UserRoles(userID int64) ([]Role, error) {
+	return userRoles(s.ConnPool, userID)
+}
+
+// UserRolesForUpdate is UserRoles read in tx, locking the user until it ends
+func (s *Server) UserRolesForUpdate(tx *pgx.Tx, userID int64) ([]Role, error) {
+	var roles []string
+	err := tx.QueryRow(`
+		select roles from users where id = $1 for update
+	`, userID).Scan(&roles)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return toRoles(roles), nil
+}
+
+// SetUserRoles replaces a user's roles. Their access tokens keep the old
+// roles until they're refreshed.
+func (s *Server) SetUserRoles(tx *pgx.Tx, userID int64, roles []Role) ([]Role, error) {
+	names := []string{}
+	for _, role := range roles {
+		if !role.valid() {
+			return nil, ErrUnknownRole
+		}
+		names = append(names, string(role))
+	}
+
+	var updated []string
+	err := tx.QueryRow(`
+		update users
+		set roles = array(select distinct unnest($2::text[]) order by 1),
+			updated_at = now()
+		where id = $1
+		returning roles
+	`, userID, names).Scan(&updated)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, errors.New("user not found")
+	case err != nil:
+		return nil, err
+	}
+
+	return toRoles(updated), nil
+}
+
+type queryRower interface {
+	QueryRow(sql string, args ...interface{}) *pgx.Row
+}
+
+func userRoles(db queryRower, userID int64) ([]Role, error) {
+	var roles []string
+	err := db.QueryRow(`
+		select roles from users where id = $1
+	`, userID).Scan(&roles)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return toRoles(roles), nil
+}
+
+func toRoles(names []string) []Role {
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, Role(name))
+	}
+
+	return roles
+}
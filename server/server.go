@@ -3,6 +3,10 @@ package server
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
@@ -29,18 +33,74 @@ type Server struct {
 	S3                         *s3.S3
 
 	SQSMediaProcessingQueueURL string
-	SNSPlatformApplicationArn  string
 
 	ImgixProcessedMediaEndpoint string
 	ImgixUserMediaMediaEndpoint string
 
 	SQS          *sqs.SQS
 	SNS          *sns.SNS
+	SMS          SMSSender
+	Push         PushSender
+	Email        EmailSender
 	ConnPool     *pgx.ConnPool
 	DB           *gorm.DB
 	MediaConvert *mediaconvert.MediaConvert
 
 	ServerSecret string
+
+	// EmailLoginURL is where emailed login links point, with ?token= added
+	EmailLoginURL string
+
+	// WebAuthnRPID is the domain passkeys are registered to, and
+	// WebAuthnOrigins the client origins allowed to use them
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	// AutoHideThreshold is the weight of reports that hides a post until a
+	// moderator reviews it, zero turns auto hiding off
+	AutoHideThreshold float64
+
+	// ContentFilters check posts, comments and messages before they're
+	// stored, see FilterContent
+	ContentFilters []ContentFilter
+
+	realtimeOnce sync.Once
+	realtime     *realtimeHub
+
+	// contentRules is DBRuleFilter's compiled rules, nil until they're next
+	// loaded
+	contentRulesMu       sync.Mutex
+	contentRules         *RuleFilter
+	contentRulesLoadedAt time.Time
+}
+
+// OpenDB connects GORM and migrates the tables it manages
+func OpenDB(databaseURL string) (*gorm.DB, error) {
+	db, err := gorm.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.DB().Ping(); err != nil {
+		return nil, err
+	}
+
+	// defer db.Close()
+	db.DB().SetMaxOpenConns(10000)
+	db.DB().SetMaxIdleConns(5000)
+
+	// Auto migration
+	db.AutoMigrate(
+		&User{},
+		&Post{},
+		&ReportedPost{},
+		&Follower{},
+		&Like{},
+		&PostComment{},
+	)
+
+	return db, nil
 }
 
 // NewServer ...
@@ -69,32 +129,12 @@ func NewServer() *Server {
 		log.Fatal(err)
 	}
 
-	// GORM config
-	db, err := gorm.Open("postgres", databaseURL)
+	db, err := OpenDB(databaseURL)
 	if err != nil {
 		log.Fatal(err)
 		return nil
 	}
 
-	if err := db.DB().Ping(); err != nil {
-		log.Fatal(err)
-		return nil
-	}
-
-	// defer db.Close()
-	db.DB().SetMaxOpenConns(10000)
-	db.DB().SetMaxIdleConns(5000)
-
-	// Auto migration
-	db.AutoMigrate(
-		&User{},
-		&Post{},
-		&ReportedPost{},
-		&Follower{},
-		&Like{},
-		&PostComment{},
-	)
-
 	// many many AWS services
 	sess := session.New(&aws.Config{
 		Region: aws.String(endpoints.UsEast1RegionID),
@@ -108,6 +148,44 @@ func NewServer() *Server {
 		// Endpoint: aws.String("https://8lrsp3p0.mediaconvert.us-east-1.amazonaws.com"),
 	})
 
+	smsSender, err := newSMSSender(sns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pushSender, err := newPushSender()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	emailSender, err := newEmailSender()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	emailLoginURL := os.Getenv("EMAIL_LOGIN_URL")
+	if emailLoginURL == "" {
+		emailLoginURL = "cobbles://login/email"
+	}
+
+	webAuthnRPID := os.Getenv("WEBAUTHN_RP_ID")
+	if webAuthnRPID == "" {
+		webAuthnRPID = "localhost"
+	}
+
+	webAuthnOrigins := []string{"https://" + webAuthnRPID}
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		webAuthnOrigins = strings.Split(origins, ",")
+	}
+
+	autoHideThreshold := DefaultAutoHideThreshold
+	if threshold := os.Getenv("AUTO_HIDE_THRESHOLD"); threshold != "" {
+		autoHideThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	serverSecret := os.Getenv("SERVER_SECRET")
 	if serverSecret == "" {
 		log.Fatal("set SERVER_SECRET")
@@ -128,26 +206,19 @@ func NewServer() *Server {
 		s3ImageProxyBaseURL = "https://llc-cobbles-dev-user-images.imgix.net"
 	}
 
-	snsApplicationArn := os.Getenv("SNS_APP_ARN")
-	if snsApplicationArn == "" {
-		// snsApplicationArn = "arn:aws:sns:us-east-1:236073164598:app/APNS_SANDBOX/cobbles-dev"
-		snsApplicationArn = "arn:aws:sns:us-east-1:927717636424:app/GCM/cobbles-dev"
-	}
-
 	sqsMediaProcessingQueueURL := os.Getenv("SQS_MEDIA_PROCESSING_QUEUE_URL")
 	if sqsMediaProcessingQueueURL == "" {
 		sqsMediaProcessingQueueURL = "https://sqs.us-east-1.amazonaws.com/927717636424/cobbles_media_events.fifo"
 		//sqsMediaProcessingQueueURL = "https://sqs.us-east-1.amazonaws.com/236073164598/cobbles_media_events.fifo"
 	}
 
-	return &Server{
+	s := &Server{
 		S3ProcessedMediaBucket:     s3ProcessedMediaBucket,
 		S3UserMediaBucket:          s3MediaBucket,
 		S3ImageProxyBaseURL:        s3ImageProxyBaseURL,
 		S3:                         s3,
 		SQSMediaProcessingQueueURL: sqsMediaProcessingQueueURL,
 		SQS:                        sqs,
-		SNSPlatformApplicationArn:  snsApplicationArn,
 
 		// ImgixProcessedMediaEndpoint: "https://processed-user-media.imgix.net",
 		// ImgixUserMediaMediaEndpoint: "https://lc-cobbles-dev-user-images.imgix.net",
@@ -157,7 +228,25 @@ func NewServer() *Server {
 		ConnPool:     connPool, // Legacy
 		DB:           db,
 		SNS:          sns,
+		SMS:          smsSender,
+		Push:         pushSender,
+		Email:        emailSender,
 		MediaConvert: mediaConvert,
 		ServerSecret: serverSecret,
+
+		EmailLoginURL:   emailLoginURL,
+		WebAuthnRPID:    webAuthnRPID,
+		WebAuthnRPName:  "Cobbles",
+		WebAuthnOrigins: webAuthnOrigins,
+
+		AutoHideThreshold: autoHideThreshold,
 	}
+
+	contentFilters, err := newContentFilters(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.ContentFilters = contentFilters
+
+	return s
 }
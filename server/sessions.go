@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+var ErrSessionRevoked = errors.New("session has been revoked")
+
+// Session is a logged in device
+type Session struct {
+	ID         string
+	UserID     int64
+	DeviceID   *string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// SessionActive reports whether an access token's session can still be used
+func (s *Server) SessionActive(userID int64, sessionID string) (bool, error) {
+	var active bool
+	err := s.ConnPool.QueryRow(`
+		select revoked_at is null
+		from sessions
+		where id = $1
+			and user_id = $2
+	`, sessionID, userID).Scan(&active)
+	switch {
+	case err == pgx.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return active, nil
+}
+
+// Sessions lists a user's active sessions, most recently used first
+func (s *Server) Sessions(userID int64) ([]*Session, error) {
+	rows, err := s.ConnPool.Query(`
+		select id, user_id, device_id, created_at, last_seen_at
+		from sessions
+		where user_id = $1
+			and revoked_at is null
+		order by last_seen_at desc
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.DeviceID,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession logs a user out of one session, it's a no-op if the session
+// isn't theirs
+func (s *Server) RevokeSession(userID int64, sessionID string) error {
+	return s.revokeSessions(userID, &sessionID)
+}
+
+// RevokeAllSessions logs a user out everywhere
+func (s *Server) RevokeAllSessions(userID int64) error {
+	return s.revokeSessions(userID, nil)
+}
+
+func (s *Server) revokeSessions(userID int64, sessionID *string) error {
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeSessions(tx, userID, sessionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// revokeSessions revokes one of a user's sessions, or all of them when
+// sessionID is nil, along with their refresh tokens
+func revokeSessions(tx *pgx.Tx, userID int64, sessionID *string) error {
+	if _, err := tx.Exec(`
+		update sessions set revoked_at = now()
+		where user_id = $1
+			and ($2::uuid is null or id = $2)
+			and revoked_at is null
+	`, userID, sessionID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`
+		update refresh_tokens set revoked_at = now()
+		where user_id = $1
+			and ($2::uuid is null or session_id = $2)
+			and revoked_at is null
+	`, userID, sessionID)
+	return err
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// SMSSender delivers text messages, eg login codes
+type SMSSender interface {
+	SendSMS(phoneNumber string, message string) error
+}
+
+// SNSSMSSender sends texts through AWS SNS
+type SNSSMSSender struct {
+	SNS *sns.SNS
+}
+
+func (s *SNSSMSSender) SendSMS(phoneNumber string, message string) error {
+	_, err := s.SNS.Publish(&sns.PublishInput{
+		Message:     aws.String(message),
+		PhoneNumber: aws.String(phoneNumber),
+	})
+	return err
+}
+
+// TwilioSMSSender sends texts through Twilio's Messages API, or anything
+// that speaks it when BaseURL is set
+type TwilioSMSSender struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+
+	// BaseURL defaults to https://api.twilio.com
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s *TwilioSMSSender) SendSMS(phoneNumber string, message string) error {
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.twilio.com"
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(baseURL, "/"), s.AccountSID)
+	form := url.Values{
+		"To":   {phoneNumber},
+		"From": {s.From},
+		"Body": {message},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("twilio: %s: %s", resp.Status, body)
+	}
+
+	return nil
+}
+
+// SMSMessage is a text the FakeSMSSender pretended to send
+type SMSMessage struct {
+	PhoneNumber string    `json:"phoneNumber"`
+	Message     string    `json:"message"`
+	SentAt      time.Time `json:"sentAt"`
+}
+
+// FakeSMSSender keeps texts in memory instead of sending them, and appends
+// them as JSON lines to Path when it's set so they can be read during local
+// development
+type FakeSMSSender struct {
+	Path string
+
+	mutex    sync.Mutex
+	messages []SMSMessage
+}
+
+func (s *FakeSMSSender) SendSMS(phoneNumber string, message string) error {
+	msg := SMSMessage{
+		PhoneNumber: phoneNumber,
+		Message:     message,
+		SentAt:      time.Now(),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages = append(s.messages, msg)
+
+	if s.Path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(msg)
+}
+
+// LastMessage is the most recent text sent to a phone number
+func (s *FakeSMSSender) LastMessage(phoneNumber string) (SMSMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].PhoneNumber == phoneNumber {
+			return s.messages[i], true
+		}
+	}
+
+	return SMSMessage{}, false
+}
+
+// Messages is everything sent so far, oldest first
+func (s *FakeSMSSender) Messages() []SMSMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]SMSMessage(nil), s.messages...)
+}
+
+// newSMSSender picks the SMS provider from SMS_PROVIDER, SNS by default
+func newSMSSender(snsClient *sns.SNS) (SMSSender, error) {
+	switch provider := os.Getenv("SMS_PROVIDER"); provider {
+	case "", "sns":
+		return &SNSSMSSender{SNS: snsClient}, nil
+	case "twilio":
+		sender := &TwilioSMSSender{
+			AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM_NUMBER"),
+			BaseURL:    os.Getenv("TWILIO_API_URL"),
+		}
+		if sender.AccountSID == "" || sender.AuthToken == "" || sender.From == "" {
+			return nil, fmt.Errorf("set TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER")
+		}
+		return sender, nil
+	case "fake":
+		return &FakeSMSSender{Path: os.Getenv("SMS_FAKE_PATH")}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMS_PROVIDER %q", provider)
+	}
+}
@@ -10,14 +10,16 @@ type User struct {
 	ID          int64
 	Name        *string
 	PhoneNumber *string
+	Email       *string
 	ZIPCode     *string
 	PhotoURL    *string
 	Bio         *string
-	FCMToken    *string
 	Followers   *int32
 	Following   *int32
 	PostCount   int32
 
+	NeighborhoodID *int64
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -40,12 +42,13 @@ func (s *Server) UserByID(userID int64) (*User, error) {
 			name,
 			bio,
 			phone_number,
+			email,
 			zip_code,
 			photo_url,
 			followers,
 			following,
 			post_count,
-			fcm_token,
+			neighborhood_id,
 			created_at,
 			updated_at
 		from users
@@ -55,12 +58,13 @@ func (s *Server) UserByID(userID int64) (*User, error) {
 		&u.Name,
 		&u.Bio,
 		&u.PhoneNumber,
+		&u.Email,
 		&u.ZIPCode,
 		&u.PhotoURL,
 		&u.Followers,
 		&u.Following,
 		&u.PostCount,
-		&u.FCMToken,
+		&u.NeighborhoodID,
 		&result.createdAt,
 		&result.updatedAt,
 	)
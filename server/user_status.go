@@ -0,0 +1,222 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// UserStatus is whether a user may use the app
+type UserStatus string
+
+const (
+	UserStatusActive UserStatus = "active"
+	// UserStatusSuspended locks the user out until their suspension ends
+	UserStatusSuspended UserStatus = "suspended"
+	// UserStatusBanned locks the user out until an admin reinstates them
+	UserStatusBanned UserStatus = "banned"
+)
+
+// AccountStatus is a user's status and why an admin or moderator set it
+type AccountStatus struct {
+	Status UserStatus `json:"status"`
+	// SuspendedUntil is only set for suspended users
+	SuspendedUntil *time.Time `json:"suspendedUntil"`
+	Reason         *string    `json:"reason"`
+	ChangedByID    *int64     `json:"changedByID"`
+	ChangedAt      *time.Time `json:"changedAt"`
+}
+
+// Restricted reports whether the user is locked out
+func (a *AccountStatus) Restricted() bool {
+	return a.Status != UserStatusActive
+}
+
+// AccountRestrictedError is returned for requests by suspended and banned
+// users
+type AccountRestrictedError struct {
+	Status         UserStatus
+	SuspendedUntil *time.Time
+}
+
+func (e *AccountRestrictedError) Error() string {
+	if e.Status == UserStatusSuspended && e.SuspendedUntil != nil {
+		return fmt.Sprintf("your account is suspended until %s", e.SuspendedUntil.Format(time.RFC1123))
+	}
+
+	return "your account has been banned"
+}
+
+// effectiveStatusSQL is a user's status, suspensions that have run out are
+// active again
+const effectiveStatusSQL = `
+	case
+		when status = 'suspended' and coalesce(suspended_until <= now(), true) then 'active'
+		else status
+	end`
+
+// ActiveUserSQL is a squirrel condition that's true when the user in column
+// isn't suspended or banned
+func ActiveUserSQL(column string) string {
+	return `not exists (
+		select 1 from users su
+		where su.id = ` + column + `
+			and (su.status = 'banned'
+				or (su.status = 'suspended' and su.suspended_until > now()))
+	)`
+}
+
+// UserAccountStatus is a user's status, active for unknown users
+func (s *Server) UserAccountStatus(userID int64) (*AccountStatus, error) {
+	return userAccountStatus(s.ConnPool, userID, "")
+}
+
+// UserAccountStatusForUpdate is UserAccountStatus read in tx, locking the
+// user until it ends
+func (s *Server) UserAccountStatusForUpdate(tx *pgx.Tx, userID int64) (*AccountStatus, error) {
+	return userAccountStatus(tx, userID, forUpdate)
+}
+
+func userAccountStatus(db querier, userID int64, lock string) (*AccountStatus, error) {
+	var status AccountStatus
+	var name string
+	err := db.QueryRow(`
+		select
+			`+effectiveStatusSQL+`,
+			suspended_until,
+			status_reason,
+			status_changed_by_id,
+			status_changed_at
+		from users
+		where id = $1
+		`+lock+`
+	`, userID).Scan(
+		&name,
+		&status.SuspendedUntil,
+		&status.Reason,
+		&status.ChangedByID,
+		&status.ChangedAt,
+	)
+	switch {
+	case err == pgx.ErrNoRows:
+		return &AccountStatus{Status: UserStatusActive}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	status.Status = UserStatus(name)
+	if status.Status != UserStatusSuspended {
+		status.SuspendedUntil = nil
+	}
+
+	return &status, nil
+}
+
+// CheckAccountActive is an *AccountRestrictedError when the user is suspended
+// or banned
+func (s *Server) CheckAccountActive(userID int64) error {
+	status, err := s.UserAccountStatus(userID)
+	if err != nil {
+		return err
+	}
+
+	if status.Restricted() {
+		return &AccountRestrictedError{
+			Status:         status.Status,
+			SuspendedUntil: status.SuspendedUntil,
+		}
+	}
+
+	return nil
+}
+
+var errStatusReasonRequired = errors.New("a reason is required")
+
+// MaxSuspensionDays is the longest suspension, users who need longer are
+// banned instead
+const MaxSuspensionDays = 3650
+
+// SuspendUser locks a user out for duration, replacing any suspension they
+// already have. Banned users stay banned.
+func (s *Server) SuspendUser(tx *pgx.Tx, adminID, userID int64, duration time.Duration, reason string) (*AccountStatus, error) {
+	if duration <= 0 {
+		return nil, errors.New("suspensions must last longer than that")
+	}
+	if duration > MaxSuspensionDays*24*time.Hour {
+		return nil, errors.New("suspensions can't last that long, ban the user instead")
+	}
+
+	return setUserStatus(tx, adminID, userID, `
+		update users
+		set status = 'suspended',
+			suspended_until = now() + $4::float8 * interval '1 second',
+			status_reason = $3,
+			status_changed_by_id = $2,
+			status_changed_at = now(),
+			updated_at = now()
+		where id = $1
+			and status != 'banned'
+	`, reason, duration.Seconds())
+}
+
+// BanUser locks a user out until they're reinstated
+func (s *Server) BanUser(tx *pgx.Tx, adminID, userID int64, reason string) (*AccountStatus, error) {
+	return setUserStatus(tx, adminID, userID, `
+		update users
+		set status = 'banned',
+			suspended_until = null,
+			status_reason = $3,
+			status_changed_by_id = $2,
+			status_changed_at = now(),
+			updated_at = now()
+		where id = $1
+	`, reason)
+}
+
+// ReinstateUser lifts a user's suspension or ban
+func (s *Server) ReinstateUser(tx *pgx.Tx, adminID, userID int64, reason string) (*AccountStatus, error) {
+	return setUserStatus(tx, adminID, userID, `
+		update users
+		set status = 'active',
+			suspended_until = null,
+			status_reason = $3,
+			status_changed_by_id = $2,
+			status_changed_at = now(),
+			updated_at = now()
+		where id = $1
+	`, reason)
+}
+
+// setUserStatus runs sql, an update taking the user, the admin and the
+// reason and then args
+func setUserStatus(db querier, adminID, userID int64, sql string, reason string, args ...interface{}) (*AccountStatus, error) {
+	if reason == "" {
+		return nil, errStatusReasonRequired
+	}
+
+	if adminID == userID {
+		return nil, errors.New("you can't change your own status")
+	}
+
+	tag, err := db.Exec(sql, append([]interface{}{userID, adminID, reason}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		status, err := userAccountStatus(db, userID, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Status == UserStatusBanned {
+			return nil, errors.New("user is banned")
+		}
+
+		return nil, errors.New("user not found")
+	}
+
+	return userAccountStatus(db, userID, "")
+}
@@ -0,0 +1,516 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Passkeys are WebAuthn credentials. Only ES256 keys are accepted and
+// attestation isn't checked: we ask for "none", so any attestation statement
+// an authenticator sends anyway is ignored.
+
+const webauthnChallengeTTL = 5 * time.Minute
+
+const (
+	webauthnRegister = "register"
+	webauthnLogin    = "login"
+)
+
+// authenticator data flags
+const (
+	authDataUserPresent  = 0x01
+	authDataAttestedCred = 0x40
+)
+
+// COSE algorithm -7 is ECDSA with SHA-256 on P-256
+const coseAlgES256 = -7
+
+var ErrInvalidPasskey = errors.New("invalid passkey")
+
+// Passkey is a user's registered WebAuthn credential
+type Passkey struct {
+	ID           int64
+	UserID       int64
+	Name         *string
+	CredentialID []byte
+	CreatedAt    time.Time
+	LastUsedAt   *time.Time
+}
+
+// PasskeyChallenge is handed to the client to pass to
+// navigator.credentials.create() or .get(), Options is the publicKey
+// argument as JSON with binary fields base64url encoded
+type PasskeyChallenge struct {
+	ID      string
+	Options string
+}
+
+// BeginPasskeyRegistration starts adding a passkey to a user
+func (s *Server) BeginPasskeyRegistration(userID int64) (*PasskeyChallenge, error) {
+	var displayName string
+	err := s.ConnPool.QueryRow(`
+		select coalesce(name, email, phone_number, '') from users where id = $1
+	`, userID).Scan(&displayName)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.Passkeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, challenge, err := s.newWebAuthnChallenge(webauthnRegister, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type credentialDescriptor struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	exclude := []credentialDescriptor{}
+	for _, p := range existing {
+		exclude = append(exclude, credentialDescriptor{
+			Type: "public-key",
+			ID:   base64.RawURLEncoding.EncodeToString(p.CredentialID),
+		})
+	}
+
+	if displayName == "" {
+		displayName = "Cobbles neighbor"
+	}
+
+	options, err := json.Marshal(map[string]interface{}{
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"rp": map[string]string{
+			"id":   s.WebAuthnRPID,
+			"name": s.WebAuthnRPName,
+		},
+		"user": map[string]string{
+			"id":          base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(userID, 10))),
+			"name":        displayName,
+			"displayName": displayName,
+		},
+		"pubKeyCredParams": []map[string]interface{}{
+			{"type": "public-key", "alg": coseAlgES256},
+		},
+		"authenticatorSelection": map[string]string{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+		"excludeCredentials": exclude,
+		"attestation":        "none",
+		"timeout":            int(webauthnChallengeTTL / time.Millisecond),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyChallenge{ID: id, Options: string(options)}, nil
+}
+
+// FinishPasskeyRegistration checks the authenticator's response to a
+// registration challenge and saves the new passkey
+func (s *Server) FinishPasskeyRegistration(userID int64, challengeID string, clientDataJSON []byte, attestationObject []byte, name *string) (*Passkey, error) {
+	challenge, err := s.consumeWebAuthnChallenge(challengeID, webauthnRegister, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidPasskey
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidPasskey
+	}
+
+	authData, err := s.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.flags&authDataAttestedCred == 0 {
+		return nil, ErrInvalidPasskey
+	}
+
+	publicKey, err := parseCOSEKey(authData.credentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Passkey
+	err = s.ConnPool.QueryRow(`
+		insert into passkeys (user_id, name, credential_id, public_key, sign_count, created_at)
+		values ($1, $2, $3, $4, $5, now())
+		returning id, user_id, name, credential_id, created_at, last_used_at
+	`, userID, name, authData.credentialID, elliptic.Marshal(elliptic.P256(), publicKey.X, publicKey.Y), authData.signCount).Scan(
+		&p.ID,
+		&p.UserID,
+		&p.Name,
+		&p.CredentialID,
+		&p.CreatedAt,
+		&p.LastUsedAt,
+	)
+	if isUniqueViolation(err) {
+		return nil, errors.New("passkey is already registered")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// BeginPasskeyLogin starts a login with any registered passkey
+func (s *Server) BeginPasskeyLogin() (*PasskeyChallenge, error) {
+	id, challenge, err := s.newWebAuthnChallenge(webauthnLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := json.Marshal(map[string]interface{}{
+		"challenge":        base64.RawURLEncoding.EncodeToString(challenge),
+		"rpId":             s.WebAuthnRPID,
+		"allowCredentials": []interface{}{},
+		"userVerification": "preferred",
+		"timeout":          int(webauthnChallengeTTL / time.Millisecond),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyChallenge{ID: id, Options: string(options)}, nil
+}
+
+// FinishPasskeyLogin checks an assertion against a login challenge and
+// returns the passkey's user
+func (s *Server) FinishPasskeyLogin(challengeID string, credentialID []byte, clientDataJSON []byte, rawAuthData []byte, signature []byte, fcmToken *string) (int64, error) {
+	challenge, err := s.consumeWebAuthnChallenge(challengeID, webauthnLogin, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := s.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.ConnPool.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var stored struct {
+		id        int64
+		userID    int64
+		publicKey []byte
+		signCount int64
+	}
+	err = tx.QueryRow(`
+		select id, user_id, public_key, sign_count
+		from passkeys
+		where credential_id = $1
+		for update
+	`, credentialID).Scan(&stored.id, &stored.userID, &stored.publicKey, &stored.signCount)
+	switch {
+	case err == pgx.ErrNoRows:
+		return 0, ErrInvalidPasskey
+	case err != nil:
+		return 0, err
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), stored.publicKey)
+	if x == nil {
+		return 0, ErrInvalidPasskey
+	}
+
+	var sig struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) > 0 {
+		return 0, ErrInvalidPasskey
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := sha256.Sum256(append(append([]byte(nil), rawAuthData...), clientDataHash[:]...))
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, signed[:], sig.R, sig.S) {
+		return 0, ErrInvalidPasskey
+	}
+
+	// a counter that doesn't move forward means the key was cloned,
+	// authenticators that don't count always send 0
+	if (authData.signCount != 0 || stored.signCount != 0) && int64(authData.signCount) <= stored.signCount {
+		return 0, ErrInvalidPasskey
+	}
+
+	if _, err := tx.Exec(`
+		update passkeys set sign_count = $2, last_used_at = now()
+		where id = $1
+	`, stored.id, int64(authData.signCount)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if fcmToken != nil && *fcmToken != "" {
+		if _, err := s.RegisterPushDevice(stored.userID, PushPlatformFCM, *fcmToken, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	return stored.userID, nil
+}
+
+// Passkeys lists a user's passkeys, newest first
+func (s *Server) Passkeys(userID int64) ([]*Passkey, error) {
+	rows, err := s.ConnPool.Query(`
+		select id, user_id, name, credential_id, created_at, last_used_at
+		from passkeys
+		where user_id = $1
+		order by created_at desc
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []*Passkey
+	for rows.Next() {
+		var p Passkey
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Name,
+			&p.CredentialID,
+			&p.CreatedAt,
+			&p.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		passkeys = append(passkeys, &p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return passkeys, nil
+}
+
+// RemovePasskey deletes one of a user's passkeys, false if it isn't theirs
+func (s *Server) RemovePasskey(userID int64, passkeyID int64) (bool, error) {
+	tag, err := s.ConnPool.Exec(`
+		delete from passkeys where id = $1 and user_id = $2
+	`, passkeyID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *Server) newWebAuthnChallenge(kind string, userID *int64) (string, []byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", nil, err
+	}
+
+	id := uuid.Must(uuid.NewV4()).String()
+	if _, err := s.ConnPool.Exec(`
+		insert into webauthn_challenges (id, user_id, kind, challenge, expires_at, created_at)
+		values ($1, $2, $3, $4, now() + $5::interval, now())
+	`, id, userID, kind, challenge, fmt.Sprintf("%d seconds", int(webauthnChallengeTTL.Seconds()))); err != nil {
+		return "", nil, err
+	}
+
+	return id, challenge, nil
+}
+
+// consumeWebAuthnChallenge deletes a challenge so it can only be answered
+// once, and returns it if it's still valid
+func (s *Server) consumeWebAuthnChallenge(id string, kind string, userID *int64) ([]byte, error) {
+	if _, err := uuid.FromString(id); err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	var challenge []byte
+	err := s.ConnPool.QueryRow(`
+		delete from webauthn_challenges
+		where id = $1
+			and kind = $2
+			and user_id is not distinct from $3
+			and expires_at >= now()
+		returning challenge
+	`, id, kind, userID).Scan(&challenge)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, ErrInvalidPasskey
+	case err != nil:
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (s *Server) verifyClientData(clientDataJSON []byte, expectedType string, challenge []byte) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return ErrInvalidPasskey
+	}
+
+	if clientData.Type != expectedType {
+		return ErrInvalidPasskey
+	}
+
+	got, err := DecodeBase64URL(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrInvalidPasskey
+	}
+
+	for _, origin := range s.WebAuthnOrigins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+
+	return ErrInvalidPasskey
+}
+
+type authenticatorData struct {
+	flags     byte
+	signCount uint32
+
+	// only set when flags has authDataAttestedCred
+	credentialID        []byte
+	credentialPublicKey []byte
+}
+
+func (s *Server) parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	// rpIdHash, flags, signCount
+	if len(b) < 37 {
+		return nil, ErrInvalidPasskey
+	}
+
+	rpIDHash := sha256.Sum256([]byte(s.WebAuthnRPID))
+	if !bytes.Equal(b[:32], rpIDHash[:]) {
+		return nil, ErrInvalidPasskey
+	}
+
+	d := &authenticatorData{
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+
+	if d.flags&authDataUserPresent == 0 {
+		return nil, ErrInvalidPasskey
+	}
+
+	if d.flags&authDataAttestedCred == 0 {
+		return d, nil
+	}
+
+	// aaguid, credentialIdLength, credentialId, credentialPublicKey
+	rest := b[37:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidPasskey
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return nil, ErrInvalidPasskey
+	}
+	d.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	_, after, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	d.credentialPublicKey = rest[:len(rest)-len(after)]
+
+	return d, nil
+}
+
+// parseCOSEKey reads an ES256 public key in COSE_Key form
+func parseCOSEKey(b []byte) (*ecdsa.PublicKey, error) {
+	decoded, _, err := decodeCBOR(b)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidPasskey
+	}
+
+	// kty EC2, alg ES256, crv P-256
+	if key[int64(1)] != int64(2) || key[int64(3)] != int64(coseAlgES256) || key[int64(-1)] != int64(1) {
+		return nil, errors.New("only ES256 passkeys are supported")
+	}
+
+	x, xOK := key[int64(-2)].([]byte)
+	y, yOK := key[int64(-3)].([]byte)
+	if !xOK || !yOK || len(x) != 32 || len(y) != 32 {
+		return nil, ErrInvalidPasskey
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, ErrInvalidPasskey
+	}
+
+	return publicKey, nil
+}
+
+// DecodeBase64URL decodes WebAuthn's unpadded base64url, tolerating padding
+func DecodeBase64URL(s string) ([]byte, error) {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+
+	return base64.RawURLEncoding.DecodeString(s)
+}